@@ -7,6 +7,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	iofs "io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -15,14 +16,18 @@ import (
 	"sort"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/diagfmt"
 	"github.com/cookiespooky/notepub/internal/indexer"
 	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/mountutil"
+	"github.com/cookiespooky/notepub/internal/redirects"
+	"github.com/cookiespooky/notepub/internal/resolvediff"
 	"github.com/cookiespooky/notepub/internal/rules"
-	"github.com/cookiespooky/notepub/internal/s3util"
+	"github.com/cookiespooky/notepub/internal/schema"
 	"github.com/cookiespooky/notepub/internal/serve"
 	"github.com/cookiespooky/notepub/internal/templateupdate"
 )
@@ -71,8 +76,20 @@ func run() int {
 		err = buildCmd(args)
 	case "validate":
 		err = validateCmd(args)
+	case "redirects":
+		err = redirectsCmd(args)
+	case "new":
+		err = newCmd(args)
+	case "init":
+		err = initCmd(args)
+	case "diff":
+		err = diffCmd(args)
+	case "schema":
+		err = schemaCmd(args)
 	case "template":
 		err = templateCmd(args)
+	case "theme":
+		err = themeCmd(args)
 	default:
 		err = usageError(fmt.Sprintf("unknown command: %s", cmd), usageWriter)
 	}
@@ -85,7 +102,7 @@ func run() int {
 }
 
 func indexCmd(args []string) error {
-	fs, configPath, rulesPath := newIndexFlagSet()
+	fs, configPath, rulesPath, report, keepGoing, maxErrors, keepPrevious := newIndexFlagSet()
 	helped, err := parseFlags(fs, args, newIndexUsageWriter(fs))
 	if err != nil {
 		return err
@@ -113,19 +130,54 @@ func indexCmd(args []string) error {
 		return err
 	}
 	cfg.RulesPath = resolvedRules
+	reportFormat := strings.ToLower(strings.TrimSpace(*report))
+	switch reportFormat {
+	case "", "text", "json", "github", "sarif":
+	default:
+		return usageError(fmt.Sprintf("unsupported index report format %q (use text, json, github or sarif)", *report), newIndexUsageWriter(fs))
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	if err := indexer.Run(ctx, cfg); err != nil {
+	if (*maxErrors != 0 || *keepPrevious) && !*keepGoing {
+		return usageError("--max-errors and --keep-previous require --keep-going", newIndexUsageWriter(fs))
+	}
+	if *maxErrors < 0 {
+		return usageError("--max-errors must be >= 0", newIndexUsageWriter(fs))
+	}
+	var templates []string
+	if theme, themeErr := serve.LoadThemeConfig(cfg.Theme); themeErr != nil {
+		log.Printf("theme: %v; frontmatter template overrides are not checked", themeErr)
+	} else {
+		templates = theme.TemplateNames()
+	}
+	result, err := indexer.RunWithReport(ctx, cfg, indexer.RunOptions{
+		KeepGoing:    *keepGoing,
+		MaxErrors:    *maxErrors,
+		KeepPrevious: *keepPrevious,
+		Templates:    templates,
+	})
+	if reportFormat != "" && reportFormat != "text" {
+		rendered, renderErr := renderIndexReport(result, reportFormat, contentDiagOptions(cfg))
+		if renderErr != nil {
+			return fmt.Errorf("index report: %w", renderErr)
+		}
+		_, _ = os.Stdout.Write(rendered)
+	}
+	if err != nil {
 		return fmt.Errorf("index: %w", err)
 	}
+	if len(result.Quarantined) > 0 {
+		log.Printf("index completed with %d quarantined note(s), see %s", len(result.Quarantined), filepath.Join(cfg.Paths.ArtifactsDir, "quarantine.json"))
+		return nil
+	}
 	log.Println("index completed")
 	return nil
 }
 
 func serveCmd(args []string) error {
-	fs, configPath, rulesPath, addr := newServeFlagSet()
+	fs, configPath, rulesPath, addr, sitesPath, drafts := newServeFlagSet()
 	helped, err := parseFlags(fs, args, newServeUsageWriter(fs))
 	if err != nil {
 		return err
@@ -133,6 +185,12 @@ func serveCmd(args []string) error {
 	if helped {
 		return nil
 	}
+	if *sitesPath != "" {
+		if *configPath != "" || *rulesPath != "" {
+			return usageError("--sites cannot be combined with --config or --rules", newServeUsageWriter(fs))
+		}
+		return serveSites(*sitesPath, *addr, *drafts)
+	}
 
 	if *rulesPath != "" {
 		if _, err := validateRulesPath(*rulesPath); err != nil {
@@ -162,48 +220,88 @@ func serveCmd(args []string) error {
 		return err
 	}
 	cfg.RulesPath = resolvedRules
+	if *drafts {
+		cfg.Server.Drafts = true
+	}
+
+	srv, err := newSiteServer(cfg)
+	if err != nil {
+		return err
+	}
+	return listenAndServe(cfg.Server.Listen, srv.Router())
+}
+
+func serveSites(sitesPath, addr string, drafts bool) error {
+	sitesCfg, err := config.LoadSites(sitesPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("sites file not found: %s", sitesPath)
+		}
+		return fmt.Errorf("load sites: %w", err)
+	}
+	servers := make([]*serve.Server, 0, len(sitesCfg.Sites))
+	listenAddr := firstNonEmpty(addr, sitesCfg.Listen)
+	for _, entry := range sitesCfg.Sites {
+		cfg, err := config.Load(entry.Config)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("config file not found: %s", entry.Config)
+			}
+			return fmt.Errorf("load config %s: %w", entry.Config, err)
+		}
+		rulesPath, err := validateRulesPath(firstNonEmpty(entry.Rules, cfg.RulesPath))
+		if err != nil {
+			return err
+		}
+		cfg.RulesPath = rulesPath
+		if drafts {
+			cfg.Server.Drafts = true
+		}
+		srv, err := newSiteServer(cfg)
+		if err != nil {
+			return fmt.Errorf("site %s: %w", cfg.Site.ID, err)
+		}
+		servers = append(servers, srv)
+		listenAddr = firstNonEmpty(listenAddr, cfg.Server.Listen)
+		log.Printf("site mounted: id=%s host=%s aliases=%s", cfg.Site.ID, cfg.Site.Host, strings.Join(cfg.Site.HostAliases, ","))
+	}
+	handler, err := serve.NewMultiSite(servers, sitesCfg.Default)
+	if err != nil {
+		return fmt.Errorf("sites: %w", err)
+	}
+	return listenAndServe(listenAddr, handler)
+}
 
+func newSiteServer(cfg config.Config) (*serve.Server, error) {
 	rulesCfg, err := rules.Load(cfg.RulesPath)
 	if err != nil {
-		return fmt.Errorf("load rules: %w", err)
+		return nil, fmt.Errorf("load rules: %w", err)
 	}
 
 	resolvePath := filepath.Join(cfg.Paths.ArtifactsDir, "resolve.json")
 	store := serve.NewResolveStore(resolvePath, rulesCfg, cfg.Media.ExposeAllUnderPrefix, cfg.Settings)
 	cache := serve.NewHtmlCache(cfg.Paths.CacheRoot, cfg.Theme.Name, cfg.Site.BaseURL+"|"+cfg.Site.MediaBaseURL)
-	themeDir := filepath.Join(cfg.Theme.Dir, cfg.Theme.Name)
-	theme, err := serve.LoadTheme(themeDir, cfg.Theme.TemplatesSubdir, cfg.Theme.AssetsSubdir)
+	theme, err := serve.LoadThemeConfig(cfg.Theme)
 	if err != nil {
-		return fmt.Errorf("load theme: %w", err)
+		return nil, fmt.Errorf("load theme: %w", err)
 	}
-	log.Printf("theme loaded: path=%s fallback=%t", themeDir, theme.UsedFallback())
-
-	var client *s3.Client
-	if cfg.Content.Source == "s3" {
-		client, err = s3util.NewClient(context.Background(), s3util.Config{
-			Endpoint:       cfg.S3.Endpoint,
-			Region:         cfg.S3.Region,
-			ForcePathStyle: cfg.S3.ForcePathStyle,
-			Bucket:         cfg.S3.Bucket,
-			Prefix:         cfg.S3.Prefix,
-			AccessKey:      cfg.S3.AccessKey,
-			SecretKey:      cfg.S3.SecretKey,
-			Anonymous:      cfg.S3.Anonymous,
-		})
-		if err != nil {
-			return fmt.Errorf("s3 client: %w", err)
-		}
+	log.Printf("theme loaded: layers=%s fallback=%t", strings.Join(theme.Layers(), ","), theme.UsedFallback())
+
+	client, err := mountutil.NewClient(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("s3 client: %w", err)
 	}
 
-	srv := serve.New(cfg, store, cache, theme, client, rulesCfg)
+	return serve.New(cfg, store, cache, theme, client, rulesCfg), nil
+}
 
+func listenAndServe(listenAddr string, handler http.Handler) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	listenAddr := cfg.Server.Listen
 	server := &http.Server{
 		Addr:              listenAddr,
-		Handler:           srv.Router(),
+		Handler:           handler,
 		ReadHeaderTimeout: readHeaderTimeout,
 		ReadTimeout:       readTimeout,
 		WriteTimeout:      writeTimeout,
@@ -235,7 +333,7 @@ func serveCmd(args []string) error {
 }
 
 func buildCmd(args []string) error {
-	fs, configPath, rulesPath, distDir, artifactsDir, noIndex, generateSearch := newBuildFlagSet()
+	fs, configPath, rulesPath, distDir, artifactsDir, noIndex, generateSearch, redirectsFormat := newBuildFlagSet()
 	helped, err := parseFlags(fs, args, newBuildUsageWriter(fs))
 	if err != nil {
 		return err
@@ -276,6 +374,7 @@ func buildCmd(args []string) error {
 		ArtifactsDir:   *artifactsDir,
 		NoIndex:        *noIndex,
 		GenerateSearch: *generateSearch,
+		Redirects:      strings.ToLower(strings.TrimSpace(*redirectsFormat)),
 	}
 	if err := serve.Build(ctx, cfg, rulesCfg, opts); err != nil {
 		return fmt.Errorf("build: %w", err)
@@ -285,7 +384,7 @@ func buildCmd(args []string) error {
 }
 
 func validateCmd(args []string) error {
-	fs, configPath, rulesPath, resolvePath, validateLinks, validateMarkdown, markdownStrict, markdownFormat, markdownOutput := newValidateFlagSet()
+	fs, configPath, rulesPath, resolvePath, validateLinks, validateMarkdown, markdownStrict, markdownFormat, markdownOutput, showTypes, validateExternal, distDir, rulesOnly := newValidateFlagSet()
 	helped, err := parseFlags(fs, args, newValidateUsageWriter(fs))
 	if err != nil {
 		return err
@@ -301,6 +400,9 @@ func validateCmd(args []string) error {
 	}
 
 	configPathResolved := resolveConfigPath(*configPath)
+	if *rulesOnly {
+		return validateRulesOnly(os.Stdout, configPathResolved, *rulesPath)
+	}
 	cfg, err := config.Load(configPathResolved)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -317,9 +419,39 @@ func validateCmd(args []string) error {
 	if err != nil {
 		return fmt.Errorf("load rules: %w", err)
 	}
-	if err := indexer.ValidateRules(rulesCfg); err != nil {
+	warnings, err := indexer.ValidateRules(rulesCfg)
+	if err != nil {
 		return fmt.Errorf("rules validation: %w", err)
 	}
+	for _, w := range warnings {
+		log.Printf("rules warning: %s", w)
+	}
+	format := normalizeMarkdownFormat(*markdownFormat)
+	if format == "" {
+		return fmt.Errorf("validate: unsupported markdown format %q (use text, json, sarif or github)", *markdownFormat)
+	}
+
+	// Every report of this run goes to one output: SARIF and github merge all
+	// diagnostic sets into a single log or annotation stream, and a failing
+	// report does not stop the ones after it.
+	out := &validateOutput{format: format}
+
+	// External results join the markdown report when both are requested.
+	var externalDiags []indexer.MarkdownDiagnostic
+	if *validateExternal {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		opts, err := indexer.ExternalLinkOptionsFor(cfg, rulesCfg.Validation.ExternalLinks)
+		if err != nil {
+			return fmt.Errorf("external link validation: %w", err)
+		}
+		externalDiags, err = indexer.ValidateExternalLinks(ctx, cfg, opts)
+		if err != nil {
+			return fmt.Errorf("external link validation: %w", err)
+		}
+		errCount, warnCount := indexer.CountDiagnostics(externalDiags)
+		log.Printf("external link validation: %d error(s), %d warning(s)", errCount, warnCount)
+	}
 
 	path := *resolvePath
 	if path == "" {
@@ -333,6 +465,9 @@ func validateCmd(args []string) error {
 		if err != nil {
 			return fmt.Errorf("resolve validation: %w", err)
 		}
+		if *showTypes {
+			printTypeAssignments(os.Stdout, idx)
+		}
 		if *validateLinks {
 			if err := indexer.ValidateResolveLinks(idx, rulesCfg, cfg.S3.Prefix); err != nil {
 				return fmt.Errorf("link validation: %w", err)
@@ -341,39 +476,419 @@ func validateCmd(args []string) error {
 		if *validateMarkdown {
 			ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 			defer cancel()
-			diags, caps, err := indexer.ValidateMarkdownWithCapabilities(ctx, cfg, idx)
-			if err != nil {
-				return fmt.Errorf("markdown validation: %w", err)
+			var shortcodes []string
+			if theme, themeErr := serve.LoadThemeConfig(cfg.Theme); themeErr != nil {
+				log.Printf("theme: %v; shortcodes are not checked", themeErr)
+			} else {
+				shortcodes = theme.Shortcodes()
 			}
-			format := normalizeMarkdownFormat(*markdownFormat)
-			if format == "" {
-				return fmt.Errorf("markdown validation: unsupported markdown format %q (use text or json)", *markdownFormat)
-			}
-			rendered, err := renderMarkdownDiagnostics(diags, caps, format)
+			diags, caps, err := indexer.ValidateMarkdownWithCapabilities(ctx, cfg, idx, shortcodes)
 			if err != nil {
-				return fmt.Errorf("markdown validation output: %w", err)
+				return fmt.Errorf("markdown validation: %w", err)
 			}
-			if err := writeMarkdownDiagnostics(rendered, *markdownOutput); err != nil {
+			diags = append(diags, externalDiags...)
+			if err := out.add(diags, contentDiagOptions(cfg), func() ([]byte, error) {
+				return renderMarkdownDiagnostics(diags, caps, format)
+			}); err != nil {
 				return fmt.Errorf("markdown validation output: %w", err)
 			}
 			errCount, warnCount := indexer.CountDiagnostics(diags)
 			log.Printf("markdown validation: %d error(s), %d warning(s)", errCount, warnCount)
 			if errCount > 0 {
-				return fmt.Errorf("markdown validation failed (%d errors)", errCount)
-			}
-			if *markdownStrict && warnCount > 0 {
-				return fmt.Errorf("markdown validation strict failed (%d warnings)", warnCount)
+				out.fail(fmt.Errorf("markdown validation failed (%d errors)", errCount))
+			} else if *markdownStrict && warnCount > 0 {
+				out.fail(fmt.Errorf("markdown validation strict failed (%d warnings)", warnCount))
 			}
 		}
+	} else if *showTypes {
+		return fmt.Errorf("type report: resolve.json not found (use --resolve or run index)")
 	} else if *validateLinks {
 		return fmt.Errorf("link validation: resolve.json not found (use --resolve)")
 	} else if *validateMarkdown {
 		return fmt.Errorf("markdown validation: resolve.json not found (use --resolve or run index)")
 	}
+	if strings.TrimSpace(*distDir) != "" {
+		diags, err := serve.CheckDist(*distDir, cfg.Site.BaseURL)
+		if err != nil {
+			return fmt.Errorf("dist validation: %w", err)
+		}
+		distOpts := diagfmt.Options{Version: version, FilePath: func(page string) string {
+			return filepath.ToSlash(filepath.Join(*distDir, filepath.FromSlash(page)))
+		}}
+		if err := out.add(diags, distOpts, func() ([]byte, error) {
+			return renderDistReport(diags, format)
+		}); err != nil {
+			return fmt.Errorf("dist validation output: %w", err)
+		}
+		if errCount, _ := indexer.CountDiagnostics(diags); errCount > 0 {
+			out.fail(fmt.Errorf("dist validation failed (%d errors)", errCount))
+		}
+	}
+	if *validateExternal && !(path != "" && *validateMarkdown) {
+		if err := out.add(externalDiags, contentDiagOptions(cfg), func() ([]byte, error) {
+			return renderDiagnostics(externalDiags, format)
+		}); err != nil {
+			return fmt.Errorf("external link validation output: %w", err)
+		}
+		errCount, warnCount := indexer.CountDiagnostics(externalDiags)
+		if errCount > 0 {
+			out.fail(fmt.Errorf("external link validation failed (%d errors)", errCount))
+		} else if *markdownStrict && warnCount > 0 {
+			out.fail(fmt.Errorf("external link validation strict failed (%d warnings)", warnCount))
+		}
+	}
+	if err := out.write(*markdownOutput); err != nil {
+		return fmt.Errorf("validation output: %w", err)
+	}
+	if out.failure != nil {
+		return out.failure
+	}
 	log.Println("validate completed")
 	return nil
 }
 
+func redirectsCmd(args []string) error {
+	fs, configPath, resolvePath, format, output := newRedirectsFlagSet()
+	helped, err := parseFlags(fs, args, newRedirectsUsageWriter(fs))
+	if err != nil {
+		return err
+	}
+	if helped {
+		return nil
+	}
+	formatVal := strings.ToLower(strings.TrimSpace(*format))
+	if redirects.FileName(formatVal) == "" {
+		return usageError(fmt.Sprintf("unsupported redirects format %q (use %s)", *format, strings.Join(redirects.Formats, ", ")), newRedirectsUsageWriter(fs))
+	}
+
+	path := *resolvePath
+	if path == "" {
+		configPathResolved := resolveConfigPath(*configPath)
+		cfg, err := config.Load(configPathResolved)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("config file not found: %s", configPathResolved)
+			}
+			return fmt.Errorf("load config: %w", err)
+		}
+		path = filepath.Join(cfg.Paths.ArtifactsDir, "resolve.json")
+	}
+	idx, err := validateResolve(path)
+	if err != nil {
+		return fmt.Errorf("resolve: %w", err)
+	}
+	// Only redirect to notes that are actually published.
+	idx, _ = indexer.PublishedView(indexer.ExcludeDrafts(idx), time.Now())
+
+	var buf strings.Builder
+	if err := redirects.Write(&buf, formatVal, redirects.Table(idx)); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*output) == "" {
+		_, err := io.WriteString(os.Stdout, buf.String())
+		return err
+	}
+	return os.WriteFile(*output, []byte(buf.String()), 0o644)
+}
+
+func diffCmd(args []string) error {
+	fs, format, output, allowLost := newDiffFlagSet()
+	usage := newDiffUsageWriter(fs)
+	// Flags may follow the two resolve paths.
+	positional := []string{}
+	rest := args
+	for {
+		helped, err := parseFlags(fs, rest, usage)
+		if err != nil {
+			return err
+		}
+		if helped {
+			return nil
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+	if len(positional) != 2 {
+		return usageError("diff requires an old and a new resolve.json", usage)
+	}
+	formatVal := strings.ToLower(strings.TrimSpace(*format))
+	if formatVal != "text" && formatVal != "json" {
+		return usageError(fmt.Sprintf("unsupported diff format %q (use text or json)", *format), usage)
+	}
+	oldIdx, err := validateResolve(positional[0])
+	if err != nil {
+		return fmt.Errorf("old resolve: %w", err)
+	}
+	newIdx, err := validateResolve(positional[1])
+	if err != nil {
+		return fmt.Errorf("new resolve: %w", err)
+	}
+
+	report := resolvediff.Diff(oldIdx, newIdx, time.Now())
+	var buf strings.Builder
+	if formatVal == "json" {
+		err = resolvediff.WriteJSON(&buf, report)
+	} else {
+		err = resolvediff.WriteText(&buf, report)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(*output) == "" {
+		if _, err := io.WriteString(os.Stdout, buf.String()); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(*output, []byte(buf.String()), 0o644); err != nil {
+		return err
+	}
+	if lost := report.Lost(); len(lost) > 0 && !*allowLost {
+		return fmt.Errorf("diff: %d URL(s) disappear without a redirect: %s", len(lost), strings.Join(lost, ", "))
+	}
+	return nil
+}
+
+func schemaCmd(args []string) error {
+	fs, output := newSchemaFlagSet()
+	usage := newSchemaUsageWriter(fs)
+	// Flags may follow the schema name.
+	positional := []string{}
+	rest := args
+	for {
+		helped, err := parseFlags(fs, rest, usage)
+		if err != nil {
+			return err
+		}
+		if helped {
+			return nil
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+	if len(positional) != 1 {
+		return usageError("schema requires config or rules", usage)
+	}
+	data, err := schema.Generate(positional[0])
+	if err != nil {
+		return usageError(err.Error(), usage)
+	}
+	if strings.TrimSpace(*output) == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o644)
+}
+
+func newCmd(args []string) error {
+	fs, configPath, rulesPath, resolvePath, slugFlag, dirFlag, mountFlag, dryRun := newNewFlagSet()
+	usage := newNewUsageWriter(fs)
+	// Flags may follow the positional type and title.
+	positional := []string{}
+	rest := args
+	for {
+		helped, err := parseFlags(fs, rest, usage)
+		if err != nil {
+			return err
+		}
+		if helped {
+			return nil
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+	if len(positional) < 2 {
+		return usageError("new requires a type and a title", usage)
+	}
+	typeName := positional[0]
+	title := strings.Join(positional[1:], " ")
+
+	configPathResolved := resolveConfigPath(*configPath)
+	cfg, err := config.Load(configPathResolved)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("config file not found: %s", configPathResolved)
+		}
+		return fmt.Errorf("load config: %w", err)
+	}
+	resolvedRules, err := resolveRulesPath(configPathResolved, cfg.RulesPath, *rulesPath)
+	if err != nil {
+		return err
+	}
+	rulesCfg, err := rules.Load(resolvedRules)
+	if err != nil {
+		return fmt.Errorf("load rules: %w", err)
+	}
+	if _, err := indexer.ValidateRules(rulesCfg); err != nil {
+		return err
+	}
+	if _, ok := rulesCfg.Types[typeName]; !ok {
+		return usageError(fmt.Sprintf("unknown type %q (rules.yaml types: %s)", typeName, strings.Join(rulesCfg.TypeOrder, ", ")), usage)
+	}
+
+	contentDir, err := newNoteContentDir(cfg, typeName, *mountFlag)
+	if err != nil {
+		return err
+	}
+
+	path := *resolvePath
+	if path == "" {
+		path = filepath.Join(cfg.Paths.ArtifactsDir, "resolve.json")
+	}
+	idx, err := validateResolve(path)
+	if err != nil {
+		log.Printf("new: slug uniqueness checked against files only (%v)", err)
+		idx = models.ResolveIndex{}
+	}
+
+	theme, err := serve.LoadThemeConfig(cfg.Theme)
+	if err != nil {
+		return fmt.Errorf("theme: %w", err)
+	}
+	body := ""
+	for _, name := range []string{typeName + ".md", "default.md"} {
+		data, err := theme.ReadFile("archetypes/" + name)
+		if err == nil {
+			body = string(data)
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read archetype: %w", err)
+		}
+	}
+
+	note, err := indexer.ScaffoldNote(rulesCfg, idx, indexer.NewNoteOptions{
+		Type:         typeName,
+		Title:        title,
+		Slug:         *slugFlag,
+		ContentDir:   contentDir,
+		Dir:          *dirFlag,
+		BodyTemplate: body,
+		Now:          time.Now().In(cfg.Location()),
+	})
+	if err != nil {
+		return fmt.Errorf("new: %w", err)
+	}
+	if *dryRun {
+		_, err := os.Stdout.Write(note.Content)
+		return err
+	}
+	target := filepath.Join(contentDir, filepath.FromSlash(note.File))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("new: %w", err)
+	}
+	if _, err := f.Write(note.Content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s\n", target, note.Path)
+	return nil
+}
+
+// newNoteContentDir picks the local directory `notepub new` writes into: the
+// named mount, else the mount whose types_default is typeName, else the first
+// local mount.
+func newNoteContentDir(cfg config.Config, typeName, mountName string) (string, error) {
+	var local []mountutil.Mount
+	for _, m := range mountutil.FromConfig(cfg) {
+		if m.Source == "local" && m.Dir != "" {
+			local = append(local, m)
+		}
+	}
+	if mountName != "" {
+		for _, m := range local {
+			if m.Name == mountName {
+				return m.Dir, nil
+			}
+		}
+		return "", fmt.Errorf("new: no local content mount named %q", mountName)
+	}
+	if len(local) == 0 {
+		return "", fmt.Errorf("new: no local content directory (content.source is not local)")
+	}
+	for _, m := range local {
+		if m.TypesDefault == typeName {
+			return m.Dir, nil
+		}
+	}
+	return local[0].Dir, nil
+}
+
+func initCmd(args []string) error {
+	fs, layout, theme, settingsNotes, force := newInitFlagSet()
+	usage := newInitUsageWriter(fs)
+	// The directory may come before or after the flags.
+	positional := []string{}
+	rest := args
+	for {
+		helped, err := parseFlags(fs, rest, usage)
+		if err != nil {
+			return err
+		}
+		if helped {
+			return nil
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+	if len(positional) > 1 {
+		return usageError("init takes at most one directory", usage)
+	}
+	root := "."
+	if len(positional) == 1 {
+		root = positional[0]
+	}
+	var themeFS iofs.FS
+	if strings.EqualFold(strings.TrimSpace(*theme), "embedded") {
+		embedded, err := serve.EmbeddedTheme()
+		if err != nil {
+			return err
+		}
+		themeFS = embedded
+	}
+	report, err := templateupdate.Init(templateupdate.InitOptions{
+		Root:          root,
+		Layout:        *layout,
+		Theme:         *theme,
+		ThemeFS:       themeFS,
+		SettingsNotes: *settingsNotes,
+		Version:       version,
+		Force:         *force,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	check, err := templateupdate.Check(root)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Print(check)
+	return nil
+}
+
 func templateCmd(args []string) error {
 	if len(args) == 0 {
 		return usageError("missing template subcommand", templateUsageWriter)
@@ -422,6 +937,188 @@ func templateCmd(args []string) error {
 	}
 }
 
+func themeCmd(args []string) error {
+	if len(args) == 0 {
+		return usageError("missing theme subcommand", themeUsageWriter)
+	}
+	subcmd := args[0]
+	subargs := args[1:]
+	switch subcmd {
+	case "-h", "--help", "help":
+		themeUsageWriter(os.Stdout)
+		return nil
+	case "ls":
+		fs, configPath, showAssets := newThemeLsFlagSet()
+		helped, err := parseFlags(fs, subargs, newThemeLsUsageWriter(fs))
+		if err != nil {
+			return err
+		}
+		if helped {
+			return nil
+		}
+		configPathResolved := resolveConfigPath(*configPath)
+		cfg, err := config.Load(configPathResolved)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("config file not found: %s", configPathResolved)
+			}
+			return fmt.Errorf("load config: %w", err)
+		}
+		theme, err := serve.LoadThemeConfig(cfg.Theme)
+		if err != nil {
+			return fmt.Errorf("load theme: %w", err)
+		}
+		return writeThemeListing(os.Stdout, theme, *showAssets)
+	case "check":
+		fs, configPath, rulesPath, resolvePath, strict := newThemeCheckFlagSet()
+		helped, err := parseFlags(fs, subargs, newThemeCheckUsageWriter(fs))
+		if err != nil {
+			return err
+		}
+		if helped {
+			return nil
+		}
+		return themeCheck(os.Stdout, *configPath, *rulesPath, *resolvePath, *strict)
+	default:
+		return usageError(fmt.Sprintf("unknown theme subcommand: %s", subcmd), themeUsageWriter)
+	}
+}
+
+// themeCheck renders the theme against rules.yaml and, when one exists, the
+// resolve index, and prints every finding. Errors fail the command; with
+// strict, warnings do too.
+func themeCheck(w io.Writer, configPath, rulesFlag, resolvePath string, strict bool) error {
+	configPathResolved := resolveConfigPath(configPath)
+	cfg, err := config.Load(configPathResolved)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("config file not found: %s", configPathResolved)
+		}
+		return fmt.Errorf("load config: %w", err)
+	}
+	resolvedRules, err := resolveRulesPath(configPathResolved, cfg.RulesPath, rulesFlag)
+	if err != nil {
+		return err
+	}
+	rulesCfg, err := rules.Load(resolvedRules)
+	if err != nil {
+		return fmt.Errorf("load rules: %w", err)
+	}
+	theme, err := serve.LoadThemeConfig(cfg.Theme)
+	if err != nil {
+		return fmt.Errorf("load theme: %w", err)
+	}
+
+	var idx models.ResolveIndex
+	if resolvePath == "" {
+		candidate := filepath.Join(cfg.Paths.ArtifactsDir, "resolve.json")
+		if _, err := os.Stat(candidate); err == nil {
+			resolvePath = candidate
+		}
+	}
+	if resolvePath != "" {
+		idx, err = validateResolve(resolvePath)
+		if err != nil {
+			return fmt.Errorf("resolve validation: %w", err)
+		}
+	}
+
+	findings := serve.CheckTheme(theme, cfg, rulesCfg, idx)
+	errCount, warnCount := 0, 0
+	for _, f := range findings {
+		label := "WARN"
+		if f.Severity == "error" {
+			label = "ERROR"
+			errCount++
+		} else {
+			warnCount++
+		}
+		where := f.Template
+		if f.Subject != "" {
+			where = strings.TrimSpace(where + " (" + f.Subject + ")")
+		}
+		fmt.Fprintf(w, "[%s] %s %s: %s\n", label, f.Code, where, f.Message)
+	}
+	pages := "synthetic pages only"
+	if resolvePath != "" {
+		pages = "synthetic and indexed pages"
+	}
+	fmt.Fprintf(w, "theme check: %d template(s), %s, %d error(s), %d warning(s)\n", len(theme.Templates()), pages, errCount, warnCount)
+	if errCount > 0 || (strict && warnCount > 0) {
+		return fmt.Errorf("theme check failed")
+	}
+	return nil
+}
+
+// writeThemeListing prints the theme layers and, for each template (and
+// asset), the layer that provides it and the layers it overrides.
+func writeThemeListing(w io.Writer, theme *serve.Theme, showAssets bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Layers (highest priority first):")
+	for i, layer := range theme.Layers() {
+		fmt.Fprintf(tw, "  %d. %s\n", i+1, layer)
+	}
+	section := func(title string, files []serve.ThemeFile) {
+		fmt.Fprintf(tw, "\n%s:\n", title)
+		for _, f := range files {
+			if len(f.Shadowed) == 0 {
+				fmt.Fprintf(tw, "  %s\t%s\n", f.Name, f.Layer)
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\toverrides %s\n", f.Name, f.Layer, strings.Join(f.Shadowed, ", "))
+		}
+	}
+	section("Templates", theme.Templates())
+	if showAssets {
+		section("Assets", theme.Assets())
+	}
+	return tw.Flush()
+}
+
+// validateRulesOnly strictly loads config and rules and lists every problem,
+// one per line, without touching content or artifacts.
+func validateRulesOnly(w io.Writer, configPath, rulesFlag string) error {
+	var problems []string
+	addErr := func(err error) {
+		problems = append(problems, strings.Split(err.Error(), "\n")...)
+	}
+	rulesPath := filepath.Join(filepath.Dir(configPath), "rules.yaml")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("config file not found: %s", configPath)
+		}
+		addErr(err)
+	} else {
+		rulesPath = cfg.RulesPath
+	}
+	resolvedRules, err := resolveRulesPath(configPath, rulesPath, rulesFlag)
+	if err != nil {
+		return err
+	}
+	var warnings []string
+	rulesCfg, err := rules.Load(resolvedRules)
+	if err != nil {
+		addErr(err)
+	} else {
+		warnings, err = indexer.ValidateRules(rulesCfg)
+		if err != nil {
+			addErr(err)
+		}
+	}
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(w, "warning: "+warning)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("rules validation: %d problem(s) in %s and %s", len(problems), configPath, resolvedRules)
+	}
+	log.Println("validate completed")
+	return nil
+}
+
 func validateResolve(path string) (models.ResolveIndex, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -453,13 +1150,41 @@ func validateResolve(path string) (models.ResolveIndex, error) {
 	return idx, nil
 }
 
+// printTypeAssignments lists notes by content key with the rule that set their type.
+func printTypeAssignments(w io.Writer, idx models.ResolveIndex) {
+	type row struct{ key, typ, rule string }
+	rows := make([]row, 0, len(idx.Meta))
+	for p, meta := range idx.Meta {
+		key := idx.Routes[p].S3Key
+		if key == "" {
+			key = p
+		}
+		rows = append(rows, row{key: key, typ: meta.Type, rule: firstNonEmpty(meta.TypeRule, "frontmatter")})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].key < rows[j].key })
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tTYPE\tRULE")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.key, r.typ, r.rule)
+	}
+	tw.Flush()
+}
+
 func usageWriter(w io.Writer) {
 	fmt.Fprintln(w, "notepub index")
 	fmt.Fprintln(w, "notepub serve --addr :8081")
+	fmt.Fprintln(w, "notepub serve --sites sites.yaml")
 	fmt.Fprintln(w, "notepub build --dist ./dist")
 	fmt.Fprintln(w, "notepub validate")
+	fmt.Fprintln(w, "notepub redirects --format netlify")
+	fmt.Fprintln(w, "notepub new <type> \"Title\"")
+	fmt.Fprintln(w, "notepub init [dir] --layout modern")
+	fmt.Fprintln(w, "notepub diff old/resolve.json new/resolve.json")
+	fmt.Fprintln(w, "notepub schema config|rules")
 	fmt.Fprintln(w, "notepub template check")
 	fmt.Fprintln(w, "notepub template update --apply")
+	fmt.Fprintln(w, "notepub theme ls")
+	fmt.Fprintln(w, "notepub theme check")
 	fmt.Fprintln(w, "notepub version")
 }
 
@@ -470,19 +1195,36 @@ func helpCmd(args []string) {
 	}
 	switch args[0] {
 	case "index":
-		fs, _, _ := newIndexFlagSet()
+		fs, _, _, _, _, _, _ := newIndexFlagSet()
 		newIndexUsageWriter(fs)(os.Stdout)
 	case "serve":
-		fs, _, _, _ := newServeFlagSet()
+		fs, _, _, _, _, _ := newServeFlagSet()
 		newServeUsageWriter(fs)(os.Stdout)
 	case "build":
-		fs, _, _, _, _, _, _ := newBuildFlagSet()
+		fs, _, _, _, _, _, _, _ := newBuildFlagSet()
 		newBuildUsageWriter(fs)(os.Stdout)
+	case "redirects":
+		fs, _, _, _, _ := newRedirectsFlagSet()
+		newRedirectsUsageWriter(fs)(os.Stdout)
+	case "new":
+		fs, _, _, _, _, _, _, _ := newNewFlagSet()
+		newNewUsageWriter(fs)(os.Stdout)
+	case "init":
+		fs, _, _, _, _ := newInitFlagSet()
+		newInitUsageWriter(fs)(os.Stdout)
+	case "diff":
+		fs, _, _, _ := newDiffFlagSet()
+		newDiffUsageWriter(fs)(os.Stdout)
+	case "schema":
+		fs, _ := newSchemaFlagSet()
+		newSchemaUsageWriter(fs)(os.Stdout)
 	case "validate":
-		fs, _, _, _, _, _, _, _, _ := newValidateFlagSet()
+		fs, _, _, _, _, _, _, _, _, _, _, _, _ := newValidateFlagSet()
 		newValidateUsageWriter(fs)(os.Stdout)
 	case "template":
 		templateUsageWriter(os.Stdout)
+	case "theme":
+		themeUsageWriter(os.Stdout)
 	default:
 		usageWriter(os.Stdout)
 	}
@@ -492,22 +1234,28 @@ func printVersion() {
 	fmt.Println(version)
 }
 
-func newIndexFlagSet() (*flag.FlagSet, *string, *string) {
+func newIndexFlagSet() (*flag.FlagSet, *string, *string, *string, *bool, *int, *bool) {
 	fs := flag.NewFlagSet("index", flag.ContinueOnError)
 	configPath := fs.String("config", "", "Path to config.yaml")
 	rulesPath := fs.String("rules", "", "Path to rules.yaml (overrides config)")
-	return fs, configPath, rulesPath
+	report := fs.String("report", "text", "Report output on stdout: text|json|github|sarif")
+	keepGoing := fs.Bool("keep-going", false, "Quarantine notes that fail validation and index the rest")
+	maxErrors := fs.Int("max-errors", 0, "With --keep-going, fail when there are more errors than this (0 = no limit)")
+	keepPrevious := fs.Bool("keep-previous", false, "With --keep-going, keep serving a quarantined note's route from the previous resolve.json")
+	return fs, configPath, rulesPath, report, keepGoing, maxErrors, keepPrevious
 }
 
-func newServeFlagSet() (*flag.FlagSet, *string, *string, *string) {
+func newServeFlagSet() (*flag.FlagSet, *string, *string, *string, *string, *bool) {
 	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
 	configPath := fs.String("config", "", "Path to config.yaml")
 	rulesPath := fs.String("rules", "", "Path to rules.yaml (overrides config)")
 	addr := fs.String("addr", "", "HTTP listen address (overrides config)")
-	return fs, configPath, rulesPath, addr
+	sitesPath := fs.String("sites", "", "Path to sites.yaml (serve several sites by Host header)")
+	drafts := fs.Bool("drafts", false, "Serve draft notes as previews (always on in dev mode)")
+	return fs, configPath, rulesPath, addr, sitesPath, drafts
 }
 
-func newBuildFlagSet() (*flag.FlagSet, *string, *string, *string, *string, *bool, *bool) {
+func newBuildFlagSet() (*flag.FlagSet, *string, *string, *string, *string, *bool, *bool, *string) {
 	fs := flag.NewFlagSet("build", flag.ContinueOnError)
 	configPath := fs.String("config", "", "Path to config.yaml")
 	rulesPath := fs.String("rules", "", "Path to rules.yaml (overrides config)")
@@ -515,10 +1263,55 @@ func newBuildFlagSet() (*flag.FlagSet, *string, *string, *string, *string, *bool
 	artifactsDir := fs.String("artifacts", "", "Artifacts directory (resolve.json, sitemap, robots)")
 	noIndex := fs.Bool("no-index", false, "Do not run index if resolve.json is missing")
 	generateSearch := fs.Bool("generate-search", false, "Generate search.json if missing")
-	return fs, configPath, rulesPath, distDir, artifactsDir, noIndex, generateSearch
+	redirectsFormat := fs.String("redirects", "", "Also write the redirect table: netlify|nginx|apache|caddy|json")
+	return fs, configPath, rulesPath, distDir, artifactsDir, noIndex, generateSearch, redirectsFormat
+}
+
+func newNewFlagSet() (*flag.FlagSet, *string, *string, *string, *string, *string, *string, *bool) {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.yaml")
+	rulesPath := fs.String("rules", "", "Path to rules.yaml (overrides config)")
+	resolvePath := fs.String("resolve", "", "Path to resolve.json for slug checks (default: <artifacts>/resolve.json)")
+	slugFlag := fs.String("slug", "", "Slug to use instead of one derived from the title")
+	dir := fs.String("dir", "", "Folder inside the content dir (overrides types.<type>.dir)")
+	mount := fs.String("mount", "", "Local content mount to write into")
+	dryRun := fs.Bool("dry-run", false, "Print the note instead of writing it")
+	return fs, configPath, rulesPath, resolvePath, slugFlag, dir, mount, dryRun
+}
+
+func newInitFlagSet() (*flag.FlagSet, *string, *string, *bool, *bool) {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	layout := fs.String("layout", "modern", "Project layout: modern (.np/) or legacy (root config.yaml)")
+	theme := fs.String("theme", "embedded", "Theme: embedded (export the built-in theme) or blank (empty folders, built-in fallback)")
+	settingsNotes := fs.Bool("settings-notes", true, "Write Site.md and Interface.md override notes")
+	force := fs.Bool("force", false, "Overwrite existing files")
+	return fs, layout, theme, settingsNotes, force
+}
+
+func newDiffFlagSet() (*flag.FlagSet, *string, *string, *bool) {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("format", "text", "Output format: text|json")
+	output := fs.String("output", "", "Write to file instead of stdout")
+	allowLost := fs.Bool("allow-lost", false, "Exit 0 even when URLs disappear without a redirect")
+	return fs, format, output, allowLost
+}
+
+func newSchemaFlagSet() (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	output := fs.String("output", "", "Write to file instead of stdout")
+	return fs, output
 }
 
-func newValidateFlagSet() (*flag.FlagSet, *string, *string, *string, *bool, *bool, *bool, *string, *string) {
+func newRedirectsFlagSet() (*flag.FlagSet, *string, *string, *string, *string) {
+	fs := flag.NewFlagSet("redirects", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.yaml")
+	resolvePath := fs.String("resolve", "", "Path to resolve.json (default: <artifacts>/resolve.json)")
+	format := fs.String("format", "netlify", "Output format: netlify|nginx|apache|caddy|json")
+	output := fs.String("output", "", "Write to file instead of stdout")
+	return fs, configPath, resolvePath, format, output
+}
+
+func newValidateFlagSet() (*flag.FlagSet, *string, *string, *string, *bool, *bool, *bool, *string, *string, *bool, *bool, *string, *bool) {
 	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
 	configPath := fs.String("config", "", "Path to config.yaml")
 	rulesPath := fs.String("rules", "", "Path to rules.yaml (overrides config)")
@@ -526,9 +1319,13 @@ func newValidateFlagSet() (*flag.FlagSet, *string, *string, *string, *bool, *boo
 	validateLinks := fs.Bool("links", false, "Validate links using resolve.json")
 	validateMarkdown := fs.Bool("markdown", false, "Validate markdown diagnostics (wikilinks/embeds/raw html)")
 	markdownStrict := fs.Bool("markdown-strict", false, "Fail on markdown warnings as well as errors")
-	markdownFormat := fs.String("markdown-format", "text", "Markdown diagnostics output format: text|json")
+	markdownFormat := fs.String("markdown-format", "text", "Diagnostics output format: text|json|sarif|github")
 	markdownOutput := fs.String("output", "", "Write markdown diagnostics output to file path")
-	return fs, configPath, rulesPath, resolvePath, validateLinks, validateMarkdown, markdownStrict, markdownFormat, markdownOutput
+	showTypes := fs.Bool("types", false, "Print each note's type and the rule that assigned it")
+	validateExternal := fs.Bool("external", false, "Check absolute http(s) links in notes (cached in paths.cache_root)")
+	distDir := fs.String("dist", "", "Check links, assets and anchors in built HTML under this directory")
+	rulesOnly := fs.Bool("rules-only", false, "Only check config and rules (unknown keys, cross-references) and list every problem")
+	return fs, configPath, rulesPath, resolvePath, validateLinks, validateMarkdown, markdownStrict, markdownFormat, markdownOutput, showTypes, validateExternal, distDir, rulesOnly
 }
 
 func newTemplateCheckFlagSet() (*flag.FlagSet, *string) {
@@ -544,17 +1341,113 @@ func newTemplateUpdateFlagSet() (*flag.FlagSet, *string, *bool) {
 	return fs, root, apply
 }
 
+func newThemeCheckFlagSet() (*flag.FlagSet, *string, *string, *string, *bool) {
+	fs := flag.NewFlagSet("theme check", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.yaml")
+	rulesPath := fs.String("rules", "", "Path to rules.yaml (overrides config)")
+	resolvePath := fs.String("resolve", "", "Path to resolve.json (default: <artifacts_dir>/resolve.json when present)")
+	strict := fs.Bool("strict", false, "Fail on warnings too")
+	return fs, configPath, rulesPath, resolvePath, strict
+}
+
+func newThemeLsFlagSet() (*flag.FlagSet, *string, *bool) {
+	fs := flag.NewFlagSet("theme ls", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.yaml")
+	showAssets := fs.Bool("assets", false, "Also list assets")
+	return fs, configPath, showAssets
+}
+
 func normalizeMarkdownFormat(v string) string {
 	switch strings.ToLower(strings.TrimSpace(v)) {
 	case "", "text":
 		return "text"
 	case "json":
 		return "json"
+	case "sarif":
+		return "sarif"
+	case "github":
+		return "github"
 	default:
 		return ""
 	}
 }
 
+// renderIndexReport renders an index run for `index --report`.
+func renderIndexReport(report indexer.Report, format string, opts diagfmt.Options) ([]byte, error) {
+	if rendered, ok, err := renderCIDiagnostics(report.Diagnostics, format, opts); ok || err != nil {
+		return rendered, err
+	}
+	if format != "json" {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	errCount, warnCount := indexer.CountDiagnostics(report.Diagnostics)
+	payload := struct {
+		Diagnostics []indexer.MarkdownDiagnostic `json:"diagnostics"`
+		Summary     struct {
+			Added       int `json:"added"`
+			Changed     int `json:"changed"`
+			Removed     int `json:"removed"`
+			Unchanged   int `json:"unchanged"`
+			Quarantined int `json:"quarantined"`
+			Errors      int `json:"errors"`
+			Warnings    int `json:"warnings"`
+		} `json:"summary"`
+		Quarantined []indexer.QuarantinedNote `json:"quarantined,omitempty"`
+	}{
+		Diagnostics: report.Diagnostics,
+		Quarantined: report.Quarantined,
+	}
+	payload.Summary.Added = report.Added
+	payload.Summary.Changed = report.Changed
+	payload.Summary.Removed = report.Removed
+	payload.Summary.Unchanged = report.Unchanged
+	payload.Summary.Quarantined = len(report.Quarantined)
+	payload.Summary.Errors = errCount
+	payload.Summary.Warnings = warnCount
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(payload); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// renderCIDiagnostics renders the sarif and github formats; ok is false for
+// formats the caller renders itself.
+func renderCIDiagnostics(diags []indexer.MarkdownDiagnostic, format string, opts diagfmt.Options) ([]byte, bool, error) {
+	var b strings.Builder
+	switch format {
+	case "sarif":
+		if err := diagfmt.WriteSARIF(&b, diags, opts); err != nil {
+			return nil, true, err
+		}
+	case "github":
+		if err := diagfmt.WriteGitHub(&b, diags, opts); err != nil {
+			return nil, true, err
+		}
+	default:
+		return nil, false, nil
+	}
+	return []byte(b.String()), true, nil
+}
+
+// contentDiagOptions maps content keys to paths under their local mount dir so
+// annotations land on the markdown files in the repository.
+func contentDiagOptions(cfg config.Config) diagfmt.Options {
+	reader := mountutil.NewReader(cfg, nil)
+	return diagfmt.Options{
+		Version: version,
+		FilePath: func(key string) string {
+			m, raw, ok := reader.Lookup(key)
+			if !ok || m.Source != "local" || m.Dir == "" {
+				return key
+			}
+			return filepath.ToSlash(filepath.Join(m.Dir, filepath.FromSlash(raw)))
+		},
+	}
+}
+
 func renderMarkdownDiagnostics(diags []indexer.MarkdownDiagnostic, caps indexer.MarkdownCapabilities, format string) ([]byte, error) {
 	switch format {
 	case "text":
@@ -603,6 +1496,91 @@ func renderMarkdownDiagnostics(diags []indexer.MarkdownDiagnostic, caps indexer.
 	}
 }
 
+// renderDiagnostics renders diagnostics without the markdown capabilities report.
+func renderDiagnostics(diags []indexer.MarkdownDiagnostic, format string) ([]byte, error) {
+	switch format {
+	case "text":
+		var b strings.Builder
+		for _, d := range diags {
+			b.WriteString(fmt.Sprintf("[%s] %s %s:%d %s\n", strings.ToUpper(d.Severity), d.Code, d.File, d.Line, d.Message))
+		}
+		return []byte(b.String()), nil
+	case "json":
+		errCount, warnCount := indexer.CountDiagnostics(diags)
+		payload := struct {
+			Diagnostics []indexer.MarkdownDiagnostic `json:"diagnostics"`
+			Summary     struct {
+				Errors   int `json:"errors"`
+				Warnings int `json:"warnings"`
+			} `json:"summary"`
+		}{
+			Diagnostics: diags,
+		}
+		payload.Summary.Errors = errCount
+		payload.Summary.Warnings = warnCount
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(payload); err != nil {
+			return nil, err
+		}
+		return []byte(b.String()), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// renderDistReport groups dist diagnostics by page.
+func renderDistReport(diags []indexer.MarkdownDiagnostic, format string) ([]byte, error) {
+	pages := []string{}
+	byPage := map[string][]indexer.MarkdownDiagnostic{}
+	for _, d := range diags {
+		if _, ok := byPage[d.File]; !ok {
+			pages = append(pages, d.File)
+		}
+		byPage[d.File] = append(byPage[d.File], d)
+	}
+	sort.Strings(pages)
+	switch format {
+	case "text":
+		var b strings.Builder
+		for _, page := range pages {
+			b.WriteString(page + "\n")
+			for _, d := range byPage[page] {
+				b.WriteString(fmt.Sprintf("  [%s] %s line %d: %s\n", strings.ToUpper(d.Severity), d.Code, d.Line, d.Message))
+			}
+		}
+		b.WriteString(fmt.Sprintf("%d issue(s) in %d page(s)\n", len(diags), len(pages)))
+		return []byte(b.String()), nil
+	case "json":
+		type pageReport struct {
+			Page        string                       `json:"page"`
+			Diagnostics []indexer.MarkdownDiagnostic `json:"diagnostics"`
+		}
+		payload := struct {
+			Pages   []pageReport `json:"pages"`
+			Summary struct {
+				Pages  int `json:"pages"`
+				Issues int `json:"issues"`
+			} `json:"summary"`
+		}{Pages: []pageReport{}}
+		for _, page := range pages {
+			payload.Pages = append(payload.Pages, pageReport{Page: page, Diagnostics: byPage[page]})
+		}
+		payload.Summary.Pages = len(pages)
+		payload.Summary.Issues = len(diags)
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(payload); err != nil {
+			return nil, err
+		}
+		return []byte(b.String()), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
 func capabilityNames(caps indexer.MarkdownCapabilities) []string {
 	names := make([]string, 0, len(caps.Supported))
 	for name := range caps.Supported {
@@ -612,6 +1590,55 @@ func capabilityNames(caps indexer.MarkdownCapabilities) []string {
 	return names
 }
 
+// validateOutput collects the reports of one validate run so they are written
+// once, in order, instead of each report overwriting the previous one.
+type validateOutput struct {
+	format   string
+	sets     []diagfmt.Set
+	text     []byte
+	reported bool
+	failure  error
+}
+
+// add records diags for CI formats, or appends render's output for text and
+// json.
+func (o *validateOutput) add(diags []indexer.MarkdownDiagnostic, opts diagfmt.Options, render func() ([]byte, error)) error {
+	o.reported = true
+	if o.format == "sarif" || o.format == "github" {
+		o.sets = append(o.sets, diagfmt.Set{Diags: diags, Options: opts})
+		return nil
+	}
+	rendered, err := render()
+	if err != nil {
+		return err
+	}
+	o.text = append(o.text, rendered...)
+	return nil
+}
+
+// fail keeps the first report failure; it is returned after the output is
+// written.
+func (o *validateOutput) fail(err error) {
+	if o.failure == nil {
+		o.failure = err
+	}
+}
+
+func (o *validateOutput) write(filePath string) error {
+	if !o.reported {
+		return nil
+	}
+	rendered := o.text
+	if len(o.sets) > 0 {
+		var err error
+		rendered, _, err = renderCIDiagnostics(diagfmt.Merge(o.sets...), o.format, diagfmt.Options{Version: version})
+		if err != nil {
+			return err
+		}
+	}
+	return writeMarkdownDiagnostics(rendered, filePath)
+}
+
 func writeMarkdownDiagnostics(out []byte, filePath string) error {
 	if strings.TrimSpace(filePath) == "" {
 		_, err := os.Stdout.Write(out)
@@ -632,6 +1659,7 @@ func newServeUsageWriter(fs *flag.FlagSet) func(io.Writer) {
 	return func(w io.Writer) {
 		fs.SetOutput(w)
 		fmt.Fprintln(w, "notepub serve --addr :8081")
+		fmt.Fprintln(w, "notepub serve --sites sites.yaml")
 		fs.PrintDefaults()
 	}
 }
@@ -644,6 +1672,46 @@ func newBuildUsageWriter(fs *flag.FlagSet) func(io.Writer) {
 	}
 }
 
+func newRedirectsUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub redirects --format netlify --output dist/_redirects")
+		fs.PrintDefaults()
+	}
+}
+
+func newNewUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub new <type> \"Title\" [--slug my-slug] [--dir folder]")
+		fs.PrintDefaults()
+	}
+}
+
+func newInitUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub init [dir] [--layout modern|legacy] [--theme embedded|blank]")
+		fs.PrintDefaults()
+	}
+}
+
+func newDiffUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub diff old/resolve.json new/resolve.json [--format json]")
+		fs.PrintDefaults()
+	}
+}
+
+func newSchemaUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub schema config|rules [--output rules.schema.json]")
+		fs.PrintDefaults()
+	}
+}
+
 func newValidateUsageWriter(fs *flag.FlagSet) func(io.Writer) {
 	return func(w io.Writer) {
 		fs.SetOutput(w)
@@ -673,6 +1741,27 @@ func newTemplateUpdateUsageWriter(fs *flag.FlagSet) func(io.Writer) {
 	}
 }
 
+func themeUsageWriter(w io.Writer) {
+	fmt.Fprintln(w, "notepub theme ls [--assets]")
+	fmt.Fprintln(w, "notepub theme check [--resolve path] [--strict]")
+}
+
+func newThemeCheckUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub theme check [--resolve path] [--strict]")
+		fs.PrintDefaults()
+	}
+}
+
+func newThemeLsUsageWriter(fs *flag.FlagSet) func(io.Writer) {
+	return func(w io.Writer) {
+		fs.SetOutput(w)
+		fmt.Fprintln(w, "notepub theme ls [--assets]")
+		fs.PrintDefaults()
+	}
+}
+
 type usageErr struct {
 	msg   string
 	usage func(io.Writer)
@@ -755,6 +1844,15 @@ func resolveConfigPath(flagPath string) string {
 	return "config.yaml"
 }
 
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func validateRulesPath(path string) (string, error) {
 	info, err := os.Stat(path)
 	if err != nil {
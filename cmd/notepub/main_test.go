@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cookiespooky/notepub/internal/diagfmt"
 	"github.com/cookiespooky/notepub/internal/indexer"
 )
 
@@ -80,6 +81,42 @@ validation:
 	})
 }
 
+func TestValidateRulesOnlyListsWarnings(t *testing.T) {
+	withTempDir(t, func() {
+		cfg := `site:
+  base_url: "https://example.com"
+s3:
+  bucket: "bucket"
+  access_key: "ak"
+  secret_key: "sk"
+`
+		rules := `version: 1
+types:
+  page:
+    template: "page.html"
+    permalink: "/{{ slug }}/"
+collections:
+  ordered:
+    kind: "filter"
+    sort:
+      by: "fm.order"
+`
+		if err := os.WriteFile("config.yaml", []byte(cfg), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := os.WriteFile("rules.yaml", []byte(rules), 0o644); err != nil {
+			t.Fatalf("write rules: %v", err)
+		}
+		var b strings.Builder
+		if err := validateRulesOnly(&b, "config.yaml", "rules.yaml"); err != nil {
+			t.Fatalf("validateRulesOnly: %v", err)
+		}
+		if !strings.Contains(b.String(), `warning: rules.yaml:10: collections.ordered.sort.by: frontmatter field "order" is not declared`) {
+			t.Fatalf("warning not listed: %q", b.String())
+		}
+	})
+}
+
 func withTempDir(t *testing.T, fn func()) {
 	t.Helper()
 	dir := t.TempDir()
@@ -173,3 +210,31 @@ func TestRenderMarkdownDiagnosticsText(t *testing.T) {
 		t.Fatalf("capabilities section is missing: %q", string(b))
 	}
 }
+
+func TestValidateOutputWritesOneGitHubStream(t *testing.T) {
+	withTempDir(t, func() {
+		out := &validateOutput{format: "github"}
+		md := []indexer.MarkdownDiagnostic{{Code: "NP-MD-WIKI-MISSING", Severity: "error", File: "a.md", Line: 2, Message: "missing"}}
+		dist := []indexer.MarkdownDiagnostic{{Code: "NP-DIST-MISSING", Severity: "error", File: "index.html", Message: "gone"}}
+		noRender := func() ([]byte, error) { t.Fatal("CI formats must not render per report"); return nil, nil }
+		if err := out.add(md, diagfmt.Options{FilePath: func(f string) string { return "content/" + f }}, noRender); err != nil {
+			t.Fatalf("add markdown: %v", err)
+		}
+		if err := out.add(dist, diagfmt.Options{FilePath: func(f string) string { return "dist/" + f }}, noRender); err != nil {
+			t.Fatalf("add dist: %v", err)
+		}
+		p := filepath.Join(".", "diag.txt")
+		if err := out.write(p); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		want := "::error file=content/a.md,line=2,title=NP-MD-WIKI-MISSING::missing\n" +
+			"::error file=dist/index.html,title=NP-DIST-MISSING::gone\n"
+		if string(data) != want {
+			t.Fatalf("unexpected output:\n%s", data)
+		}
+	})
+}
@@ -6,9 +6,13 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+	// Embedded zone database so site.timezone works on minimal images.
+	_ "time/tzdata"
 
-	"gopkg.in/yaml.v3"
+	"github.com/cookiespooky/notepub/internal/yamlutil"
 )
 
 const (
@@ -20,6 +24,11 @@ const (
 	defaultLocalDir   = "markdown"
 )
 
+var (
+	mountNameRe      = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	mountNameCleanRe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
 type Config struct {
 	CompatMode   string            `yaml:"compat_mode"`
 	Site         SiteConfig        `yaml:"site"`
@@ -37,6 +46,7 @@ type Config struct {
 	Media        MediaConfig       `yaml:"media"`
 	RulesPath    string            `yaml:"rules_path"`
 	Settings     map[string]string `yaml:"settings"`
+	Languages    []LanguageConfig  `yaml:"languages"`
 }
 
 type SiteConfig struct {
@@ -48,6 +58,7 @@ type SiteConfig struct {
 	MediaBaseURL   string   `yaml:"media_base_url"`
 	Host           string   `yaml:"host"`
 	HostAliases    []string `yaml:"host_aliases"`
+	Timezone       string   `yaml:"timezone"`
 }
 
 type RuntimeConfig struct {
@@ -79,8 +90,20 @@ type S3Config struct {
 }
 
 type ContentConfig struct {
-	Source   string `yaml:"source"`
-	LocalDir string `yaml:"local_dir"`
+	Source     string         `yaml:"source"`
+	LocalDir   string         `yaml:"local_dir"`
+	Mounts     []ContentMount `yaml:"mounts"`
+	Precedence []string       `yaml:"precedence"`
+}
+
+type ContentMount struct {
+	Name         string `yaml:"name"`
+	Source       string `yaml:"source"`
+	Dir          string `yaml:"dir"`
+	Bucket       string `yaml:"bucket"`
+	Prefix       string `yaml:"prefix"`
+	MountPath    string `yaml:"mount_path"`
+	TypesDefault string `yaml:"types_default"`
 }
 
 type MarkdownConfig struct {
@@ -99,6 +122,9 @@ type ThemeConfig struct {
 	Name            string `yaml:"name"`
 	TemplatesSubdir string `yaml:"templates_subdir"`
 	AssetsSubdir    string `yaml:"assets_subdir"`
+	// Extends names a parent theme in Dir that supplies missing templates
+	// and assets; "none" disables the embedded fallback.
+	Extends string `yaml:"extends"`
 }
 
 type RobotsConfig struct {
@@ -113,6 +139,8 @@ type CacheConfig struct {
 
 type ServerConfig struct {
 	Listen string `yaml:"listen"`
+	// Drafts serves notes with `draft: true` as previews (also on in dev mode).
+	Drafts bool `yaml:"drafts"`
 }
 
 type MediaConfig struct {
@@ -131,7 +159,7 @@ func Load(path string) (Config, error) {
 		return Config{}, fmt.Errorf("read config %s: %w", path, err)
 	}
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if _, err := yamlutil.DecodeStrict(path, data, &cfg); err != nil {
 		return Config{}, fmt.Errorf("parse config: %w", err)
 	}
 	if cfg.RulesPath == "" {
@@ -164,6 +192,14 @@ func Load(path string) (Config, error) {
 	cfg.Site.MediaBaseURL = normalizeBaseURL(cfg.Site.MediaBaseURL)
 	cfg.S3.Prefix = normalizePrefix(cfg.S3.Prefix)
 	cfg.Content.Source = strings.ToLower(strings.TrimSpace(cfg.Content.Source))
+	if len(cfg.Content.Mounts) > 0 {
+		if err := normalizeMounts(&cfg, filepath.Dir(path)); err != nil {
+			return Config{}, err
+		}
+		cfg.Content.Source = "mounts"
+		// Keys are namespaced per mount and carry the mount prefix themselves.
+		cfg.S3.Prefix = ""
+	}
 	if cfg.Content.Source == "" {
 		if cfg.S3.Bucket == "" {
 			cfg.Content.Source = "local"
@@ -183,6 +219,12 @@ func Load(path string) (Config, error) {
 	if cfg.Site.BaseURL == "" {
 		return Config{}, fmt.Errorf("site.base_url is required")
 	}
+	cfg.Site.Timezone = strings.TrimSpace(cfg.Site.Timezone)
+	if cfg.Site.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Site.Timezone); err != nil {
+			return Config{}, fmt.Errorf("site.timezone: %w", err)
+		}
+	}
 	switch cfg.Content.Source {
 	case "s3":
 		if cfg.S3.Bucket == "" {
@@ -192,19 +234,99 @@ func Load(path string) (Config, error) {
 		if cfg.Content.LocalDir == "" {
 			return Config{}, fmt.Errorf("content.local_dir is required for local source")
 		}
+	case "mounts":
 	default:
 		return Config{}, fmt.Errorf("content.source must be \"s3\" or \"local\"")
 	}
-	if cfg.Content.Source == "s3" {
+	if cfg.Content.Source == "s3" || hasS3Mount(cfg.Content.Mounts) {
 		if (cfg.S3.AccessKey == "" && cfg.S3.SecretKey != "") || (cfg.S3.AccessKey != "" && cfg.S3.SecretKey == "") {
 			return Config{}, fmt.Errorf("s3.access_key and s3.secret_key must be set together")
 		}
 	}
 	cfg.Markdown.HTMLPolicy = normalizeHTMLPolicy(cfg.Markdown.HTMLPolicy)
 	finalizeSettings(&cfg)
+	if err := normalizeLanguages(&cfg, filepath.Dir(path)); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
 
+func normalizeMounts(cfg *Config, configDir string) error {
+	seen := map[string]bool{}
+	for i := range cfg.Content.Mounts {
+		m := &cfg.Content.Mounts[i]
+		m.MountPath = strings.Trim(strings.TrimSpace(filepath.ToSlash(m.MountPath)), "/")
+		m.Name = strings.TrimSpace(m.Name)
+		if m.Name == "" {
+			m.Name = defaultMountName(*m, i)
+		}
+		if !mountNameRe.MatchString(m.Name) {
+			return fmt.Errorf("content.mounts[%d].name %q must match %s", i, m.Name, mountNameRe.String())
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("content.mounts[%d].name %q is duplicated", i, m.Name)
+		}
+		seen[m.Name] = true
+		m.Source = strings.ToLower(strings.TrimSpace(m.Source))
+		if m.Source == "" {
+			if m.Bucket != "" {
+				m.Source = "s3"
+			} else {
+				m.Source = "local"
+			}
+		}
+		m.Prefix = normalizePrefix(m.Prefix)
+		m.TypesDefault = strings.TrimSpace(m.TypesDefault)
+		switch m.Source {
+		case "local":
+			if strings.TrimSpace(m.Dir) == "" {
+				return fmt.Errorf("content.mounts[%d].dir is required for local source", i)
+			}
+			if !filepath.IsAbs(m.Dir) {
+				m.Dir = filepath.Join(configDir, m.Dir)
+			}
+			m.Dir = filepath.Clean(m.Dir)
+		case "s3":
+			if m.Bucket == "" {
+				m.Bucket = cfg.S3.Bucket
+			}
+			if m.Bucket == "" {
+				return fmt.Errorf("content.mounts[%d].bucket is required for s3 source", i)
+			}
+		default:
+			return fmt.Errorf("content.mounts[%d].source must be \"s3\" or \"local\"", i)
+		}
+	}
+	for _, name := range cfg.Content.Precedence {
+		if !seen[strings.TrimSpace(name)] {
+			return fmt.Errorf("content.precedence references unknown mount %q", name)
+		}
+	}
+	return nil
+}
+
+func defaultMountName(m ContentMount, i int) string {
+	candidate := m.MountPath
+	if candidate == "" && m.Dir != "" {
+		candidate = filepath.Base(filepath.Clean(m.Dir))
+	}
+	candidate = strings.ToLower(mountNameCleanRe.ReplaceAllString(candidate, "-"))
+	candidate = strings.Trim(candidate, "-")
+	if candidate == "" || candidate == "." {
+		return fmt.Sprintf("mount%d", i+1)
+	}
+	return candidate
+}
+
+func hasS3Mount(mounts []ContentMount) bool {
+	for _, m := range mounts {
+		if m.Source == "s3" {
+			return true
+		}
+	}
+	return false
+}
+
 func applyDefaults(cfg *Config) {
 	if cfg.CompatMode == "" {
 		cfg.CompatMode = "auto"
@@ -263,6 +385,23 @@ func applyDefaults(cfg *Config) {
 	}
 }
 
+// DraftPreview reports whether serve should route draft notes.
+func (c Config) DraftPreview() bool {
+	return c.Server.Drafts || c.Runtime.Mode == "dev"
+}
+
+// Location returns site.timezone, used for frontmatter dates without an offset.
+func (c Config) Location() *time.Location {
+	if c.Site.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(c.Site.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 func normalizeBaseURL(baseURL string) string {
 	baseURL = strings.TrimSpace(baseURL)
 	baseURL = strings.TrimRight(baseURL, "/")
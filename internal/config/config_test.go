@@ -492,3 +492,179 @@ content:
 		t.Fatalf("site title = %q, want From Note", cfg.Site.Title)
 	}
 }
+
+func TestLoadContentMounts(t *testing.T) {
+	cfgPath := writeTempConfig(t, `site:
+  base_url: "https://example.com"
+s3:
+  bucket: "shared"
+  prefix: "legacy"
+content:
+  mounts:
+    - dir: "./garden"
+      mount_path: "/garden/"
+      types_default: "note"
+    - name: "docs"
+      source: "s3"
+      prefix: "docs"
+  precedence: ["docs"]
+`)
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Content.Source != "mounts" {
+		t.Fatalf("source = %q, want mounts", cfg.Content.Source)
+	}
+	if cfg.S3.Prefix != "" {
+		t.Fatalf("s3.prefix = %q, want empty in mounts mode", cfg.S3.Prefix)
+	}
+	garden := cfg.Content.Mounts[0]
+	if garden.Name != "garden" || garden.Source != "local" || garden.MountPath != "garden" {
+		t.Fatalf("unexpected garden mount: %+v", garden)
+	}
+	if garden.Dir != filepath.Join(filepath.Dir(cfgPath), "garden") {
+		t.Fatalf("garden dir = %q", garden.Dir)
+	}
+	docs := cfg.Content.Mounts[1]
+	if docs.Bucket != "shared" || docs.Prefix != "docs/" {
+		t.Fatalf("unexpected docs mount: %+v", docs)
+	}
+}
+
+func TestLoadContentMountsRejectsUnknownPrecedence(t *testing.T) {
+	cfgPath := writeTempConfig(t, `site:
+  base_url: "https://example.com"
+content:
+  mounts:
+    - name: "a"
+      dir: "./a"
+  precedence: ["b"]
+`)
+	_, err := Load(cfgPath)
+	if err == nil || !strings.Contains(err.Error(), "unknown mount") {
+		t.Fatalf("expected unknown mount error, got %v", err)
+	}
+}
+
+func TestLoadSitesResolvesRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sites.yaml")
+	content := `listen: ":8080"
+default: blog
+sites:
+  - config: blog/config.yaml
+  - config: /abs/docs.yaml
+    rules: docs/rules.yaml
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write sites: %v", err)
+	}
+	sites, err := LoadSites(path)
+	if err != nil {
+		t.Fatalf("LoadSites: %v", err)
+	}
+	if sites.Listen != ":8080" || sites.Default != "blog" || len(sites.Sites) != 2 {
+		t.Fatalf("unexpected sites: %+v", sites)
+	}
+	if sites.Sites[0].Config != filepath.Join(dir, "blog", "config.yaml") {
+		t.Fatalf("config path = %q", sites.Sites[0].Config)
+	}
+	if sites.Sites[1].Config != "/abs/docs.yaml" || sites.Sites[1].Rules != filepath.Join(dir, "docs", "rules.yaml") {
+		t.Fatalf("unexpected second site: %+v", sites.Sites[1])
+	}
+}
+
+func TestLoadSitesRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sites.yaml")
+	content := `sites:
+  - config: blog/config.yaml
+    rule: blog/rules.yaml
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write sites: %v", err)
+	}
+	_, err := LoadSites(path)
+	if err == nil {
+		t.Fatalf("expected unknown key error")
+	}
+	if !strings.Contains(err.Error(), path+":3: unknown key \"rule\"") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadLanguagesResolvesPrefixesAndSettings(t *testing.T) {
+	cfgPath := writeTempConfig(t, `site:
+  base_url: "https://example.com"
+settings:
+  site_name: "Garden"
+  footer: "Shared footer"
+languages:
+  - code: "EN"
+    name: "English"
+  - code: "ru"
+    settings:
+      site_name: "Сад"
+  - code: "de"
+    prefix: "deutsch/"
+`)
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DefaultLanguage() != "en" {
+		t.Fatalf("default language = %q, want en", cfg.DefaultLanguage())
+	}
+	wantPrefixes := []string{"", "/ru", "/deutsch"}
+	for i, want := range wantPrefixes {
+		if got := cfg.Languages[i].Prefix; got != want {
+			t.Fatalf("languages[%d].prefix = %q, want %q", i, got, want)
+		}
+	}
+	ru := cfg.SettingsFor("ru")
+	if ru["site_name"] != "Сад" || ru["footer"] != "Shared footer" || ru["site_language"] != "ru" {
+		t.Fatalf("unexpected ru settings: %#v", ru)
+	}
+	if cfg.Settings["site_name"] != "Garden" {
+		t.Fatalf("site settings mutated: %#v", cfg.Settings)
+	}
+	if got := cfg.SettingsFor("fr")["site_name"]; got != "Garden" {
+		t.Fatalf("unknown language settings = %q, want site fallback", got)
+	}
+}
+
+func TestLoadLanguagesRejectsDuplicatePrefix(t *testing.T) {
+	cfgPath := writeTempConfig(t, `site:
+  base_url: "https://example.com"
+languages:
+  - code: "en"
+  - code: "ru"
+    prefix: "/es"
+  - code: "es"
+`)
+	_, err := Load(cfgPath)
+	if err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("expected duplicate prefix error, got %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownKeysWithSuggestion(t *testing.T) {
+	cfgPath := writeTempConfig(t, `site:
+  base_url: "https://example.com/"
+  titel: "Docs"
+content:
+  source: "local"
+  local-dir: "./content"
+`)
+	_, err := Load(cfgPath)
+	if err == nil {
+		t.Fatalf("expected unknown key error")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, cfgPath+":3: unknown key \"titel\" in site (did you mean \"title\"?)") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(msg, cfgPath+":6: unknown key \"local-dir\" in content (did you mean \"local_dir\"?)") {
+		t.Fatalf("second unknown key not reported: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var langCodeRe = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+type LanguageConfig struct {
+	Code          string            `yaml:"code"`
+	Name          string            `yaml:"name"`
+	Prefix        string            `yaml:"prefix"`
+	InterfaceNote string            `yaml:"interface_note"`
+	Settings      map[string]string `yaml:"settings"`
+}
+
+// normalizeLanguages validates languages and resolves per-language settings.
+// The first language is the default; it has no URL prefix unless one is set.
+func normalizeLanguages(cfg *Config, configDir string) error {
+	seenCodes := map[string]bool{}
+	seenPrefixes := map[string]string{}
+	for i := range cfg.Languages {
+		lang := &cfg.Languages[i]
+		lang.Code = strings.ToLower(strings.TrimSpace(lang.Code))
+		if !langCodeRe.MatchString(lang.Code) {
+			return fmt.Errorf("languages[%d].code %q is not a valid language code", i, lang.Code)
+		}
+		if seenCodes[lang.Code] {
+			return fmt.Errorf("languages[%d].code %q is duplicated", i, lang.Code)
+		}
+		seenCodes[lang.Code] = true
+		if strings.TrimSpace(lang.Name) == "" {
+			lang.Name = lang.Code
+		}
+		prefix := strings.Trim(strings.TrimSpace(lang.Prefix), "/")
+		if prefix == "" && i > 0 {
+			prefix = lang.Code
+		}
+		if prefix != "" {
+			prefix = "/" + prefix
+		}
+		if other, ok := seenPrefixes[prefix]; ok {
+			return fmt.Errorf("languages[%d].prefix %q is already used by %q", i, prefix, other)
+		}
+		seenPrefixes[prefix] = lang.Code
+		lang.Prefix = prefix
+
+		settings := cloneStringMap(cfg.Settings)
+		if strings.TrimSpace(lang.InterfaceNote) != "" && cfg.CompatMode != "legacy" {
+			props, err := readNoteFrontmatter(configDir, lang.InterfaceNote)
+			if err != nil {
+				if cfg.Overrides.Strict {
+					return fmt.Errorf("languages[%d] interface overrides: %w", i, err)
+				}
+				warnOverride("languages."+lang.Code+".interface_note", err)
+			}
+			mergeStringMap(settings, props)
+		}
+		mergeStringMap(settings, lang.Settings)
+		settings["site_language"] = lang.Code
+		lang.Settings = settings
+	}
+	return nil
+}
+
+// DefaultLanguage returns the code of the first configured language, or "".
+func (c Config) DefaultLanguage() string {
+	if len(c.Languages) == 0 {
+		return ""
+	}
+	return c.Languages[0].Code
+}
+
+func (c Config) Language(code string) (LanguageConfig, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for _, lang := range c.Languages {
+		if lang.Code == code {
+			return lang, true
+		}
+	}
+	return LanguageConfig{}, false
+}
+
+// SettingsFor returns settings for a language, falling back to site settings.
+func (c Config) SettingsFor(code string) map[string]string {
+	if lang, ok := c.Language(code); ok && lang.Settings != nil {
+		return lang.Settings
+	}
+	return c.Settings
+}
+
+func cloneStringMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func mergeStringMap(dst, src map[string]string) {
+	for key, val := range src {
+		if strings.TrimSpace(key) == "" || strings.TrimSpace(val) == "" {
+			continue
+		}
+		dst[key] = val
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/yamlutil"
+)
+
+// SitesConfig describes several site configs served from one process.
+type SitesConfig struct {
+	Listen  string      `yaml:"listen"`
+	Default string      `yaml:"default"`
+	Sites   []SiteEntry `yaml:"sites"`
+}
+
+type SiteEntry struct {
+	Config string `yaml:"config"`
+	Rules  string `yaml:"rules"`
+}
+
+// LoadSites reads sites.yaml; config and rules paths are resolved relative to it.
+func LoadSites(path string) (SitesConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SitesConfig{}, fmt.Errorf("read sites %s: %w", path, err)
+	}
+	var sites SitesConfig
+	if _, err := yamlutil.DecodeStrict(path, data, &sites); err != nil {
+		return SitesConfig{}, fmt.Errorf("parse sites: %w", err)
+	}
+	sites.Listen = strings.TrimSpace(sites.Listen)
+	sites.Default = strings.TrimSpace(sites.Default)
+	if len(sites.Sites) == 0 {
+		return SitesConfig{}, fmt.Errorf("sites: at least one site is required")
+	}
+	baseDir := filepath.Dir(path)
+	for i := range sites.Sites {
+		entry := &sites.Sites[i]
+		entry.Config = strings.TrimSpace(entry.Config)
+		entry.Rules = strings.TrimSpace(entry.Rules)
+		if entry.Config == "" {
+			return SitesConfig{}, fmt.Errorf("sites[%d].config is required", i)
+		}
+		if !filepath.IsAbs(entry.Config) {
+			entry.Config = filepath.Join(baseDir, entry.Config)
+		}
+		if entry.Rules != "" && !filepath.IsAbs(entry.Rules) {
+			entry.Rules = filepath.Join(baseDir, entry.Rules)
+		}
+	}
+	return sites, nil
+}
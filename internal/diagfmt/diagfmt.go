@@ -0,0 +1,280 @@
+// Package diagfmt renders diagnostics for CI: SARIF for code-scanning
+// dashboards and GitHub Actions workflow commands for inline annotations.
+package diagfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/indexer"
+)
+
+const (
+	toolName      = "notepub"
+	toolInfoURI   = "https://github.com/cookiespooky/notepub"
+	codesHelpURI  = "https://github.com/cookiespooky/notepub#diagnostic-codes"
+	sarifSchema   = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion  = "2.1.0"
+	genericRuleID = "NP-UNKNOWN"
+)
+
+// Rule describes one diagnostic code. HelpURI points at the code's row in the
+// README "Diagnostic codes" table, or at the table for unknown codes.
+type Rule struct {
+	ID          string
+	Description string
+	HelpURI     string
+}
+
+// Rules is the catalogue of diagnostic codes emitted by notepub.
+var Rules = map[string]string{
+	"NP-MD-WIKI-MISSING":             "Wikilink target does not match any note.",
+	"NP-MD-EMBED-MISSING":            "Embed target does not match any note or media file.",
+	"NP-MD-WIKI-AMBIGUOUS":           "Wikilink matches more than one note; qualify it with a path.",
+	"NP-MD-WIKI-UNRESOLVED":          "Wikilink could not be resolved.",
+	"NP-MD-HTML-SANITIZED":           "Raw HTML was changed by markdown.html_policy: safe.",
+	"NP-MD-RAW-HTML":                 "Raw HTML is used in markdown.",
+	"NP-MD-RAW-HTML-UNSAFE":          "Raw HTML is rendered as-is under markdown.html_policy: unsafe.",
+	"NP-MD-RAW-HTML-DENY":            "Raw HTML is not allowed under markdown.html_policy: deny.",
+	"NP-MD-HTML-DANGEROUS":           "Raw HTML contains script, event handlers or javascript: URLs.",
+	"NP-MD-READ-ERROR":               "The note could not be read from its content source.",
+	"NP-MD-FRONTMATTER-ERROR":        "The note's YAML frontmatter could not be parsed.",
+	"NP-OBSIDIAN-UNSUPPORTED":        "Obsidian syntax that notepub renders only partially.",
+	"NP-MD-SHORTCODE-UNKNOWN":        "The theme has no shortcodes/<name>.html for this shortcode.",
+	"NP-MD-SHORTCODE-UNPAIRED":       "A closing shortcode tag has no opening tag.",
+	"NP-FM-TYPE":                     "Frontmatter field has the wrong type for fm_schema.",
+	"NP-FM-ENUM":                     "Frontmatter field is not one of the allowed values.",
+	"NP-FM-PATTERN":                  "Frontmatter field does not match the schema pattern.",
+	"NP-FM-URL":                      "Frontmatter field is not a valid URL.",
+	"NP-FM-DATE":                     "Frontmatter field is not a valid date.",
+	"NP-FM-MIN":                      "Frontmatter field is below the schema minimum.",
+	"NP-FM-MAX":                      "Frontmatter field is above the schema maximum.",
+	"NP-FM-REQUIRED":                 "Frontmatter field required for this type is missing.",
+	"NP-FM-NOT-ALLOWED":              "Frontmatter field is not declared for this type.",
+	"NP-LINK-EXTERNAL-DEAD":          "External link returns a client error (4xx).",
+	"NP-LINK-EXTERNAL-UNREACHABLE":   "External link timed out, failed or returned 429/5xx.",
+	"NP-DIST-MISSING":                "Built HTML references a file that is not in dist.",
+	"NP-DIST-OUTSIDE-BASE":           "Built HTML references a path outside the base_url path.",
+	"NP-DIST-MISSING-ANCHOR":         "Built HTML links to a #fragment with no matching element id.",
+	"NP-DIST-BAD-URL":                "Built HTML contains an unparsable URL.",
+	"NP-INDEX-ERROR":                 "The note failed index validation.",
+	"NP-INDEX-REQUIRED":              "A field required by fields.required is missing.",
+	"NP-INDEX-UNKNOWN-TYPE":          "The note's type is not declared in rules.yaml.",
+	"NP-INDEX-MISSING-TEMPLATE":      "The note's type, template or layout names no theme template.",
+	"NP-INDEX-MISSING-PERMALINK":     "The note's type has no permalink.",
+	"NP-INDEX-PERMALINK":             "The permalink could not be built for the note.",
+	"NP-INDEX-LANG":                  "The note's lang is not a configured language.",
+	"NP-INDEX-DATE":                  "A date field is invalid or expire_at is not after publish_at.",
+	"NP-INDEX-CASCADE":               "A folder default above the note (_defaults.yaml or an index note cascade) could not be loaded.",
+	"NP-INDEX-DUPLICATE-ROUTE":       "Another note already uses this route.",
+	"NP-INDEX-DUPLICATE-SLUG":        "Another note already uses this slug.",
+	"NP-INDEX-DUPLICATE-TRANSLATION": "Another note already translates this translation_key into the language.",
+	"NP-INDEX-REDIRECT":              "A redirect_from entry is invalid or collides with a route or another alias.",
+	"NP-INDEX-LINK":                  "A frontmatter link could not be resolved.",
+}
+
+// RuleFor returns the catalogue entry for code, with a generic description
+// for codes the catalogue does not know.
+func RuleFor(code string) Rule {
+	if desc, ok := Rules[code]; ok {
+		return Rule{ID: code, Description: desc, HelpURI: toolInfoURI + "#" + strings.ToLower(code)}
+	}
+	if code == "" {
+		code = genericRuleID
+	}
+	return Rule{ID: code, Description: "notepub diagnostic " + code + ".", HelpURI: codesHelpURI}
+}
+
+// Options adjusts how diagnostics map onto repository files.
+type Options struct {
+	Version string
+	// FilePath maps a diagnostic File (content key or dist page) to a
+	// repository-relative path. Nil keeps File as is.
+	FilePath func(string) string
+}
+
+func (o Options) path(file string) string {
+	if o.FilePath == nil {
+		return file
+	}
+	return o.FilePath(file)
+}
+
+// Set is one group of diagnostics with the options that map its files.
+type Set struct {
+	Diags   []indexer.MarkdownDiagnostic
+	Options Options
+}
+
+// Merge flattens sets into one list with every File already mapped through
+// its set's FilePath, so several reports can be written as one SARIF log or
+// one annotation stream.
+func Merge(sets ...Set) []indexer.MarkdownDiagnostic {
+	var out []indexer.MarkdownDiagnostic
+	for _, set := range sets {
+		for _, d := range set.Diags {
+			d.File = set.Options.path(d.File)
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// WriteGitHub writes GitHub Actions workflow commands, one per diagnostic.
+func WriteGitHub(w io.Writer, diags []indexer.MarkdownDiagnostic, opts Options) error {
+	for _, d := range diags {
+		cmd := "error"
+		switch d.Severity {
+		case "warn", "warning":
+			cmd = "warning"
+		case "info", "note":
+			cmd = "notice"
+		}
+		props := []string{"file=" + escapeProperty(opts.path(d.File))}
+		if d.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", d.Line))
+		}
+		props = append(props, "title="+escapeProperty(RuleFor(d.Code).ID))
+		if _, err := fmt.Fprintf(w, "::%s %s::%s\n", cmd, strings.Join(props, ","), escapeData(d.Message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeData(val string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(val)
+}
+
+func escapeProperty(val string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(val)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	HelpURI          string       `json:"helpUri"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes a SARIF 2.1.0 log. The rules table lists every code
+// present in diags with its description and help URI.
+func WriteSARIF(w io.Writer, diags []indexer.MarkdownDiagnostic, opts Options) error {
+	codes := map[string]bool{}
+	for _, d := range diags {
+		codes[RuleFor(d.Code).ID] = true
+	}
+	ids := make([]string, 0, len(codes))
+	for id := range codes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	ruleIndex := make(map[string]int, len(ids))
+	rules := make([]sarifRule, 0, len(ids))
+	for i, id := range ids {
+		ruleIndex[id] = i
+		rule := RuleFor(id)
+		rules = append(rules, sarifRule{
+			ID:               rule.ID,
+			ShortDescription: sarifMessage{Text: rule.Description},
+			HelpURI:          rule.HelpURI,
+		})
+	}
+
+	results := make([]sarifResult, 0, len(diags))
+	for _, d := range diags {
+		id := RuleFor(d.Code).ID
+		loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: opts.path(d.File)}}
+		if d.Line > 0 {
+			loc.Region = &sarifRegion{StartLine: d.Line}
+		}
+		results = append(results, sarifResult{
+			RuleID:    id,
+			RuleIndex: ruleIndex[id],
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: loc}},
+		})
+	}
+
+	payload := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				Version:        opts.Version,
+				InformationURI: toolInfoURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(payload)
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "warn", "warning":
+		return "warning"
+	default:
+		return "note"
+	}
+}
@@ -0,0 +1,111 @@
+package diagfmt
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/indexer"
+)
+
+var testDiags = []indexer.MarkdownDiagnostic{
+	{Code: "NP-MD-WIKI-MISSING", Severity: "error", File: "notes/a.md", Line: 4, Message: "missing [[b]], see c"},
+	{Code: "NP-LINK-EXTERNAL-UNREACHABLE", Severity: "warn", File: "notes/b.md", Message: "https://x.test: 50%\nretry"},
+}
+
+func TestWriteGitHub(t *testing.T) {
+	var b strings.Builder
+	opts := Options{FilePath: func(f string) string { return "content/" + f }}
+	if err := WriteGitHub(&b, testDiags, opts); err != nil {
+		t.Fatalf("WriteGitHub: %v", err)
+	}
+	want := "::error file=content/notes/a.md,line=4,title=NP-MD-WIKI-MISSING::missing [[b]], see c\n" +
+		"::warning file=content/notes/b.md,title=NP-LINK-EXTERNAL-UNREACHABLE::https://x.test: 50%25%0Aretry\n"
+	if b.String() != want {
+		t.Fatalf("unexpected output:\n%s", b.String())
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var b strings.Builder
+	if err := WriteSARIF(&b, testDiags, Options{Version: "v1.2.3"}); err != nil {
+		t.Fatalf("WriteSARIF: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(b.String()), &log); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %+v", log)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Version != "v1.2.3" || len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("unexpected driver: %+v", run.Tool.Driver)
+	}
+	if run.Tool.Driver.Rules[1].ID != "NP-MD-WIKI-MISSING" || run.Tool.Driver.Rules[1].ShortDescription.Text != Rules["NP-MD-WIKI-MISSING"] {
+		t.Fatalf("unexpected rules: %+v", run.Tool.Driver.Rules)
+	}
+	if got := run.Tool.Driver.Rules[1].HelpURI; got != "https://github.com/cookiespooky/notepub#np-md-wiki-missing" {
+		t.Fatalf("helpUri = %q", got)
+	}
+	first := run.Results[0]
+	if first.RuleIndex != 1 || first.Level != "error" || first.Locations[0].PhysicalLocation.Region.StartLine != 4 {
+		t.Fatalf("unexpected result: %+v", first)
+	}
+	second := run.Results[1]
+	if second.Level != "warning" || second.Locations[0].PhysicalLocation.Region != nil {
+		t.Fatalf("unexpected result: %+v", second)
+	}
+}
+
+func TestRuleForUnknownCode(t *testing.T) {
+	if r := RuleFor("NP-NEW"); r.ID != "NP-NEW" || r.Description == "" {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+	if r := RuleFor("NP-NEW"); r.HelpURI != codesHelpURI {
+		t.Fatalf("unknown code helpUri = %q", r.HelpURI)
+	}
+	if r := RuleFor(""); r.ID != genericRuleID {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+}
+
+func TestMergeMapsEachSetOnce(t *testing.T) {
+	content := Set{Diags: testDiags[:1], Options: Options{FilePath: func(f string) string { return "content/" + f }}}
+	dist := Set{
+		Diags:   []indexer.MarkdownDiagnostic{{Code: "NP-DIST-MISSING", Severity: "error", File: "a/index.html", Message: "missing /x.css"}},
+		Options: Options{FilePath: func(f string) string { return "dist/" + f }},
+	}
+	var b strings.Builder
+	if err := WriteSARIF(&b, Merge(content, dist), Options{Version: "v1"}); err != nil {
+		t.Fatalf("WriteSARIF: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(b.String()), &log); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("expected one run with both results, got %+v", log)
+	}
+	got := []string{
+		log.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI,
+		log.Runs[0].Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI,
+	}
+	if got[0] != "content/notes/a.md" || got[1] != "dist/a/index.html" {
+		t.Fatalf("unexpected uris: %v", got)
+	}
+}
+
+func TestReadmeHasAnchorForEveryRule(t *testing.T) {
+	data, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatalf("read README: %v", err)
+	}
+	for code := range Rules {
+		anchor := `<a id="` + strings.ToLower(code) + `"></a>`
+		if !strings.Contains(string(data), anchor) {
+			t.Errorf("README Diagnostic codes table has no %s row", code)
+		}
+	}
+}
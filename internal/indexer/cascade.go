@@ -0,0 +1,189 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cookiespooky/notepub/internal/mountutil"
+	"github.com/cookiespooky/notepub/internal/s3util"
+)
+
+const cascadeDefaultsFile = "_defaults.yaml"
+
+var cascadeIndexNotes = []string{"_index.md", "index.md"}
+
+// cascadeLayer is one source of directory defaults: a `_defaults.yaml` file
+// or the `cascade:` frontmatter key of a folder's index note. A broken layer
+// could not be read or parsed; notes under it are reported instead of being
+// indexed without its defaults.
+type cascadeLayer struct {
+	source string
+	etag   string
+	values map[string]interface{}
+	broken bool
+}
+
+// cascadeSet holds directory defaults keyed by content directory ("." is the
+// root). failed lists index notes whose diagnostics loadCascades already
+// reported.
+type cascadeSet struct {
+	byDir  map[string][]cascadeLayer
+	failed map[string]bool
+}
+
+type cascadeResult struct {
+	Values    map[string]interface{}
+	Sources   map[string]string
+	Signature string
+	// Broken is the first broken layer above the note, if any.
+	Broken string
+}
+
+var cascadeKeyRe = regexp.MustCompile(`(?m)^cascade\s*:`)
+
+// loadCascades reads every `_defaults.yaml` and index note cascade. Index note
+// bodies are kept in bodies so the main loop does not fetch them twice. A
+// source that cannot be read or parsed becomes a diagnostic and a broken
+// layer; an index note with unparsable frontmatter only breaks its folder when
+// it has a `cascade:` key.
+func loadCascades(ctx context.Context, reader *mountutil.Reader, objects []s3util.Object, bodies map[string][]byte) (cascadeSet, []MarkdownDiagnostic) {
+	set := cascadeSet{byDir: map[string][]cascadeLayer{}, failed: map[string]bool{}}
+	var diags []MarkdownDiagnostic
+	sorted := make([]s3util.Object, len(objects))
+	copy(sorted, objects)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	for _, obj := range sorted {
+		base := path.Base(obj.Key)
+		isDefaults := base == cascadeDefaultsFile
+		if !isDefaults && !isCascadeIndexNote(base) {
+			continue
+		}
+		layer := cascadeLayer{source: obj.Key, etag: obj.ETag}
+		body, err := reader.Fetch(ctx, obj.Key)
+		if err != nil {
+			diags = append(diags, MarkdownDiagnostic{Code: "NP-MD-READ-ERROR", Severity: "error", File: obj.Key, Line: 1, Message: err.Error()})
+			set.failed[obj.Key] = true
+			layer.broken = true
+			set.add(path.Dir(obj.Key), layer, isDefaults)
+			continue
+		}
+		if isDefaults {
+			if err := yaml.Unmarshal(body, &layer.values); err != nil {
+				d := MarkdownDiagnostic{Code: "NP-MD-FRONTMATTER-ERROR", Severity: "error", File: obj.Key, Line: 1, Message: "defaults yaml: " + err.Error()}
+				if line := yamlErrorLine(err); line > 0 {
+					d.Line = line
+				}
+				diags = append(diags, d)
+				layer.broken = true
+			}
+		} else {
+			bodies[obj.Key] = body
+			meta, _, err := parseFrontmatter(body)
+			if err != nil {
+				d := MarkdownDiagnostic{Code: "NP-MD-FRONTMATTER-ERROR", Severity: "error", File: obj.Key, Line: 1, Message: err.Error()}
+				var fmErr *FrontmatterError
+				if errors.As(err, &fmErr) && fmErr.Line > 0 {
+					d.Line = fmErr.Line
+				}
+				diags = append(diags, d)
+				set.failed[obj.Key] = true
+				if !cascadeKeyRe.Match(body) {
+					continue
+				}
+				layer.broken = true
+			} else if raw, ok := meta["cascade"]; !ok {
+				continue
+			} else if layer.values, ok = raw.(map[string]interface{}); !ok {
+				diags = append(diags, newNoteDiagnostics(obj.Key, body).diag("NP-MD-FRONTMATTER-ERROR", "cascade", "cascade must be a mapping"))
+				set.failed[obj.Key] = true
+				layer.broken = true
+			}
+		}
+		if !layer.broken && len(layer.values) == 0 {
+			continue
+		}
+		set.add(path.Dir(obj.Key), layer, isDefaults)
+	}
+	return set, diags
+}
+
+// add appends layer to dir; _defaults.yaml goes first so an index note
+// cascade in the same folder wins.
+func (c cascadeSet) add(dir string, layer cascadeLayer, isDefaults bool) {
+	if isDefaults {
+		c.byDir[dir] = append([]cascadeLayer{layer}, c.byDir[dir]...)
+	} else {
+		c.byDir[dir] = append(c.byDir[dir], layer)
+	}
+}
+
+func isCascadeIndexNote(base string) bool {
+	for _, name := range cascadeIndexNotes {
+		if strings.EqualFold(base, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// forKey merges the cascades of every folder above key, nearer folders
+// overriding parents. An index note does not receive its own cascade.
+func (c cascadeSet) forKey(key string) cascadeResult {
+	res := cascadeResult{}
+	if len(c.byDir) == 0 {
+		return res
+	}
+	h := sha1.New()
+	for _, dir := range cascadeDirs(key) {
+		for _, layer := range c.byDir[dir] {
+			if layer.source == key {
+				continue
+			}
+			if layer.broken {
+				if res.Broken == "" {
+					res.Broken = layer.source
+				}
+				continue
+			}
+			if res.Values == nil {
+				res.Values = map[string]interface{}{}
+				res.Sources = map[string]string{}
+			}
+			for k, v := range layer.values {
+				res.Values[k] = v
+				res.Sources[k] = layer.source
+			}
+			io.WriteString(h, layer.source)
+			io.WriteString(h, "\x00")
+			io.WriteString(h, layer.etag)
+			io.WriteString(h, "\x00")
+		}
+	}
+	if res.Values != nil {
+		res.Signature = hex.EncodeToString(h.Sum(nil))
+	}
+	return res
+}
+
+// cascadeDirs lists the folders containing key from the root down.
+func cascadeDirs(key string) []string {
+	dir := path.Dir(key)
+	dirs := []string{"."}
+	if dir == "." || dir == "/" {
+		return dirs
+	}
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	for i := range parts {
+		dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+	}
+	return dirs
+}
@@ -0,0 +1,122 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/mountutil"
+)
+
+func writeVaultFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	full := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", rel, err)
+	}
+}
+
+func loadTestCascades(t *testing.T, root string) cascadeSet {
+	t.Helper()
+	cfg := config.Config{Content: config.ContentConfig{Source: "local", LocalDir: root}}
+	reader := mountutil.NewReader(cfg, nil)
+	objects, err := reader.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	set, diags := loadCascades(context.Background(), reader, objects, map[string][]byte{})
+	if len(diags) > 0 {
+		t.Fatalf("loadCascades: %#v", diags)
+	}
+	return set
+}
+
+func TestCascadeNestedFoldersOverrideParents(t *testing.T) {
+	root := t.TempDir()
+	writeVaultFile(t, root, "_defaults.yaml", "noindex: false\nhub: root\n")
+	writeVaultFile(t, root, "products/_defaults.yaml", "type: product\nhub: products\n")
+	writeVaultFile(t, root, "products/index.md", "---\ntype: hub\ncascade:\n  template: product.html\n---\n")
+	writeVaultFile(t, root, "products/archive/_defaults.yaml", "noindex: true\n")
+	writeVaultFile(t, root, "products/archive/old.md", "---\ntitle: Old\n---\n")
+
+	set := loadTestCascades(t, root)
+	got := set.forKey("products/archive/old.md")
+	if got.Values["type"] != "product" || got.Values["hub"] != "products" || got.Values["template"] != "product.html" || got.Values["noindex"] != true {
+		t.Fatalf("unexpected cascade values: %#v", got.Values)
+	}
+	if got.Sources["type"] != "products/_defaults.yaml" {
+		t.Fatalf("type source = %q", got.Sources["type"])
+	}
+	index := set.forKey("products/index.md")
+	if _, ok := index.Values["template"]; ok {
+		t.Fatalf("index note should not receive its own cascade: %#v", index.Values)
+	}
+	if other := set.forKey("about.md"); other.Values["hub"] != "root" || other.Signature == got.Signature {
+		t.Fatalf("unexpected root cascade: %#v", other)
+	}
+}
+
+func TestCascadeSignatureChangesWithSource(t *testing.T) {
+	root := t.TempDir()
+	writeVaultFile(t, root, "drafts/_defaults.yaml", "noindex: true\n")
+	writeVaultFile(t, root, "drafts/a.md", "---\ntitle: A\n---\n")
+	before := loadTestCascades(t, root).forKey("drafts/a.md").Signature
+	if before == "" {
+		t.Fatalf("expected cascade signature")
+	}
+	writeVaultFile(t, root, "drafts/_defaults.yaml", "noindex: true\ntype: page\n")
+	after := loadTestCascades(t, root).forKey("drafts/a.md").Signature
+	if after == before {
+		t.Fatalf("signature did not change after cascade edit")
+	}
+	if sig := loadTestCascades(t, root).forKey("a.md").Signature; sig != "" {
+		t.Fatalf("unrelated note signature = %q", sig)
+	}
+}
+
+func TestCascadeBrokenLayersBecomeDiagnostics(t *testing.T) {
+	root := t.TempDir()
+	writeVaultFile(t, root, "_defaults.yaml", "hub: root\n")
+	writeVaultFile(t, root, "aa/_defaults.yaml", "hub: aa\ntype: \"page\n")
+	writeVaultFile(t, root, "aa/a.md", "---\ntitle: A\n---\n")
+	writeVaultFile(t, root, "zz/index.md", "---\ntitle: Z\ncascade:\n  type: post\nbad: [\n---\n")
+	writeVaultFile(t, root, "zz/z.md", "---\ntitle: Z1\n---\n")
+	writeVaultFile(t, root, "yy/index.md", "---\ntitle: [\n---\n")
+	writeVaultFile(t, root, "yy/y.md", "---\ntitle: Y1\n---\n")
+
+	cfg := config.Config{Content: config.ContentConfig{Source: "local", LocalDir: root}}
+	reader := mountutil.NewReader(cfg, nil)
+	objects, err := reader.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	set, diags := loadCascades(context.Background(), reader, objects, map[string][]byte{})
+	byFile := map[string]MarkdownDiagnostic{}
+	for _, d := range diags {
+		byFile[d.File] = d
+	}
+	if d := byFile["aa/_defaults.yaml"]; d.Code != "NP-MD-FRONTMATTER-ERROR" || d.Line != 2 {
+		t.Fatalf("defaults diagnostic = %#v", d)
+	}
+	if d := byFile["zz/index.md"]; d.Code != "NP-MD-FRONTMATTER-ERROR" || d.Line != 5 {
+		t.Fatalf("index note diagnostic = %#v", d)
+	}
+	if len(diags) != 3 || !set.failed["zz/index.md"] || !set.failed["yy/index.md"] {
+		t.Fatalf("diagnostics = %#v, failed = %v", diags, set.failed)
+	}
+	if got := set.forKey("aa/a.md"); got.Broken != "aa/_defaults.yaml" || got.Values["hub"] != "root" {
+		t.Fatalf("aa/a.md cascade = %#v", got)
+	}
+	if got := set.forKey("zz/z.md"); got.Broken != "zz/index.md" {
+		t.Fatalf("zz/z.md cascade = %#v", got)
+	}
+	// yy/index.md has no cascade key, so its folder keeps working.
+	if got := set.forKey("yy/y.md"); got.Broken != "" || got.Values["hub"] != "root" {
+		t.Fatalf("yy/y.md cascade = %#v", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cookiespooky/notepub/internal/models"
 	"github.com/cookiespooky/notepub/internal/rules"
@@ -96,7 +97,8 @@ func buildCollectionItem(idx models.ResolveIndex, pathVal string) models.Collect
 		Description: meta.Description,
 		Canonical:   meta.Canonical,
 		Image:       meta.Image,
-		UpdatedAt:   route.LastModified,
+		CreatedAt:   meta.Date,
+		UpdatedAt:   firstNonEmpty(meta.Updated, route.LastModified),
 		NoIndex:     route.NoIndex,
 		FM:          meta.FM,
 	}
@@ -237,11 +239,16 @@ func collectionSortValue(item models.CollectionItem, by string, schema map[strin
 			return "", true
 		}
 		return strings.ToLower(item.Slug), false
-	case "updated_at", "created_at":
+	case "updated_at":
 		if item.UpdatedAt == "" {
 			return "", true
 		}
-		return item.UpdatedAt, false
+		return sortableTime(item.UpdatedAt), false
+	case "created_at":
+		if item.CreatedAt == "" {
+			return "", true
+		}
+		return sortableTime(item.CreatedAt), false
 	default:
 		if strings.HasPrefix(by, "fm.") {
 			key := strings.TrimPrefix(by, "fm.")
@@ -374,3 +381,12 @@ func collectionToBool(val interface{}) bool {
 		return fmt.Sprint(val) == "true"
 	}
 }
+
+// sortableTime normalizes RFC 3339 timestamps to UTC so mixed offsets sort by instant.
+func sortableTime(val string) string {
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return val
+	}
+	return t.UTC().Format(time.RFC3339)
+}
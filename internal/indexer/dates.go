@@ -0,0 +1,198 @@
+package indexer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+// Layouts accepted for frontmatter dates. Layouts without an offset are read
+// in site.timezone.
+var frontmatterDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// timeFromMeta parses a frontmatter date. YAML already decodes ISO dates into
+// UTC times; those without an explicit non-UTC offset are re-read in loc.
+func timeFromMeta(meta map[string]interface{}, key string, loc *time.Location) (time.Time, bool, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if meta == nil {
+		return time.Time{}, false, nil
+	}
+	switch v := meta[key].(type) {
+	case time.Time:
+		if v.Location() == time.UTC && loc != time.UTC {
+			v = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), loc)
+		}
+		return v, true, nil
+	case string:
+		raw := strings.TrimSpace(v)
+		if raw == "" {
+			return time.Time{}, false, nil
+		}
+		for _, layout := range frontmatterDateLayouts {
+			if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+				return t, true, nil
+			}
+		}
+		return time.Time{}, false, newFieldError(key, "invalid %s %q", key, raw)
+	case nil:
+		return time.Time{}, false, nil
+	}
+	return time.Time{}, false, newFieldError(key, "invalid %s", key)
+}
+
+// applyNoteDates fills date, updated, publish_at and expire_at on meta as RFC 3339.
+func applyNoteDates(meta *models.MetaEntry, fm map[string]interface{}, loc *time.Location) error {
+	fields := []struct {
+		key string
+		dst *string
+	}{
+		{"date", &meta.Date},
+		{"updated", &meta.Updated},
+		{"publish_at", &meta.PublishAt},
+		{"expire_at", &meta.ExpireAt},
+	}
+	for _, f := range fields {
+		t, ok, err := timeFromMeta(fm, f.key, loc)
+		if err != nil {
+			return err
+		}
+		if ok {
+			*f.dst = t.Format(time.RFC3339)
+		}
+	}
+	if meta.PublishAt != "" && meta.ExpireAt != "" {
+		publish, _ := time.Parse(time.RFC3339, meta.PublishAt)
+		expire, _ := time.Parse(time.RFC3339, meta.ExpireAt)
+		if !expire.After(publish) {
+			return newFieldError("expire_at", "expire_at %s is not after publish_at %s", meta.ExpireAt, meta.PublishAt)
+		}
+	}
+	return nil
+}
+
+// IsPublished reports whether meta is inside its publish window at now.
+func IsPublished(meta models.MetaEntry, now time.Time) bool {
+	if meta.PublishAt != "" {
+		if t, err := time.Parse(time.RFC3339, meta.PublishAt); err == nil && now.Before(t) {
+			return false
+		}
+	}
+	if meta.ExpireAt != "" {
+		if t, err := time.Parse(time.RFC3339, meta.ExpireAt); err == nil && !now.Before(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublishedView returns idx without notes outside their publish window at now,
+// and the next time the view changes (zero when no window is pending).
+func PublishedView(idx models.ResolveIndex, now time.Time) (models.ResolveIndex, time.Time) {
+	var next time.Time
+	hidden := map[string]bool{}
+	for p, meta := range idx.Meta {
+		for _, raw := range []string{meta.PublishAt, meta.ExpireAt} {
+			if raw == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil || !t.After(now) {
+				continue
+			}
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+		}
+		if !IsPublished(meta, now) {
+			hidden[p] = true
+		}
+	}
+	return filterView(idx, hidden), next
+}
+
+// filterView returns a copy of idx without the hidden paths, dropping links,
+// media and translations that point at them. idx itself is not modified.
+func filterView(idx models.ResolveIndex, hidden map[string]bool) models.ResolveIndex {
+	if len(hidden) == 0 {
+		return idx
+	}
+	view := idx
+	view.Routes = make(map[string]models.RouteEntry, len(idx.Routes))
+	for p, route := range idx.Routes {
+		if !hidden[p] {
+			view.Routes[p] = route
+		}
+	}
+	view.Meta = make(map[string]models.MetaEntry, len(idx.Meta))
+	for p, meta := range idx.Meta {
+		if !hidden[p] {
+			view.Meta[p] = meta
+		}
+	}
+	if idx.Links != nil {
+		view.Links = make(map[string]map[string][]string, len(idx.Links))
+		for p, byRule := range idx.Links {
+			if hidden[p] {
+				continue
+			}
+			filtered := make(map[string][]string, len(byRule))
+			for rule, targets := range byRule {
+				kept := make([]string, 0, len(targets))
+				for _, target := range targets {
+					if !hidden[target] {
+						kept = append(kept, target)
+					}
+				}
+				filtered[rule] = kept
+			}
+			view.Links[p] = filtered
+		}
+	}
+	if idx.Media != nil {
+		view.Media = make(map[string][]string, len(idx.Media))
+		for p, media := range idx.Media {
+			if !hidden[p] {
+				view.Media[p] = media
+			}
+		}
+	}
+	if idx.Translations != nil {
+		view.Translations = make(map[string]map[string]string, len(idx.Translations))
+		for key, byLang := range idx.Translations {
+			kept := map[string]string{}
+			for lang, p := range byLang {
+				if !hidden[p] {
+					kept[lang] = p
+				}
+			}
+			if len(kept) > 0 {
+				view.Translations[key] = kept
+			}
+		}
+	}
+	if idx.Redirects != nil {
+		view.Redirects = make(map[string]models.Redirect, len(idx.Redirects))
+		for from, r := range idx.Redirects {
+			if !hidden[r.To] {
+				view.Redirects[from] = r
+			}
+		}
+	}
+	return view
+}
@@ -0,0 +1,160 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestApplyNoteDatesUsesSiteTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	fm := map[string]interface{}{
+		"date":       time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"updated":    "12.03.2026 09:30",
+		"publish_at": "2026-03-01T08:00:00+00:00",
+		"expire_at":  "March 31, 2026",
+	}
+	var meta models.MetaEntry
+	if err := applyNoteDates(&meta, fm, loc); err != nil {
+		t.Fatalf("applyNoteDates: %v", err)
+	}
+	want := map[string]string{
+		"date":       "2026-03-01T00:00:00+01:00",
+		"updated":    "2026-03-12T09:30:00+01:00",
+		"publish_at": "2026-03-01T08:00:00Z",
+		"expire_at":  "2026-03-31T00:00:00+02:00",
+	}
+	got := map[string]string{
+		"date":       meta.Date,
+		"updated":    meta.Updated,
+		"publish_at": meta.PublishAt,
+		"expire_at":  meta.ExpireAt,
+	}
+	for key, w := range want {
+		if got[key] != w {
+			t.Fatalf("%s: got %q want %q", key, got[key], w)
+		}
+	}
+}
+
+func TestApplyNoteDatesRejectsInvertedWindow(t *testing.T) {
+	fm := map[string]interface{}{
+		"publish_at": "2026-05-02",
+		"expire_at":  "2026-05-01",
+	}
+	var meta models.MetaEntry
+	if err := applyNoteDates(&meta, fm, time.UTC); err == nil {
+		t.Fatalf("expected error for expire_at before publish_at")
+	}
+}
+
+func TestPublishedViewHidesScheduledAndExpired(t *testing.T) {
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/live":      {Status: 200},
+			"/scheduled": {Status: 200},
+			"/expired":   {Status: 200},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/live":      {Title: "Live", ExpireAt: "2026-06-01T00:00:00Z"},
+			"/scheduled": {Title: "Scheduled", PublishAt: "2026-05-10T00:00:00Z"},
+			"/expired":   {Title: "Expired", ExpireAt: "2026-04-01T00:00:00Z"},
+		},
+		Links: map[string]map[string][]string{
+			"/live": {"related": {"/scheduled", "/expired"}},
+		},
+	}
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	view, next := PublishedView(idx, now)
+	if _, ok := view.Routes["/live"]; !ok {
+		t.Fatalf("expected /live to be published")
+	}
+	for _, p := range []string{"/scheduled", "/expired"} {
+		if _, ok := view.Routes[p]; ok {
+			t.Fatalf("expected %s to be hidden", p)
+		}
+		if _, ok := view.Meta[p]; ok {
+			t.Fatalf("expected %s meta to be hidden", p)
+		}
+	}
+	if got := view.Links["/live"]["related"]; len(got) != 0 {
+		t.Fatalf("expected hidden link targets to be dropped, got %v", got)
+	}
+	if want := time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("next window: got %s want %s", next, want)
+	}
+	if len(idx.Routes) != 3 {
+		t.Fatalf("PublishedView must not modify the source index")
+	}
+
+	later, _ := PublishedView(idx, time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC))
+	if _, ok := later.Routes["/scheduled"]; !ok {
+		t.Fatalf("expected /scheduled to be published once publish_at passes")
+	}
+}
+
+func TestRewriteSitemapsSkipsNewerIndexAndBusyLock(t *testing.T) {
+	root := t.TempDir()
+	var cfg config.Config
+	cfg.Site.BaseURL = "https://example.test/"
+	cfg.Paths.ArtifactsDir = filepath.Join(root, "artifacts")
+	cfg.Paths.SnapshotFile = filepath.Join(root, "snapshot", "index.json")
+	for _, dir := range []string{cfg.Paths.ArtifactsDir, filepath.Dir(cfg.Paths.SnapshotFile)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	resolvePath := filepath.Join(cfg.Paths.ArtifactsDir, resolveFileName)
+	if err := os.WriteFile(resolvePath, []byte("{}"), 0o644); err != nil {
+		t.Fatalf("write resolve: %v", err)
+	}
+	info, err := os.Stat(resolvePath)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/a": {Status: 200}},
+		Meta:   map[string]models.MetaEntry{"/a": {Type: "page"}},
+	}
+	sitemap := filepath.Join(cfg.Paths.ArtifactsDir, "sitemap-index.xml")
+
+	if err := RewriteSitemaps(cfg, idx, info.ModTime().Add(-time.Second), rules.Rules{}); err != nil {
+		t.Fatalf("RewriteSitemaps: %v", err)
+	}
+	if _, err := os.Stat(sitemap); !os.IsNotExist(err) {
+		t.Fatalf("sitemap written for a stale view: %v", err)
+	}
+
+	lockPath := filepath.Join(filepath.Dir(cfg.Paths.SnapshotFile), "index.lock")
+	lock, err := acquireLock(lockPath)
+	if err != nil {
+		t.Fatalf("acquireLock: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := RewriteSitemaps(cfg, idx, info.ModTime(), rules.Rules{}); err == nil {
+			t.Fatal("expected busy lock error while indexing")
+		}
+	}
+	releaseLock(lock, lockPath)
+
+	if err := RewriteSitemaps(cfg, idx, info.ModTime(), rules.Rules{}); err != nil {
+		t.Fatalf("RewriteSitemaps: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.Paths.ArtifactsDir, "sitemap-0001.xml"))
+	if err != nil {
+		t.Fatalf("read sitemap: %v", err)
+	}
+	if !strings.Contains(string(data), "https://example.test/a") {
+		t.Fatalf("unexpected sitemap: %s", data)
+	}
+}
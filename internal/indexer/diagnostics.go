@@ -2,20 +2,19 @@ package indexer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"sort"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/cookiespooky/notepub/internal/config"
 	"github.com/cookiespooky/notepub/internal/linkutil"
-	"github.com/cookiespooky/notepub/internal/localutil"
 	"github.com/cookiespooky/notepub/internal/mdproc"
 	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/mountutil"
 	"github.com/cookiespooky/notepub/internal/rules"
-	"github.com/cookiespooky/notepub/internal/s3util"
 )
 
 type MarkdownDiagnostic struct {
@@ -23,7 +22,20 @@ type MarkdownDiagnostic struct {
 	Severity string
 	File     string
 	Line     int
-	Message  string
+	// Field is the frontmatter key the diagnostic refers to, when known.
+	Field   string `json:",omitempty"`
+	Message string
+}
+
+// ValidationError is returned by Run when notes fail index validation.
+// Diagnostics holds every error and warning found in the run.
+type ValidationError struct {
+	Diagnostics []MarkdownDiagnostic
+}
+
+func (e *ValidationError) Error() string {
+	errCount, _ := CountDiagnostics(e.Diagnostics)
+	return fmt.Sprintf("index validation failed (%d errors)", errCount)
 }
 
 type MarkdownCapabilities struct {
@@ -43,52 +55,50 @@ func defaultMarkdownCapabilities() MarkdownCapabilities {
 			"obsidian.math":       true,
 			"raw_html":            true,
 			"obsidian.block_refs": false,
+			"shortcodes":          true,
 		},
 		Used: map[string]bool{},
 	}
 }
 
 func ValidateMarkdown(ctx context.Context, cfg config.Config, idx models.ResolveIndex) ([]MarkdownDiagnostic, error) {
-	diags, _, err := ValidateMarkdownWithCapabilities(ctx, cfg, idx)
+	diags, _, err := ValidateMarkdownWithCapabilities(ctx, cfg, idx, nil)
 	return diags, err
 }
 
-func ValidateMarkdownWithCapabilities(ctx context.Context, cfg config.Config, idx models.ResolveIndex) ([]MarkdownDiagnostic, MarkdownCapabilities, error) {
-	resolver, err := buildResolverIndex(idx, cfg.S3.Prefix)
+// ValidateMarkdownWithCapabilities diagnoses every note and reports the
+// Markdown features in use. shortcodes lists the theme's shortcode names;
+// when nil, shortcodes are not checked.
+func ValidateMarkdownWithCapabilities(ctx context.Context, cfg config.Config, idx models.ResolveIndex, shortcodes []string) ([]MarkdownDiagnostic, MarkdownCapabilities, error) {
+	resolvers, err := buildLangResolvers(idx, cfg.S3.Prefix)
 	if err != nil {
 		return nil, MarkdownCapabilities{}, fmt.Errorf("build resolver index: %w", err)
 	}
-
-	var (
-		objects  []s3util.Object
-		s3client *s3.Client
-	)
-	switch cfg.Content.Source {
-	case "local":
-		objects, err = localutil.ListMarkdown(cfg.Content.LocalDir, cfg.S3.Prefix)
-		if err != nil {
-			return nil, MarkdownCapabilities{}, fmt.Errorf("list local markdown: %w", err)
-		}
-	case "s3":
-		s3client, err = s3util.NewClient(ctx, s3util.Config{
-			Endpoint:       cfg.S3.Endpoint,
-			Region:         cfg.S3.Region,
-			ForcePathStyle: cfg.S3.ForcePathStyle,
-			Bucket:         cfg.S3.Bucket,
-			Prefix:         cfg.S3.Prefix,
-			AccessKey:      cfg.S3.AccessKey,
-			SecretKey:      cfg.S3.SecretKey,
-			Anonymous:      cfg.S3.Anonymous,
-		})
-		if err != nil {
-			return nil, MarkdownCapabilities{}, fmt.Errorf("s3 client: %w", err)
+	langByKey := map[string]string{}
+	metaByKey := map[string]models.MetaEntry{}
+	for p, route := range idx.Routes {
+		meta, ok := idx.Meta[p]
+		if !ok {
+			continue
 		}
-		objects, err = s3util.ListObjects(ctx, s3client, cfg.S3.Bucket, cfg.S3.Prefix)
-		if err != nil {
-			return nil, MarkdownCapabilities{}, fmt.Errorf("list s3 objects: %w", err)
+		metaByKey[route.S3Key] = meta
+		if meta.Lang != "" {
+			langByKey[route.S3Key] = meta.Lang
 		}
-	default:
-		return nil, MarkdownCapabilities{}, fmt.Errorf("unsupported content source: %s", cfg.Content.Source)
+	}
+	rulesCfg, err := rules.Load(cfg.RulesPath)
+	if err != nil {
+		return nil, MarkdownCapabilities{}, fmt.Errorf("load rules: %w", err)
+	}
+
+	s3client, err := mountutil.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, MarkdownCapabilities{}, fmt.Errorf("s3 client: %w", err)
+	}
+	reader := mountutil.NewReader(cfg, s3client)
+	objects, err := reader.List(ctx)
+	if err != nil {
+		return nil, MarkdownCapabilities{}, fmt.Errorf("list markdown: %w", err)
 	}
 
 	keys := make([]string, 0, len(objects))
@@ -107,16 +117,18 @@ func ValidateMarkdownWithCapabilities(ctx context.Context, cfg config.Config, id
 		Case:      "insensitive",
 	}
 
+	var knownShortcodes map[string]bool
+	if shortcodes != nil {
+		knownShortcodes = make(map[string]bool, len(shortcodes))
+		for _, name := range shortcodes {
+			knownShortcodes[name] = true
+		}
+	}
+
 	diagnostics := make([]MarkdownDiagnostic, 0)
 	capabilities := defaultMarkdownCapabilities()
 	for _, key := range keys {
-		var body []byte
-		switch cfg.Content.Source {
-		case "local":
-			body, err = localutil.FetchObject(cfg.Content.LocalDir, key)
-		case "s3":
-			body, err = s3util.FetchObject(ctx, s3client, cfg.S3.Bucket, key)
-		}
+		body, err := reader.Fetch(ctx, key)
 		if err != nil {
 			diagnostics = append(diagnostics, MarkdownDiagnostic{
 				Code:     "NP-MD-READ-ERROR",
@@ -129,17 +141,30 @@ func ValidateMarkdownWithCapabilities(ctx context.Context, cfg config.Config, id
 		}
 		_, content, err := parseFrontmatter(body)
 		if err != nil {
+			line := 1
+			var fmErr *FrontmatterError
+			if errors.As(err, &fmErr) && fmErr.Line > 0 {
+				line = fmErr.Line
+			}
 			diagnostics = append(diagnostics, MarkdownDiagnostic{
 				Code:     "NP-MD-FRONTMATTER-ERROR",
 				Severity: "error",
 				File:     key,
-				Line:     1,
+				Line:     line,
 				Message:  err.Error(),
 			})
 			continue
 		}
+		if meta, ok := metaByKey[key]; ok {
+			diagnostics = append(diagnostics, checkFrontmatter(key, body, meta.FM, meta.Type, rulesCfg)...)
+		}
 		mergeCapabilities(&capabilities, detectMarkdownCapabilities(string(content)))
+		resolver, ok := resolvers.forLang(langByKey[key])
+		if !ok {
+			resolver = resolvers.all
+		}
 		diagnostics = append(diagnostics, diagnoseMarkdownContent(key, string(content), resolver, rule, cfg.Markdown.HTMLPolicy)...)
+		diagnostics = append(diagnostics, diagnoseShortcodes(key, string(content), knownShortcodes)...)
 	}
 	capabilities.UnsupportedUsed = buildUnsupportedList(capabilities)
 	return diagnostics, capabilities, nil
@@ -247,6 +272,44 @@ func diagnoseMarkdownContent(fileKey string, markdown string, res resolverIndex,
 	return out
 }
 
+// diagnoseShortcodes reports shortcodes the theme has no template for and
+// closing tags without an opening one; both are rendered as written.
+func diagnoseShortcodes(fileKey string, markdown string, known map[string]bool) []MarkdownDiagnostic {
+	if known == nil {
+		return nil
+	}
+	var out []MarkdownDiagnostic
+	open := map[string]int{}
+	for _, sc := range mdproc.FindShortcodes(markdown) {
+		switch {
+		case !known[sc.Name]:
+			if sc.Closing {
+				continue
+			}
+			out = append(out, MarkdownDiagnostic{
+				Code:     "NP-MD-SHORTCODE-UNKNOWN",
+				Severity: "warn",
+				File:     fileKey,
+				Line:     sc.Line,
+				Message:  fmt.Sprintf("%s: no shortcodes/%s.html in theme", sc.Raw, sc.Name),
+			})
+		case !sc.Closing:
+			open[sc.Name]++
+		case open[sc.Name] > 0:
+			open[sc.Name]--
+		default:
+			out = append(out, MarkdownDiagnostic{
+				Code:     "NP-MD-SHORTCODE-UNPAIRED",
+				Severity: "warn",
+				File:     fileKey,
+				Line:     sc.Line,
+				Message:  fmt.Sprintf("%s: closing tag without an opening %s", sc.Raw, sc.Name),
+			})
+		}
+	}
+	return out
+}
+
 func CountDiagnostics(diags []MarkdownDiagnostic) (errors int, warnings int) {
 	for _, d := range diags {
 		switch strings.ToLower(strings.TrimSpace(d.Severity)) {
@@ -296,6 +359,9 @@ func detectMarkdownCapabilities(markdown string) MarkdownCapabilities {
 	if blockRefWikiRe.MatchString(text) {
 		out.Used["obsidian.block_refs"] = true
 	}
+	if len(mdproc.FindShortcodes(markdown)) > 0 {
+		out.Used["shortcodes"] = true
+	}
 	return out
 }
 
@@ -97,3 +97,20 @@ func TestDiagnoseMarkdownContentBlockRefUnsupported(t *testing.T) {
 		t.Fatalf("expected NP-OBSIDIAN-UNSUPPORTED in %#v", diags)
 	}
 }
+
+func TestDiagnoseShortcodes(t *testing.T) {
+	md := "{{< tabs >}}\n{{< tab \"A\" >}}x{{< /tab >}}\n{{< /tabs >}}\n{{< youtube id >}}\n`{{< nope >}}`\n{{< /figure >}}\n"
+	diags := diagnoseShortcodes("content/a.md", md, map[string]bool{"tabs": true, "tab": true, "figure": true})
+	if len(diags) != 2 {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if diags[0].Code != "NP-MD-SHORTCODE-UNKNOWN" || diags[0].Line != 4 || diags[0].Severity != "warn" {
+		t.Fatalf("unexpected unknown diagnostic: %#v", diags[0])
+	}
+	if diags[1].Code != "NP-MD-SHORTCODE-UNPAIRED" || diags[1].Line != 6 {
+		t.Fatalf("unexpected unpaired diagnostic: %#v", diags[1])
+	}
+	if diags := diagnoseShortcodes("content/a.md", md, nil); len(diags) != 0 {
+		t.Fatalf("shortcodes must not be checked without a theme: %#v", diags)
+	}
+}
@@ -0,0 +1,15 @@
+package indexer
+
+import "github.com/cookiespooky/notepub/internal/models"
+
+// ExcludeDrafts returns idx without notes marked `draft: true`. build and
+// prod serve publish this view; draft preview uses the full index.
+func ExcludeDrafts(idx models.ResolveIndex) models.ResolveIndex {
+	hidden := map[string]bool{}
+	for p, meta := range idx.Meta {
+		if meta.Draft {
+			hidden[p] = true
+		}
+	}
+	return filterView(idx, hidden)
+}
@@ -0,0 +1,378 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/mdproc"
+	"github.com/cookiespooky/notepub/internal/mountutil"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+const (
+	defaultExternalConcurrency     = 8
+	defaultExternalPerHostInterval = 500 * time.Millisecond
+	defaultExternalTimeout         = 10 * time.Second
+	defaultExternalCacheTTL        = 24 * time.Hour
+	externalUserAgent              = "notepub-linkcheck/1"
+)
+
+// ExternalLinkOptions controls `validate --external`.
+type ExternalLinkOptions struct {
+	Concurrency     int
+	PerHostInterval time.Duration
+	Timeout         time.Duration
+	CacheTTL        time.Duration
+	// CachePath is the JSON results cache; empty disables caching.
+	CachePath string
+	// Ignore skips URLs starting with any of these prefixes.
+	Ignore []string
+	Client *http.Client
+}
+
+// ExternalLinkOptionsFor builds options from rules.yaml validation.external_links,
+// caching results under paths.cache_root.
+func ExternalLinkOptionsFor(cfg config.Config, rule rules.ExternalLinksRule) (ExternalLinkOptions, error) {
+	opts := ExternalLinkOptions{
+		Concurrency:     rule.Concurrency,
+		PerHostInterval: defaultExternalPerHostInterval,
+		Timeout:         defaultExternalTimeout,
+		CacheTTL:        defaultExternalCacheTTL,
+		Ignore:          rule.Ignore,
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultExternalConcurrency
+	}
+	for _, d := range []struct {
+		name string
+		raw  string
+		dst  *time.Duration
+	}{
+		{"per_host_interval", rule.PerHostInterval, &opts.PerHostInterval},
+		{"timeout", rule.Timeout, &opts.Timeout},
+		{"cache_ttl", rule.CacheTTL, &opts.CacheTTL},
+	} {
+		if strings.TrimSpace(d.raw) == "" {
+			continue
+		}
+		v, err := time.ParseDuration(strings.TrimSpace(d.raw))
+		if err != nil || v < 0 {
+			return ExternalLinkOptions{}, fmt.Errorf("validation.external_links.%s: invalid duration %q", d.name, d.raw)
+		}
+		*d.dst = v
+	}
+	if cfg.Paths.CacheRoot != "" {
+		opts.CachePath = filepath.Join(cfg.Paths.CacheRoot, "linkcheck", "external.json")
+	}
+	return opts, nil
+}
+
+type externalRef struct {
+	URL  string
+	File string
+	Line int
+}
+
+type externalResult struct {
+	Status    int    `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt string `json:"checked_at"`
+}
+
+// transient reports a result that may differ on the next try: a network
+// error, a timeout, 429 or 5xx. Such results are not cached.
+func (r externalResult) transient() bool {
+	return r.Error != "" || r.Status == http.StatusTooManyRequests || r.Status >= 500
+}
+
+// ValidateExternalLinks checks every absolute http(s) URL in the notes and
+// reports dead ones as diagnostics.
+func ValidateExternalLinks(ctx context.Context, cfg config.Config, opts ExternalLinkOptions) ([]MarkdownDiagnostic, error) {
+	s3client, err := mountutil.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("s3 client: %w", err)
+	}
+	reader := mountutil.NewReader(cfg, s3client)
+	objects, err := reader.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list markdown: %w", err)
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if strings.HasSuffix(strings.ToLower(obj.Key), ".md") {
+			keys = append(keys, obj.Key)
+		}
+	}
+	sort.Strings(keys)
+
+	diagnostics := make([]MarkdownDiagnostic, 0)
+	refs := make([]externalRef, 0)
+	for _, key := range keys {
+		body, err := reader.Fetch(ctx, key)
+		if err != nil {
+			diagnostics = append(diagnostics, MarkdownDiagnostic{
+				Code:     "NP-MD-READ-ERROR",
+				Severity: "error",
+				File:     key,
+				Line:     1,
+				Message:  err.Error(),
+			})
+			continue
+		}
+		refs = append(refs, collectExternalLinks(key, string(body), opts.Ignore)...)
+	}
+
+	urls := make([]string, 0, len(refs))
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		if !seen[ref.URL] {
+			seen[ref.URL] = true
+			urls = append(urls, ref.URL)
+		}
+	}
+	results, err := checkExternalLinks(ctx, urls, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if d, ok := externalDiagnostic(ref, results[ref.URL]); ok {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics, nil
+}
+
+// collectExternalLinks finds http(s) URLs per line of a note, frontmatter
+// included, so diagnostics point at the source line.
+func collectExternalLinks(fileKey, body string, ignore []string) []externalRef {
+	lines := strings.Split(mdproc.MaskCodeWithSpaces(mdproc.NormalizeLineEndings(body)), "\n")
+	out := make([]externalRef, 0)
+	for i, line := range lines {
+		seen := map[string]bool{}
+		lineBytes := []byte(line)
+		candidates := append(extractMarkdownLinks(lineBytes), extractEmbedTargets(lineBytes)...)
+		candidates = append(candidates, extractAutoLinks(lineBytes)...)
+		for _, raw := range candidates {
+			u := strings.TrimSpace(raw)
+			if !isExternalHTTP(u) || seen[u] || ignoredURL(u, ignore) {
+				continue
+			}
+			seen[u] = true
+			out = append(out, externalRef{URL: u, File: fileKey, Line: i + 1})
+		}
+	}
+	return out
+}
+
+func isExternalHTTP(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+func ignoredURL(u string, ignore []string) bool {
+	for _, prefix := range ignore {
+		if prefix = strings.TrimSpace(prefix); prefix != "" && strings.HasPrefix(u, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func externalDiagnostic(ref externalRef, res externalResult) (MarkdownDiagnostic, bool) {
+	d := MarkdownDiagnostic{File: ref.File, Line: ref.Line}
+	switch {
+	case res.Error != "":
+		d.Code = "NP-LINK-EXTERNAL-UNREACHABLE"
+		d.Severity = "warn"
+		d.Message = fmt.Sprintf("%s: %s", ref.URL, res.Error)
+	case res.Status >= 400 && res.Status < 500 && res.Status != http.StatusTooManyRequests:
+		d.Code = "NP-LINK-EXTERNAL-DEAD"
+		d.Severity = "error"
+		d.Message = fmt.Sprintf("%s: HTTP %d", ref.URL, res.Status)
+	case res.Status >= 400:
+		d.Code = "NP-LINK-EXTERNAL-UNREACHABLE"
+		d.Severity = "warn"
+		d.Message = fmt.Sprintf("%s: HTTP %d", ref.URL, res.Status)
+	default:
+		return MarkdownDiagnostic{}, false
+	}
+	return d, true
+}
+
+// checkExternalLinks fetches urls with a bounded worker pool and a minimum
+// interval between requests to the same host. Fresh cached results are reused;
+// transient failures are re-checked every run.
+func checkExternalLinks(ctx context.Context, urls []string, opts ExternalLinkOptions) (map[string]externalResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultExternalConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultExternalTimeout
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	cache := loadExternalCache(opts.CachePath)
+	now := time.Now()
+	results := make(map[string]externalResult, len(urls))
+	pending := make([]string, 0, len(urls))
+	// The rewritten cache only keeps URLs still referenced.
+	kept := make(map[string]externalResult, len(urls))
+	for _, u := range urls {
+		if res, ok := cache[u]; ok && opts.CacheTTL > 0 && !res.transient() {
+			if checked, err := time.Parse(time.RFC3339, res.CheckedAt); err == nil && now.Sub(checked) < opts.CacheTTL {
+				results[u] = res
+				kept[u] = res
+				continue
+			}
+		}
+		pending = append(pending, u)
+	}
+
+	limiter := newHostLimiter(opts.PerHostInterval)
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				res := checkExternalURL(ctx, client, limiter, u, opts.Timeout)
+				mu.Lock()
+				results[u] = res
+				if !res.transient() {
+					kept[u] = res
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range pending {
+		select {
+		case jobs <- u:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.CachePath != "" && (len(pending) > 0 || len(kept) != len(cache)) {
+		if err := os.MkdirAll(filepath.Dir(opts.CachePath), 0o755); err != nil {
+			return nil, fmt.Errorf("link cache: %w", err)
+		}
+		if err := writeAtomicJSON(opts.CachePath, kept); err != nil {
+			return nil, fmt.Errorf("link cache: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// checkExternalURL tries HEAD first and falls back to GET for servers that
+// reject or mishandle HEAD.
+func checkExternalURL(ctx context.Context, client *http.Client, limiter *hostLimiter, rawURL string, timeout time.Duration) externalResult {
+	res := externalResult{CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+	host := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+	status, err := externalRequest(ctx, client, limiter, host, http.MethodHead, rawURL, timeout)
+	if err != nil || status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented || status == http.StatusForbidden || status == http.StatusNotFound {
+		status, err = externalRequest(ctx, client, limiter, host, http.MethodGet, rawURL, timeout)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Status = status
+	return res
+}
+
+func externalRequest(ctx context.Context, client *http.Client, limiter *hostLimiter, host, method, rawURL string, timeout time.Duration) (int, error) {
+	if err := limiter.wait(ctx, host); err != nil {
+		return 0, err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", externalUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.CopyN(io.Discard, resp.Body, 64*1024)
+	return resp.StatusCode, nil
+}
+
+// hostLimiter spaces requests to the same host by at least interval.
+type hostLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: map[string]time.Time{}}
+}
+
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+	delay := time.Until(at)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func loadExternalCache(path string) map[string]externalResult {
+	out := map[string]externalResult{}
+	if path == "" {
+		return out
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]externalResult{}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package indexer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollectExternalLinksWithLines(t *testing.T) {
+	body := "---\ntitle: Links\nsource: https://example.com/source\n---\n\nSee [docs](https://example.com/docs \"Docs\") and <https://example.org/>.\n\n```\nhttps://example.com/in-code\n```\n\n![img](http://cdn.example.com/a.png) [local](/about) https://skip.example.com/x\n"
+	refs := collectExternalLinks("note.md", body, []string{"https://skip.example.com/"})
+	want := []externalRef{
+		{URL: "https://example.com/source", File: "note.md", Line: 3},
+		{URL: "https://example.com/docs", File: "note.md", Line: 6},
+		{URL: "https://example.org/", File: "note.md", Line: 6},
+		{URL: "http://cdn.example.com/a.png", File: "note.md", Line: 12},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("got %+v, want %+v", refs, want)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Fatalf("ref %d: got %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+}
+
+func TestCheckExternalLinksFallbackAndCache(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/head-unsupported":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	opts := ExternalLinkOptions{
+		Concurrency:     4,
+		PerHostInterval: time.Millisecond,
+		Timeout:         50 * time.Millisecond,
+		CacheTTL:        time.Hour,
+		CachePath:       filepath.Join(t.TempDir(), "external.json"),
+	}
+	urls := []string{srv.URL + "/ok", srv.URL + "/head-unsupported", srv.URL + "/gone", srv.URL + "/slow"}
+	results, err := checkExternalLinks(context.Background(), urls, opts)
+	if err != nil {
+		t.Fatalf("checkExternalLinks: %v", err)
+	}
+	if got := results[srv.URL+"/ok"].Status; got != http.StatusOK {
+		t.Fatalf("/ok status = %d", got)
+	}
+	if got := results[srv.URL+"/head-unsupported"].Status; got != http.StatusOK {
+		t.Fatalf("/head-unsupported status = %d, want GET fallback 200", got)
+	}
+	if _, ok := externalDiagnostic(externalRef{URL: srv.URL + "/gone"}, results[srv.URL+"/gone"]); !ok {
+		t.Fatalf("expected dead link diagnostic for /gone")
+	}
+	if d, ok := externalDiagnostic(externalRef{URL: srv.URL + "/slow"}, results[srv.URL+"/slow"]); !ok || d.Code != "NP-LINK-EXTERNAL-UNREACHABLE" {
+		t.Fatalf("expected timeout diagnostic for /slow, got %+v", d)
+	}
+
+	before := atomic.LoadInt32(&hits)
+	if _, err := checkExternalLinks(context.Background(), urls[:3], opts); err != nil {
+		t.Fatalf("checkExternalLinks cached: %v", err)
+	}
+	if after := atomic.LoadInt32(&hits); after != before {
+		t.Fatalf("expected cached results, server hit %d more times", after-before)
+	}
+	// The timeout was not cached, so /slow is checked again.
+	if _, err := checkExternalLinks(context.Background(), urls[3:], opts); err != nil {
+		t.Fatalf("checkExternalLinks transient: %v", err)
+	}
+	if after := atomic.LoadInt32(&hits); after == before {
+		t.Fatalf("expected the timed out link to be re-checked")
+	}
+	// Only /slow was referenced in the last run, and it is not cached.
+	if cache := loadExternalCache(opts.CachePath); len(cache) != 0 {
+		t.Fatalf("expected unreferenced URLs to be pruned, cache = %v", cache)
+	}
+}
+
+func TestHostLimiterSpacesRequests(t *testing.T) {
+	l := newHostLimiter(20 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background(), "example.com"); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected requests spaced by interval, took %s", elapsed)
+	}
+}
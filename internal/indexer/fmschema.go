@@ -0,0 +1,275 @@
+package indexer
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+var fmSchemaTypes = map[string]bool{
+	"string":   true,
+	"number":   true,
+	"boolean":  true,
+	"date":     true,
+	"string[]": true,
+	"enum":     true,
+	"url":      true,
+	"pattern":  true,
+}
+
+func validateFMSchemaRules(cfg rules.Rules) error {
+	for name, field := range cfg.FMFields {
+		if !fmSchemaTypes[field.Type] {
+			return fmt.Errorf("fm_schema.%s: unknown type %q", name, field.Type)
+		}
+		if field.Type == "enum" && len(field.Values) == 0 {
+			return fmt.Errorf("fm_schema.%s: enum requires values", name)
+		}
+		if field.Type == "pattern" && field.Pattern == "" {
+			return fmt.Errorf("fm_schema.%s: pattern type requires pattern", name)
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("fm_schema.%s: invalid pattern: %w", name, err)
+			}
+		}
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return fmt.Errorf("fm_schema.%s: min is greater than max", name)
+		}
+		switch field.Severity {
+		case "", "error", "warn":
+		default:
+			return fmt.Errorf("fm_schema.%s: severity must be error or warn, got %q", name, field.Severity)
+		}
+	}
+	return nil
+}
+
+// checkFrontmatter validates frontmatter against fm_schema and the
+// required/optional fields of the note's type. Lines point into body.
+func checkFrontmatter(key string, body []byte, meta map[string]interface{}, typeName string, cfg rules.Rules) []MarkdownDiagnostic {
+	typeDef := cfg.Types[typeName]
+	if len(cfg.FMFields) == 0 && len(typeDef.Required) == 0 {
+		return nil
+	}
+	lines, startLine := frontmatterLines(body)
+	lineFor := func(field string) int {
+		if line, ok := lines[field]; ok {
+			return line
+		}
+		return startLine
+	}
+	out := make([]MarkdownDiagnostic, 0)
+	add := func(field, code, msg string) {
+		out = append(out, MarkdownDiagnostic{
+			Code:     code,
+			Severity: fmSeverity(cfg.FMFields[field], cfg),
+			File:     key,
+			Line:     lineFor(field),
+			Message:  msg,
+		})
+	}
+
+	for _, field := range typeDef.Required {
+		if fmValueEmpty(meta[field]) {
+			add(field, "NP-FM-REQUIRED", fmt.Sprintf("type %q requires field %q", typeName, field))
+		}
+	}
+
+	names := make([]string, 0, len(cfg.FMFields))
+	for name := range cfg.FMFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	allowed := map[string]bool{}
+	for _, field := range typeDef.Required {
+		allowed[field] = true
+	}
+	for _, field := range typeDef.Optional {
+		allowed[field] = true
+	}
+	for _, name := range names {
+		val, ok := meta[name]
+		if !ok || val == nil {
+			continue
+		}
+		if len(typeDef.Optional) > 0 && !allowed[name] {
+			add(name, "NP-FM-NOT-ALLOWED", fmt.Sprintf("field %q is not allowed for type %q", name, typeName))
+			continue
+		}
+		if code, msg := checkFieldValue(name, cfg.FMFields[name], val); code != "" {
+			add(name, code, msg)
+		}
+	}
+	return out
+}
+
+func fmSeverity(field rules.FieldSchema, cfg rules.Rules) string {
+	if field.Severity != "" {
+		return field.Severity
+	}
+	if isErrorAction(cfg.Validation.FMSchema) {
+		return "error"
+	}
+	return "warn"
+}
+
+func fmValueEmpty(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func checkFieldValue(name string, field rules.FieldSchema, val interface{}) (string, string) {
+	switch field.Type {
+	case "string", "pattern":
+		s, ok := fmScalarString(val)
+		if !ok {
+			return "NP-FM-TYPE", fmt.Sprintf("field %q must be a string", name)
+		}
+		if code, msg := checkFieldBounds(name, field, float64(utf8.RuneCountInString(s)), "length"); code != "" {
+			return code, msg
+		}
+		if field.Pattern != "" {
+			if re, err := regexp.Compile(field.Pattern); err == nil && !re.MatchString(s) {
+				return "NP-FM-PATTERN", fmt.Sprintf("field %q value %q does not match %s", name, s, field.Pattern)
+			}
+		}
+	case "number":
+		n, ok := fmNumber(val)
+		if !ok {
+			return "NP-FM-TYPE", fmt.Sprintf("field %q must be a number, got %v", name, val)
+		}
+		return checkFieldBounds(name, field, n, "value")
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			return "NP-FM-TYPE", fmt.Sprintf("field %q must be a boolean, got %v", name, val)
+		}
+	case "date":
+		if _, ok := val.(time.Time); ok {
+			return "", ""
+		}
+		if _, ok, err := timeFromMeta(map[string]interface{}{name: val}, name, time.UTC); err != nil || !ok {
+			return "NP-FM-DATE", fmt.Sprintf("field %q must be a date (YYYY-MM-DD or RFC 3339), got %v", name, val)
+		}
+	case "string[]":
+		list, ok := val.([]interface{})
+		if !ok {
+			return "NP-FM-TYPE", fmt.Sprintf("field %q must be a list of strings", name)
+		}
+		for _, item := range list {
+			if _, ok := fmScalarString(item); !ok {
+				return "NP-FM-TYPE", fmt.Sprintf("field %q must be a list of strings", name)
+			}
+		}
+		return checkFieldBounds(name, field, float64(len(list)), "item count")
+	case "enum":
+		values := []interface{}{val}
+		if list, ok := val.([]interface{}); ok {
+			values = list
+		}
+		for _, item := range values {
+			s, _ := fmScalarString(item)
+			if !containsString(field.Values, s) {
+				return "NP-FM-ENUM", fmt.Sprintf("field %q value %v is not one of %s", name, item, strings.Join(field.Values, ", "))
+			}
+		}
+	case "url":
+		s, ok := val.(string)
+		u, err := url.Parse(strings.TrimSpace(s))
+		if !ok || err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return "NP-FM-URL", fmt.Sprintf("field %q must be an absolute URL, got %v", name, val)
+		}
+	}
+	return "", ""
+}
+
+func checkFieldBounds(name string, field rules.FieldSchema, n float64, what string) (string, string) {
+	if field.Min != nil && n < *field.Min {
+		return "NP-FM-MIN", fmt.Sprintf("field %q %s %v is below min %v", name, what, n, *field.Min)
+	}
+	if field.Max != nil && n > *field.Max {
+		return "NP-FM-MAX", fmt.Sprintf("field %q %s %v is above max %v", name, what, n, *field.Max)
+	}
+	return "", ""
+}
+
+func fmScalarString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), true
+	case time.Time:
+		return v.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+func fmNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func containsString(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
+
+// frontmatterLines maps top-level frontmatter keys to their line in body and
+// returns the line of the opening `---` (1 when there is no frontmatter).
+func frontmatterLines(body []byte) (map[string]int, int) {
+	trimmed := bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF})
+	normalized := bytes.ReplaceAll(trimmed, []byte("\r\n"), []byte("\n"))
+	normalized = bytes.ReplaceAll(normalized, []byte("\r"), []byte("\n"))
+	start := bytes.TrimLeft(normalized, " \t\r\n")
+	if !bytes.HasPrefix(start, []byte("---")) {
+		return nil, 1
+	}
+	offset := bytes.Count(normalized[:len(normalized)-len(start)], []byte("\n"))
+	parts := bytes.SplitN(start, []byte("\n---"), 2)
+	if len(parts) != 2 {
+		return nil, offset + 1
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(bytes.TrimPrefix(parts[0], []byte("---")), &doc); err != nil || len(doc.Content) == 0 {
+		return nil, offset + 1
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, offset + 1
+	}
+	out := make(map[string]int, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		out[mapping.Content[i].Value] = offset + mapping.Content[i].Line
+	}
+	return out, offset + 1
+}
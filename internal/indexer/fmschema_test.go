@@ -0,0 +1,124 @@
+package indexer
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func loadTestRules(t *testing.T, src string) rules.Rules {
+	t.Helper()
+	var cfg rules.Rules
+	if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatalf("unmarshal rules: %v", err)
+	}
+	if err := validateFMSchemaRules(cfg); err != nil {
+		t.Fatalf("validateFMSchemaRules: %v", err)
+	}
+	return cfg
+}
+
+func TestCheckFrontmatterReportsTypedViolations(t *testing.T) {
+	cfg := loadTestRules(t, `
+fm_schema:
+  draft: boolean
+  price:
+    type: number
+    min: 0
+    severity: error
+  status:
+    type: enum
+    values: [draft, published]
+  sku:
+    type: pattern
+    pattern: "^SKU-[0-9]+$"
+  homepage: url
+  published: date
+  tags: string[]
+types:
+  good:
+    required: [price, sku]
+validation:
+  fm_schema:
+    action: warn
+`)
+	body := []byte("---\ntype: good\ndraft: \"yes\"\nprice: -5\nstatus: archived\nsku: ABC\nhomepage: example.com\npublished: someday\ntags: one\n---\nbody\n")
+	meta, _, err := parseFrontmatter(body)
+	if err != nil {
+		t.Fatalf("parseFrontmatter: %v", err)
+	}
+	diags := checkFrontmatter("products/widget.md", body, meta, "good", cfg)
+	want := map[string]struct {
+		line     int
+		severity string
+	}{
+		"NP-FM-TYPE":    {3, "warn"},
+		"NP-FM-MIN":     {4, "error"},
+		"NP-FM-ENUM":    {5, "warn"},
+		"NP-FM-PATTERN": {6, "warn"},
+		"NP-FM-URL":     {7, "warn"},
+		"NP-FM-DATE":    {8, "warn"},
+	}
+	got := map[string]MarkdownDiagnostic{}
+	for _, d := range diags {
+		if _, seen := got[d.Code]; !seen {
+			got[d.Code] = d
+		}
+	}
+	for code, exp := range want {
+		d, ok := got[code]
+		if !ok {
+			t.Fatalf("missing %s in %#v", code, diags)
+		}
+		if d.Line != exp.line || d.Severity != exp.severity || d.File != "products/widget.md" {
+			t.Fatalf("%s = %+v, want line %d severity %s", code, d, exp.line, exp.severity)
+		}
+	}
+	if len(diags) != 7 {
+		t.Fatalf("expected 7 diagnostics (incl. tags), got %d: %#v", len(diags), diags)
+	}
+}
+
+func TestCheckFrontmatterRequiredAndOptional(t *testing.T) {
+	cfg := loadTestRules(t, `
+fm_schema:
+  price: number
+  weight: number
+  author: string
+types:
+  good:
+    required: [price]
+    optional: [weight]
+`)
+	body := []byte("\n---\ntitle: Widget\nauthor: Ann\n---\n")
+	meta, _, _ := parseFrontmatter(body)
+	diags := checkFrontmatter("widget.md", body, meta, "good", cfg)
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %#v", diags)
+	}
+	if diags[0].Code != "NP-FM-REQUIRED" || diags[0].Line != 2 {
+		t.Fatalf("unexpected required diagnostic: %+v", diags[0])
+	}
+	if diags[1].Code != "NP-FM-NOT-ALLOWED" || diags[1].Line != 4 {
+		t.Fatalf("unexpected not-allowed diagnostic: %+v", diags[1])
+	}
+}
+
+func TestValidateFMSchemaRulesRejectsBadSpecs(t *testing.T) {
+	for _, src := range []string{
+		"fm_schema:\n  a: text\n",
+		"fm_schema:\n  a: {type: enum}\n",
+		"fm_schema:\n  a: {type: pattern, pattern: \"[\"}\n",
+		"fm_schema:\n  a: {type: number, min: 5, max: 1}\n",
+	} {
+		var cfg rules.Rules
+		if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if err := validateFMSchemaRules(cfg); err == nil {
+			t.Fatalf("expected error for %q", src)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -21,13 +22,12 @@ import (
 
 	"gopkg.in/yaml.v3"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/cookiespooky/notepub/internal/config"
 	"github.com/cookiespooky/notepub/internal/linkutil"
-	"github.com/cookiespooky/notepub/internal/localutil"
 	"github.com/cookiespooky/notepub/internal/mdproc"
 	"github.com/cookiespooky/notepub/internal/mediautil"
 	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/mountutil"
 	"github.com/cookiespooky/notepub/internal/rules"
 	"github.com/cookiespooky/notepub/internal/s3util"
 	"github.com/cookiespooky/notepub/internal/urlutil"
@@ -41,6 +41,21 @@ const (
 )
 
 func Run(ctx context.Context, cfg config.Config) error {
+	_, err := RunWithReport(ctx, cfg, RunOptions{})
+	return err
+}
+
+// RunWithReport indexes like Run and also returns a report of what changed
+// since the previous snapshot and every diagnostic found. Notes that fail
+// validation do not stop the run; all of them are reported together, and
+// opts decides whether they fail it.
+func RunWithReport(ctx context.Context, cfg config.Config, opts RunOptions) (Report, error) {
+	report := Report{Diagnostics: []MarkdownDiagnostic{}}
+	err := runIndex(ctx, cfg, opts, &report)
+	return report, err
+}
+
+func runIndex(ctx context.Context, cfg config.Config, opts RunOptions, report *Report) error {
 	artifactsDir := cfg.Paths.ArtifactsDir
 	snapshotPath := cfg.Paths.SnapshotFile
 	snapshotDir := filepath.Dir(snapshotPath)
@@ -74,42 +89,35 @@ func Run(ctx context.Context, cfg config.Config) error {
 	if err != nil {
 		return fmt.Errorf("load rules: %w", err)
 	}
-	if err := ValidateRules(rulesCfg); err != nil {
+	warnings, err := ValidateRules(rulesCfg)
+	if err != nil {
 		return err
 	}
-
-	var (
-		objects  []s3util.Object
-		s3client *s3.Client
-	)
-	switch cfg.Content.Source {
-	case "local":
-		objects, err = localutil.ListMarkdown(cfg.Content.LocalDir, cfg.S3.Prefix)
-		if err != nil {
-			return fmt.Errorf("list local: %w", err)
-		}
-	case "s3":
-		s3client, err = s3util.NewClient(ctx, s3util.Config{
-			Endpoint:       cfg.S3.Endpoint,
-			Region:         cfg.S3.Region,
-			ForcePathStyle: cfg.S3.ForcePathStyle,
-			Bucket:         cfg.S3.Bucket,
-			Prefix:         cfg.S3.Prefix,
-			AccessKey:      cfg.S3.AccessKey,
-			SecretKey:      cfg.S3.SecretKey,
-			Anonymous:      cfg.S3.Anonymous,
-		})
-		if err != nil {
-			return fmt.Errorf("s3 client: %w", err)
-		}
-		objects, err = s3util.ListObjects(ctx, s3client, cfg.S3.Bucket, cfg.S3.Prefix)
-		if err != nil {
-			return fmt.Errorf("list s3: %w", err)
+	for _, w := range warnings {
+		log.Printf("rules warning: %s", w)
+	}
+	loc := cfg.Location()
+	var themeTemplates map[string]bool
+	if opts.Templates != nil {
+		themeTemplates = map[string]bool{}
+		for _, name := range opts.Templates {
+			themeTemplates[name] = true
 		}
-	default:
-		return fmt.Errorf("unsupported content source: %s", cfg.Content.Source)
 	}
 
+	s3client, err := mountutil.NewClient(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("s3 client: %w", err)
+	}
+	reader := mountutil.NewReader(cfg, s3client)
+	objects, err := reader.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list content: %w", err)
+	}
+
+	noteBodies := map[string][]byte{}
+	cascades, cascadeDiags := loadCascades(ctx, reader, objects, noteBodies)
+
 	current := map[string]s3util.Object{}
 	for _, obj := range objects {
 		if !strings.HasSuffix(strings.ToLower(obj.Key), ".md") {
@@ -138,11 +146,12 @@ func Run(ctx context.Context, cfg config.Config) error {
 		Links:       map[string]map[string][]string{},
 		LinkTargets: map[string]map[string][]string{},
 		Media:       map[string][]string{},
+		Mounts:      reader.Info(),
 	}
 	usedPaths := map[string]bool{}
 	usedSlugs := map[string]bool{}
 	typeCounts := map[string]int{}
-	errors := []string{}
+	diags := append([]MarkdownDiagnostic{}, cascadeDiags...)
 
 	for _, key := range keys {
 		obj := current[key]
@@ -150,23 +159,48 @@ func Run(ctx context.Context, cfg config.Config) error {
 		if obj.LastModified != nil {
 			lm = obj.LastModified.UTC().Format(time.RFC3339)
 		}
-		newSnapshot[key] = models.SnapshotEntry{ETag: obj.ETag, LastModified: lm}
+		cascade := cascades.forKey(key)
+		newSnapshot[key] = models.SnapshotEntry{ETag: obj.ETag, LastModified: lm, Cascade: cascade.Signature}
+		if cascades.failed[key] {
+			continue
+		}
+		if cascade.Broken != "" {
+			diags = append(diags, MarkdownDiagnostic{Code: "NP-INDEX-CASCADE", Severity: "error", File: key, Line: 1, Message: fmt.Sprintf("folder defaults from %s could not be loaded", cascade.Broken)})
+			continue
+		}
 
 		old, ok := oldSnapshot[key]
-		if ok && old.ETag == obj.ETag {
+		if ok && old.ETag == obj.ETag && old.Cascade == cascade.Signature {
 			if p := oldKeyToPath[key]; p != "" {
 				meta := oldIndex.Meta[p]
 				route := oldIndex.Routes[p]
 				if meta.FM == nil {
 					ok = false
 				}
-				if ok && (oldIndex.LinkTargets == nil || oldIndex.LinkTargets[p] == nil) {
+				// Notes with fm_schema findings under the current rules are
+				// re-read so their diagnostics keep pointing at the right lines.
+				if ok && (oldIndex.LinkTargets == nil || oldIndex.LinkTargets[p] == nil || len(checkFrontmatter(key, nil, meta.FM, meta.Type, rulesCfg)) > 0) {
 					ok = false
 				} else {
-					if err := validateExisting(p, meta, rulesCfg, usedPaths, usedSlugs, typeCounts); err != nil {
-						errors = append(errors, fmt.Sprintf("%s: %s", key, err))
+					nd := newNoteDiagnostics(key, nil)
+					if err := validateExisting(p, meta, rulesCfg, usedPaths, usedSlugs); err != nil {
+						diags = append(diags, nd.fromErr("NP-INDEX-ERROR", "", err))
+						continue
+					}
+					if err := applyNoteDates(&meta, meta.FM, loc); err != nil {
+						diags = append(diags, nd.fromErr("NP-INDEX-DATE", "", err))
+						continue
+					}
+					meta.Draft = boolFromMeta(meta.FM, "draft")
+					meta.Template, meta.Layout = templateOverrides(meta.FM)
+					if failed := appendDiagnostics(&diags, checkTemplateOverrides(nd, meta, rulesCfg, themeTemplates)); failed {
 						continue
 					}
+					slugKey := ""
+					if meta.Slug != "" {
+						slugKey = slugScopeKey(meta.Lang, meta.Slug)
+					}
+					reserveNote(usedPaths, usedSlugs, typeCounts, p, slugKey, meta.Type)
 					route.LastModified = lm
 					newIndex.Meta[p] = meta
 					newIndex.Routes[p] = route
@@ -185,73 +219,134 @@ func Run(ctx context.Context, cfg config.Config) error {
 			}
 		}
 
-		var body []byte
-		switch cfg.Content.Source {
-		case "local":
-			body, err = localutil.FetchObject(cfg.Content.LocalDir, key)
-		case "s3":
-			body, err = s3util.FetchObject(ctx, s3client, cfg.S3.Bucket, key)
-		}
-		if err != nil {
-			return fmt.Errorf("fetch %s: %w", key, err)
+		body, ok := noteBodies[key]
+		if !ok {
+			body, err = reader.Fetch(ctx, key)
+			if err != nil {
+				diags = append(diags, MarkdownDiagnostic{Code: "NP-MD-READ-ERROR", Severity: "error", File: key, Line: 1, Message: err.Error()})
+				continue
+			}
 		}
 		metaMap, content, err := parseFrontmatter(body)
 		if err != nil {
-			return fmt.Errorf("parse frontmatter %s: %w", key, err)
+			d := MarkdownDiagnostic{Code: "NP-MD-FRONTMATTER-ERROR", Severity: "error", File: key, Line: 1, Message: err.Error()}
+			var fmErr *FrontmatterError
+			if errors.As(err, &fmErr) && fmErr.Line > 0 {
+				d.Line = fmErr.Line
+			}
+			diags = append(diags, d)
+			continue
+		}
+		nd := newNoteDiagnostics(key, body)
+		vaultKey := strings.TrimPrefix(strings.TrimPrefix(mountutil.LogicalKey(key, newIndex.Mounts), cfg.S3.Prefix), "/")
+		typeRule := ""
+		if stringFromMeta(metaMap, "type") == "" && stringFromMeta(cascade.Values, "type") != "" {
+			typeRule = "cascade " + cascade.Sources["type"]
+		}
+		applyFMDefaults(metaMap, cascade.Values)
+		if stringFromMeta(metaMap, "type") == "" {
+			if name, rule, ok := inferType(rulesCfg, vaultKey, metaMap); ok {
+				metaMap["type"] = name
+				typeRule = rule
+			} else if m, _, ok := reader.Lookup(key); ok && m.TypesDefault != "" {
+				metaMap["type"] = m.TypesDefault
+				typeRule = "content.mounts." + m.Name + ".types_default"
+			} else if _, ok := rulesCfg.Fields.Defaults["type"]; ok {
+				typeRule = "fields.defaults.type"
+			}
 		}
 		applyFMDefaults(metaMap, rulesCfg.Fields.Defaults)
 
 		core, err := buildCore(metaMap, rulesCfg)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %s", key, err))
+			diags = append(diags, nd.fromErr("NP-INDEX-REQUIRED", "", err))
 			continue
 		}
 		typeDef, ok := rulesCfg.Types[core.Type]
 		if !ok {
 			if isErrorAction(rulesCfg.Validation.UnknownType) {
-				errors = append(errors, fmt.Sprintf("%s: unknown type %q", key, core.Type))
+				diags = append(diags, nd.diag("NP-INDEX-UNKNOWN-TYPE", "type", fmt.Sprintf("unknown type %q", core.Type)))
 			} else {
 				log.Printf("unknown type %q (skipped): %s", core.Type, key)
 			}
 			continue
 		}
 		if typeDef.Template == "" && rulesCfg.Validation.MissingTemplate.Action == "error" {
-			errors = append(errors, fmt.Sprintf("%s: missing template for type %q", key, core.Type))
+			diags = append(diags, nd.diag("NP-INDEX-MISSING-TEMPLATE", "type", fmt.Sprintf("missing template for type %q", core.Type)))
 			continue
 		}
 		if strings.TrimSpace(typeDef.Permalink) == "" {
-			errors = append(errors, fmt.Sprintf("%s: missing permalink for type %q", key, core.Type))
+			diags = append(diags, nd.diag("NP-INDEX-MISSING-PERMALINK", "type", fmt.Sprintf("missing permalink for type %q", core.Type)))
+			continue
+		}
+		schemaFailed := false
+		for _, d := range checkFrontmatter(key, body, metaMap, core.Type, rulesCfg) {
+			diags = append(diags, d)
+			if d.Severity == "error" {
+				schemaFailed = true
+			}
+		}
+		if schemaFailed {
 			continue
 		}
-		pathVal, err := buildPermalink(typeDef.Permalink, core.Slug, rulesCfg)
+		if core.Slug == "" && typeDef.AutoSlug != "" {
+			core.Slug = autoSlug(typeDef.AutoSlug, core.Title, vaultKey)
+		}
+		pathVal, err := buildPermalink(typeDef.Permalink, permalinkVars{
+			Slug: core.Slug,
+			Type: core.Type,
+			Key:  vaultKey,
+			Meta: metaMap,
+			Loc:  loc,
+		}, rulesCfg)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %s", key, err))
+			diags = append(diags, nd.fromErr("NP-INDEX-PERMALINK", "", err))
 			continue
 		}
+		langCfg, err := noteLanguage(metaMap, cfg)
+		if err != nil {
+			diags = append(diags, nd.fromErr("NP-INDEX-LANG", "lang", err))
+			continue
+		}
+		pathVal = languagePath(langCfg.Prefix, pathVal)
+
+		metaEntry := buildMetaEntry(metaMap, core, content, cfg.Site, cfg.OGTypeByType, pathVal, key, cfg.S3.Prefix)
+		metaEntry.Lang = langCfg.Code
+		metaEntry.TranslationKey = stringFromMeta(metaMap, "translation_key")
+		metaEntry.TypeRule = typeRule
+		metaEntry.Draft = boolFromMeta(metaMap, "draft")
+		if err := applyNoteDates(&metaEntry, metaMap, loc); err != nil {
+			diags = append(diags, nd.fromErr("NP-INDEX-DATE", "", err))
+			continue
+		}
+		metaEntry.Template, metaEntry.Layout = templateOverrides(metaMap)
+		if failed := appendDiagnostics(&diags, checkTemplateOverrides(nd, metaEntry, rulesCfg, themeTemplates)); failed {
+			continue
+		}
+		// Route, slug and type count are only reserved once every check has
+		// passed, so a failing note never blocks a later one.
 		if usedPaths[pathVal] {
 			if isErrorAction(rulesCfg.Validation.DuplicateRoute) {
-				errors = append(errors, fmt.Sprintf("%s: duplicate route %q", key, pathVal))
+				diags = append(diags, nd.diag("NP-INDEX-DUPLICATE-ROUTE", "", fmt.Sprintf("duplicate route %q", pathVal)))
 				continue
 			}
 			log.Printf("duplicate route %q (first wins): %s", pathVal, key)
 			continue
 		}
+		slugKey := ""
 		if core.Slug != "" {
-			slugKey := strings.ToLower(core.Slug)
+			slugKey = slugScopeKey(langCfg.Code, core.Slug)
 			if usedSlugs[slugKey] {
 				if isErrorAction(rulesCfg.Validation.UniqueSlug) {
-					errors = append(errors, fmt.Sprintf("%s: duplicate slug %q", key, core.Slug))
+					diags = append(diags, nd.diag("NP-INDEX-DUPLICATE-SLUG", "slug", fmt.Sprintf("duplicate slug %q", core.Slug)))
 					continue
 				}
 				log.Printf("duplicate slug %q (first wins): %s", core.Slug, key)
 				continue
 			}
-			usedSlugs[slugKey] = true
 		}
-		usedPaths[pathVal] = true
-		typeCounts[core.Type]++
+		reserveNote(usedPaths, usedSlugs, typeCounts, pathVal, slugKey, core.Type)
 
-		metaEntry := buildMetaEntry(metaMap, core, content, cfg.Site, cfg.OGTypeByType, pathVal, key, cfg.S3.Prefix)
 		routeEntry := buildRouteEntry(metaMap, metaEntry, key, obj.ETag, lm, pathVal)
 		mediaKeys := extractMediaKeysFromContent(string(content), key, cfg.S3.Prefix)
 		if len(mediaKeys) > 0 {
@@ -263,11 +358,33 @@ func Run(ctx context.Context, cfg config.Config) error {
 		newIndex.LinkTargets[pathVal] = extractRawLinkTargets(metaMap, content, rulesCfg)
 	}
 
-	if len(errors) > 0 {
-		for _, msg := range errors {
-			log.Printf("index validation: %s", msg)
+	translations, translationDiags := buildTranslations(newIndex)
+	diags = append(diags, translationDiags...)
+	newIndex.Translations = translations
+	redirects, redirectDiags := buildRedirects(oldIndex, newIndex)
+	diags = append(diags, redirectDiags...)
+	if len(redirects) > 0 {
+		newIndex.Redirects = redirects
+	}
+
+	report.Added, report.Changed, report.Removed, report.Unchanged = snapshotCounts(oldSnapshot, newSnapshot)
+	if opts.KeepGoing {
+		diags, report.Quarantined = quarantineNotes(&newIndex, oldIndex, diags, opts.KeepPrevious)
+		// Quarantined notes are re-read next run, even if unchanged.
+		for _, q := range report.Quarantined {
+			delete(newSnapshot, q.File)
 		}
-		return fmt.Errorf("index validation failed (%d errors)", len(errors))
+	}
+	report.Diagnostics = diags
+	for _, d := range diags {
+		if d.Severity == "error" {
+			log.Printf("index validation: %s:%d: %s (%s)", d.File, d.Line, d.Message, d.Code)
+		} else {
+			log.Printf("index warning: %s:%d: %s (%s)", d.File, d.Line, d.Message, d.Code)
+		}
+	}
+	if errCount, _ := CountDiagnostics(diags); !opts.tolerates(errCount) {
+		return &ValidationError{Diagnostics: diags}
 	}
 
 	if err := validateTypeCounts(typeCounts, rulesCfg.Validation.SinglePageOfType); err != nil {
@@ -276,7 +393,14 @@ func Run(ctx context.Context, cfg config.Config) error {
 
 	links, err := resolveLinks(newIndex, rulesCfg, cfg.S3.Prefix)
 	if err != nil {
-		return err
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			return err
+		}
+		report.Diagnostics = append(report.Diagnostics, verr.Diagnostics...)
+		if errCount, _ := CountDiagnostics(report.Diagnostics); !opts.tolerates(errCount) {
+			return &ValidationError{Diagnostics: report.Diagnostics}
+		}
 	}
 	newIndex.Links = links
 	newIndex.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
@@ -287,16 +411,22 @@ func Run(ctx context.Context, cfg config.Config) error {
 	if err := writeAtomicJSON(snapshotPath, newSnapshot); err != nil {
 		return fmt.Errorf("write snapshot: %w", err)
 	}
-	if err := writeSitemaps(artifactsDir, cfg.Site.BaseURL, newIndex, rulesCfg); err != nil {
+	if err := writeQuarantine(artifactsDir, report.Quarantined); err != nil {
+		return fmt.Errorf("write quarantine: %w", err)
+	}
+	// Drafts, scheduled and expired notes stay in resolve.json; public
+	// artifacts only list published ones.
+	published, _ := PublishedView(ExcludeDrafts(newIndex), time.Now())
+	if err := writeSitemaps(artifactsDir, cfg.Site.BaseURL, cfg.DefaultLanguage(), published, rulesCfg); err != nil {
 		return fmt.Errorf("write sitemap: %w", err)
 	}
 	if err := writeRobots(artifactsDir, cfg.Site.BaseURL, cfg.Robots); err != nil {
 		return fmt.Errorf("write robots: %w", err)
 	}
-	if err := writeSearchIndex(artifactsDir, newIndex, rulesCfg); err != nil {
+	if err := writeSearchIndex(artifactsDir, published, rulesCfg); err != nil {
 		return fmt.Errorf("write search: %w", err)
 	}
-	if err := materializeCollections(artifactsDir, newIndex, rulesCfg); err != nil {
+	if err := materializeCollections(artifactsDir, published, rulesCfg); err != nil {
 		return fmt.Errorf("materialize collections: %w", err)
 	}
 
@@ -378,50 +508,24 @@ func buildCore(meta map[string]interface{}, cfg rules.Rules) (coreFields, error)
 			continue
 		case "type":
 			if core.Type == "" {
-				return core, fmt.Errorf("missing required field type")
+				return core, newFieldError("type", "missing required field type")
 			}
 		case "title":
 			if core.Title == "" {
-				return core, fmt.Errorf("missing required field title")
+				return core, newFieldError("title", "missing required field title")
 			}
 		default:
 			if stringFromMeta(meta, field) == "" {
-				return core, fmt.Errorf("missing required field %s", field)
+				return core, newFieldError(field, "missing required field %s", field)
 			}
 		}
 	}
 	if core.Type == "" {
-		return core, fmt.Errorf("missing required field type")
+		return core, newFieldError("type", "missing required field type")
 	}
 	return core, nil
 }
 
-func buildPermalink(template, slugVal string, cfg rules.Rules) (string, error) {
-	if template == "" {
-		return "", fmt.Errorf("missing permalink template")
-	}
-	if strings.Contains(template, "{{ slug }}") || strings.Contains(template, "{{slug}}") {
-		if strings.TrimSpace(slugVal) == "" {
-			if cfg.Validation.PermalinkRequiresSlug.Action == "error" {
-				return "", fmt.Errorf("slug required by permalink")
-			}
-		}
-		template = strings.ReplaceAll(template, "{{ slug }}", slugVal)
-		template = strings.ReplaceAll(template, "{{slug}}", slugVal)
-	}
-	pathVal := strings.TrimSpace(template)
-	if pathVal == "" {
-		return "", fmt.Errorf("empty permalink")
-	}
-	if !strings.HasPrefix(pathVal, "/") {
-		pathVal = "/" + pathVal
-	}
-	if pathVal != "/" {
-		pathVal = strings.TrimRight(pathVal, "/")
-	}
-	return pathVal, nil
-}
-
 func filenameBase(key string) string {
 	base := filepath.Base(key)
 	return strings.TrimSuffix(base, filepath.Ext(base))
@@ -537,7 +641,11 @@ func parseFrontmatter(body []byte) (map[string]interface{}, []byte, error) {
 		if len(parts) == 2 {
 			header := bytes.TrimPrefix(parts[0], []byte("---"))
 			if err := yaml.Unmarshal(header, &meta); err != nil {
-				return nil, nil, fmt.Errorf("frontmatter yaml: %w", err)
+				fmErr := &FrontmatterError{Err: err}
+				if line := yamlErrorLine(err); line > 0 {
+					fmErr.Line = bytes.Count(normalized[:len(normalized)-len(start)], []byte("\n")) + line
+				}
+				return nil, nil, fmErr
 			}
 			content = parts[1]
 		}
@@ -554,52 +662,59 @@ func applyFMDefaults(meta map[string]interface{}, defaults map[string]interface{
 	}
 }
 
-func validateExisting(pathVal string, meta models.MetaEntry, cfg rules.Rules, usedPaths, usedSlugs map[string]bool, typeCounts map[string]int) error {
+func validateExisting(pathVal string, meta models.MetaEntry, cfg rules.Rules, usedPaths, usedSlugs map[string]bool) error {
 	if meta.Type == "" {
-		return fmt.Errorf("missing type for %s", pathVal)
+		return newNoteError("NP-INDEX-REQUIRED", "type", "missing type for %s", pathVal)
 	}
 	typeDef, ok := cfg.Types[meta.Type]
 	if !ok {
 		if isErrorAction(cfg.Validation.UnknownType) {
-			return fmt.Errorf("unknown type %q", meta.Type)
+			return newNoteError("NP-INDEX-UNKNOWN-TYPE", "type", "unknown type %q", meta.Type)
 		}
 		log.Printf("unknown type %q (skipped): %s", meta.Type, pathVal)
-		return fmt.Errorf("unknown type %q", meta.Type)
+		return newNoteError("NP-INDEX-UNKNOWN-TYPE", "type", "unknown type %q", meta.Type)
 	}
 	if typeDef.Template == "" && cfg.Validation.MissingTemplate.Action == "error" {
-		return fmt.Errorf("missing template for type %q", meta.Type)
+		return newNoteError("NP-INDEX-MISSING-TEMPLATE", "type", "missing template for type %q", meta.Type)
 	}
 	if strings.TrimSpace(typeDef.Permalink) == "" {
-		return fmt.Errorf("missing permalink for type %q", meta.Type)
+		return newNoteError("NP-INDEX-MISSING-PERMALINK", "type", "missing permalink for type %q", meta.Type)
 	}
-	if strings.Contains(typeDef.Permalink, "{{ slug }}") && strings.TrimSpace(meta.Slug) == "" {
+	if permalinkUsesVar(typeDef.Permalink, "slug") && strings.TrimSpace(meta.Slug) == "" {
 		if cfg.Validation.PermalinkRequiresSlug.Action == "error" {
-			return fmt.Errorf("slug required by permalink")
+			return newNoteError("NP-INDEX-PERMALINK", "slug", "slug required by permalink")
 		}
 	}
 	if usedPaths[pathVal] {
 		if isErrorAction(cfg.Validation.DuplicateRoute) {
-			return fmt.Errorf("duplicate route %q", pathVal)
+			return newNoteError("NP-INDEX-DUPLICATE-ROUTE", "", "duplicate route %q", pathVal)
 		}
 		log.Printf("duplicate route %q (first wins)", pathVal)
-		return fmt.Errorf("duplicate route %q", pathVal)
+		return newNoteError("NP-INDEX-DUPLICATE-ROUTE", "", "duplicate route %q", pathVal)
 	}
-	usedPaths[pathVal] = true
 	if meta.Slug != "" {
-		slugKey := strings.ToLower(meta.Slug)
+		slugKey := slugScopeKey(meta.Lang, meta.Slug)
 		if usedSlugs[slugKey] {
 			if isErrorAction(cfg.Validation.UniqueSlug) {
-				return fmt.Errorf("duplicate slug %q", meta.Slug)
+				return newNoteError("NP-INDEX-DUPLICATE-SLUG", "slug", "duplicate slug %q", meta.Slug)
 			}
 			log.Printf("duplicate slug %q (first wins)", meta.Slug)
-			return fmt.Errorf("duplicate slug %q", meta.Slug)
+			return newNoteError("NP-INDEX-DUPLICATE-SLUG", "slug", "duplicate slug %q", meta.Slug)
 		}
-		usedSlugs[slugKey] = true
 	}
-	typeCounts[meta.Type]++
 	return nil
 }
 
+// reserveNote records the route, slug (slugKey may be empty) and type of an
+// accepted note.
+func reserveNote(usedPaths, usedSlugs map[string]bool, typeCounts map[string]int, pathVal, slugKey, typeName string) {
+	usedPaths[pathVal] = true
+	if slugKey != "" {
+		usedSlugs[slugKey] = true
+	}
+	typeCounts[typeName]++
+}
+
 func validateTypeCounts(counts map[string]int, rules map[string]int) error {
 	for typeName, expected := range rules {
 		if counts[typeName] != expected {
@@ -609,21 +724,51 @@ func validateTypeCounts(counts map[string]int, rules map[string]int) error {
 	return nil
 }
 
-func ValidateRules(cfg rules.Rules) error {
+// ValidateRules checks rules beyond what strict decoding catches and reports
+// every problem found, joined into one error. Warnings do not fail validation
+// and are returned for the caller to show.
+func ValidateRules(cfg rules.Rules) ([]string, error) {
+	var errs []error
+	for _, name := range sortedTypeNames(cfg) {
+		typeDef := cfg.Types[name]
+		if err := validatePermalinkTemplate(typeDef.Permalink); err != nil {
+			errs = append(errs, fmt.Errorf("type %q: %w", name, err))
+		}
+		if err := validateTypeMatches(name, typeDef); err != nil {
+			errs = append(errs, err)
+		}
+		switch typeDef.AutoSlug {
+		case "", "title", "filename":
+		default:
+			errs = append(errs, fmt.Errorf("type %q: auto_slug must be title or filename, got %q", name, typeDef.AutoSlug))
+		}
+		if _, err := cleanTypeDir(typeDef.Dir); err != nil {
+			errs = append(errs, fmt.Errorf("type %q: %w", name, err))
+		}
+	}
+	if err := validateFMSchemaRules(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ExternalLinkOptionsFor(config.Config{}, cfg.Validation.ExternalLinks); err != nil {
+		errs = append(errs, err)
+	}
 	if cfg.Validation.MaterializeRequiresLimit || cfg.Validation.MaterializeGroupByRequiresItemLimit {
-		for name, col := range cfg.Collections {
+		for _, name := range sortedKeys(cfg.Collections) {
+			col := cfg.Collections[name]
 			if !col.Materialize {
 				continue
 			}
 			if cfg.Validation.MaterializeRequiresLimit && col.Limit == 0 {
-				return fmt.Errorf("collection %q materialize requires limit", name)
+				errs = append(errs, fmt.Errorf("collection %q materialize requires limit", name))
 			}
 			if cfg.Validation.MaterializeGroupByRequiresItemLimit && col.GroupBy.By != "" && col.GroupBy.ItemLimit == 0 {
-				return fmt.Errorf("collection %q materialize requires group_by.item_limit", name)
+				errs = append(errs, fmt.Errorf("collection %q materialize requires group_by.item_limit", name))
 			}
 		}
 	}
-	return nil
+	refErrs, warnings := validateRuleReferences(cfg)
+	errs = append(errs, refErrs...)
+	return warnings, errors.Join(errs...)
 }
 
 func ValidateResolveLinks(idx models.ResolveIndex, cfg rules.Rules, prefix string) error {
@@ -912,8 +1057,8 @@ func normalizeTarget(raw string) string {
 
 func resolveLinks(idx models.ResolveIndex, cfg rules.Rules, prefix string) (map[string]map[string][]string, error) {
 	out := map[string]map[string][]string{}
-	errors := []string{}
-	resolver, err := buildResolverIndex(idx, prefix)
+	diags := []MarkdownDiagnostic{}
+	resolvers, err := buildLangResolvers(idx, prefix)
 	if err != nil {
 		return out, err
 	}
@@ -931,10 +1076,17 @@ func resolveLinks(idx models.ResolveIndex, cfg rules.Rules, prefix string) (map[
 				continue
 			}
 			for _, target := range targets {
-				resolved, _, err := ResolveLink(target, rule.ResolveBy, rule.Resolve, resolver)
+				resolved, _, err := resolvers.resolve(meta.Lang, target, rule.ResolveBy, rule.Resolve)
 				if err != nil {
 					if shouldErrorOnResolve(err, rule.Resolve) {
-						errors = append(errors, fmt.Sprintf("%s: %s", pathVal, err.Error()))
+						diags = append(diags, MarkdownDiagnostic{
+							Code:     "NP-INDEX-LINK",
+							Severity: "error",
+							File:     idx.Routes[pathVal].S3Key,
+							Line:     1,
+							Field:    rule.Field,
+							Message:  err.Error(),
+						})
 					} else {
 						handleResolveError(err, rule.Resolve, target, pathVal, rule.Name)
 					}
@@ -957,16 +1109,28 @@ func resolveLinks(idx models.ResolveIndex, cfg rules.Rules, prefix string) (map[
 			}
 		}
 	}
-	if len(errors) > 0 {
-		for _, msg := range errors {
-			log.Printf("link resolve error: %s", msg)
+	if len(diags) > 0 {
+		sort.Slice(diags, func(i, j int) bool { return diags[i].File < diags[j].File })
+		for _, d := range diags {
+			log.Printf("link resolve error: %s: %s", d.File, d.Message)
 		}
-		return out, fmt.Errorf("link resolve failed (%d errors)", len(errors))
+		return out, &ValidationError{Diagnostics: diags}
 	}
 	return out, nil
 }
 
 func buildResolverIndex(idx models.ResolveIndex, prefix string) (resolverIndex, error) {
+	res, wikiErrors := collectResolverIndex(idx, prefix)
+	if len(wikiErrors) > 0 {
+		for _, msg := range wikiErrors {
+			log.Printf("wikimap collision: %s", msg)
+		}
+		return res, fmt.Errorf("wikimap collisions (%d)", len(wikiErrors))
+	}
+	return res, nil
+}
+
+func collectResolverIndex(idx models.ResolveIndex, prefix string) (resolverIndex, []string) {
 	res := resolverIndex{
 		byPath:          map[string]string{},
 		byPathLower:     map[string]string{},
@@ -978,46 +1142,55 @@ func buildResolverIndex(idx models.ResolveIndex, prefix string) (resolverIndex,
 		byWiki:          map[string]string{},
 	}
 	wikiErrors := []string{}
-	for pathVal, route := range idx.Routes {
+	// Paths are visited in mount precedence order; keys already claimed by a
+	// higher-precedence mount shadow later mounts instead of colliding.
+	wikiRank := map[string]int{}
+	filenameRank := map[string]int{}
+	addWiki := func(key, pathVal string, rank int) {
+		norm := normalizeWikiKey(key)
+		if r, ok := wikiRank[norm]; ok && r < rank {
+			return
+		}
+		if err := addWikiKey(res.byWiki, key, pathVal); err != nil {
+			wikiErrors = append(wikiErrors, err.Error())
+			return
+		}
+		wikiRank[norm] = rank
+	}
+	for _, pathVal := range mountutil.RankedPaths(idx) {
+		route := idx.Routes[pathVal]
 		meta, ok := idx.Meta[pathVal]
 		if !ok {
 			continue
 		}
+		rank := mountutil.Rank(route.S3Key, idx.Mounts)
 		res.typeByPath[pathVal] = meta.Type
 		if meta.Slug != "" {
 			addResolveKey(res.bySlug, res.bySlugLower, meta.Slug, pathVal)
 		}
 		if route.S3Key != "" {
-			rel := normalizePathKey(route.S3Key, prefix)
+			rel := normalizePathKey(mountutil.LogicalKey(route.S3Key, idx.Mounts), prefix)
 			if rel != "" {
 				addResolveKey(res.byPath, res.byPathLower, rel, pathVal)
 			}
 			name := filenameBase(route.S3Key)
 			if name != "" {
-				addResolveListKey(res.byFilename, res.byFilenameLower, name, pathVal)
-				if err := addWikiKey(res.byWiki, name, pathVal); err != nil {
-					wikiErrors = append(wikiErrors, err.Error())
+				lowerName := strings.ToLower(name)
+				if r, ok := filenameRank[lowerName]; !ok || r == rank {
+					addResolveListKey(res.byFilename, res.byFilenameLower, name, pathVal)
+					filenameRank[lowerName] = rank
 				}
+				addWiki(name, pathVal, rank)
 			}
 		}
 		for _, alias := range extractAliases(meta.FM) {
-			if err := addWikiKey(res.byWiki, alias, pathVal); err != nil {
-				wikiErrors = append(wikiErrors, err.Error())
-			}
+			addWiki(alias, pathVal, rank)
 		}
 		if meta.Title != "" {
-			if err := addWikiKey(res.byWiki, meta.Title, pathVal); err != nil {
-				wikiErrors = append(wikiErrors, err.Error())
-			}
-		}
-	}
-	if len(wikiErrors) > 0 {
-		for _, msg := range wikiErrors {
-			log.Printf("wikimap collision: %s", msg)
+			addWiki(meta.Title, pathVal, rank)
 		}
-		return res, fmt.Errorf("wikimap collisions (%d)", len(wikiErrors))
 	}
-	return res, nil
+	return res, wikiErrors
 }
 
 func addResolveKey(rawMap, lowerMap map[string]string, key, pathVal string) {
@@ -1618,14 +1791,48 @@ func buildAbsoluteURL(baseURL, p string) string {
 	return urlutil.JoinBaseURL(baseURL, p)
 }
 
-func writeSitemaps(artifactsDir, baseURL string, idx models.ResolveIndex, cfg rules.Rules) error {
+// WriteSitemaps rewrites sitemap artifacts for idx; serve uses it when a
+// publish window opens or closes after indexing.
+func WriteSitemaps(artifactsDir string, cfg config.Config, idx models.ResolveIndex, rulesCfg rules.Rules) error {
+	return writeSitemaps(artifactsDir, cfg.Site.BaseURL, cfg.DefaultLanguage(), idx, rulesCfg)
+}
+
+// RewriteSitemaps is WriteSitemaps for a process other than the indexer. It
+// holds the indexer lock so it never interleaves with an index run, and it
+// skips the write when resolve.json was modified after loaded, since that run
+// already wrote sitemaps for the newer index.
+func RewriteSitemaps(cfg config.Config, idx models.ResolveIndex, loaded time.Time, rulesCfg rules.Rules) error {
+	lockPath := filepath.Join(filepath.Dir(cfg.Paths.SnapshotFile), "index.lock")
+	lockFile, err := acquireLock(lockPath)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lockFile, lockPath)
+	info, err := os.Stat(filepath.Join(cfg.Paths.ArtifactsDir, resolveFileName))
+	if err != nil {
+		return err
+	}
+	if info.ModTime().After(loaded) {
+		return nil
+	}
+	return WriteSitemaps(cfg.Paths.ArtifactsDir, cfg, idx, rulesCfg)
+}
+
+func writeSitemaps(artifactsDir, baseURL, defaultLang string, idx models.ResolveIndex, cfg rules.Rules) error {
 	if err := cleanupSitemapChunks(artifactsDir); err != nil {
 		return err
 	}
+	type alternate struct {
+		Rel      string `xml:"rel,attr"`
+		Hreflang string `xml:"hreflang,attr"`
+		Href     string `xml:"href,attr"`
+	}
 	type urlEntry struct {
-		Loc     string `xml:"loc"`
-		LastMod string `xml:"lastmod,omitempty"`
+		Loc        string      `xml:"loc"`
+		LastMod    string      `xml:"lastmod,omitempty"`
+		Alternates []alternate `xml:"xhtml:link"`
 	}
+	hasAlternates := false
 	urls := []urlEntry{}
 	for p, rt := range idx.Routes {
 		if rt.Status != 200 || rt.NoIndex {
@@ -1643,13 +1850,21 @@ func writeSitemaps(artifactsDir, baseURL string, idx models.ResolveIndex, cfg ru
 		}
 		loc := buildAbsoluteURL(baseURL, p)
 		lastmod := ""
-		if rt.LastModified != "" {
-			if t, err := time.Parse(time.RFC3339, rt.LastModified); err == nil {
+		if updated := firstNonEmpty(meta.Updated, rt.LastModified); updated != "" {
+			if t, err := time.Parse(time.RFC3339, updated); err == nil {
 				lastmod = t.UTC().Format("2006-01-02")
 			}
 		}
-		urls = append(urls, urlEntry{Loc: loc, LastMod: lastmod})
+		entry := urlEntry{Loc: loc, LastMod: lastmod}
+		for _, tr := range sitemapTranslations(idx, meta, defaultLang) {
+			entry.Alternates = append(entry.Alternates, alternate{Rel: "alternate", Hreflang: tr.lang, Href: buildAbsoluteURL(baseURL, tr.path)})
+		}
+		if len(entry.Alternates) > 0 {
+			hasAlternates = true
+		}
+		urls = append(urls, entry)
 	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i].Loc < urls[j].Loc })
 	chunkSize := 50000
 	chunks := [][]urlEntry{}
 	for i := 0; i < len(urls); i += chunkSize {
@@ -1661,13 +1876,17 @@ func writeSitemaps(artifactsDir, baseURL string, idx models.ResolveIndex, cfg ru
 	}
 	for idx, chunk := range chunks {
 		urlset := struct {
-			XMLName xml.Name   `xml:"urlset"`
-			Xmlns   string     `xml:"xmlns,attr"`
-			URLs    []urlEntry `xml:"url"`
+			XMLName    xml.Name   `xml:"urlset"`
+			Xmlns      string     `xml:"xmlns,attr"`
+			XmlnsXhtml string     `xml:"xmlns:xhtml,attr,omitempty"`
+			URLs       []urlEntry `xml:"url"`
 		}{
 			Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
 			URLs:  chunk,
 		}
+		if hasAlternates {
+			urlset.XmlnsXhtml = "http://www.w3.org/1999/xhtml"
+		}
 		buf, err := xml.Marshal(urlset)
 		if err != nil {
 			return err
@@ -1757,6 +1976,7 @@ type searchItem struct {
 	Snippet   string  `json:"snippet,omitempty"`
 	Type      string  `json:"type,omitempty"`
 	UpdatedAt string  `json:"updatedAt,omitempty"`
+	Lang      string  `json:"lang,omitempty"`
 	Score     float64 `json:"score"`
 }
 
@@ -1782,19 +2002,49 @@ func writeSearchIndex(artifactsDir string, idx models.ResolveIndex, cfg rules.Ru
 			Path:      pathVal,
 			Snippet:   strings.TrimSpace(meta.Description),
 			Type:      docType,
-			UpdatedAt: route.LastModified,
+			UpdatedAt: firstNonEmpty(meta.Updated, route.LastModified),
+			Lang:      meta.Lang,
 		})
 	}
 	sort.SliceStable(items, func(i, j int) bool {
 		return strings.ToLower(items[i].Path) < strings.ToLower(items[j].Path)
 	})
+	generatedAt := time.Now().UTC().Format(time.RFC3339)
 	payload := searchIndex{
-		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		GeneratedAt: generatedAt,
 		Items:       items,
 	}
+	if err := cleanupLangSearchIndexes(artifactsDir); err != nil {
+		return err
+	}
+	byLang := map[string][]searchItem{}
+	for _, item := range items {
+		if item.Lang != "" {
+			byLang[item.Lang] = append(byLang[item.Lang], item)
+		}
+	}
+	for lang, langItems := range byLang {
+		langPayload := searchIndex{GeneratedAt: generatedAt, Items: langItems}
+		if err := writeAtomicJSON(filepath.Join(artifactsDir, "search."+lang+".json"), langPayload); err != nil {
+			return err
+		}
+	}
 	return writeAtomicJSON(filepath.Join(artifactsDir, "search.json"), payload)
 }
 
+func cleanupLangSearchIndexes(artifactsDir string) error {
+	matches, err := filepath.Glob(filepath.Join(artifactsDir, "search.*.json"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func acquireLock(path string) (*os.File, error) {
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
 	if err != nil {
@@ -0,0 +1,189 @@
+package indexer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+// noteLanguage returns the configured language for a note. Notes without
+// `lang` belong to the default language; sites without languages get a zero value.
+func noteLanguage(meta map[string]interface{}, cfg config.Config) (config.LanguageConfig, error) {
+	if len(cfg.Languages) == 0 {
+		return config.LanguageConfig{}, nil
+	}
+	code := strings.ToLower(stringFromMeta(meta, "lang"))
+	if code == "" {
+		return cfg.Languages[0], nil
+	}
+	lang, ok := cfg.Language(code)
+	if !ok {
+		return config.LanguageConfig{}, fmt.Errorf("unknown lang %q", code)
+	}
+	return lang, nil
+}
+
+func languagePath(prefix, pathVal string) string {
+	if prefix == "" {
+		return pathVal
+	}
+	if pathVal == "/" {
+		return prefix + "/"
+	}
+	joined := path.Join(prefix, pathVal)
+	if strings.HasSuffix(pathVal, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// slugScopeKey scopes slug uniqueness per language so translations may share slugs.
+func slugScopeKey(lang, slug string) string {
+	key := strings.ToLower(slug)
+	if lang == "" {
+		return key
+	}
+	return lang + ":" + key
+}
+
+func buildTranslations(idx models.ResolveIndex) (map[string]map[string]string, []MarkdownDiagnostic) {
+	out := map[string]map[string]string{}
+	diags := []MarkdownDiagnostic{}
+	paths := make([]string, 0, len(idx.Meta))
+	for p := range idx.Meta {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		meta := idx.Meta[p]
+		if meta.TranslationKey == "" || meta.Lang == "" {
+			continue
+		}
+		if out[meta.TranslationKey] == nil {
+			out[meta.TranslationKey] = map[string]string{}
+		}
+		if existing, ok := out[meta.TranslationKey][meta.Lang]; ok {
+			diags = append(diags, MarkdownDiagnostic{
+				Code:     "NP-INDEX-DUPLICATE-TRANSLATION",
+				Severity: "error",
+				File:     idx.Routes[p].S3Key,
+				Line:     1,
+				Field:    "translation_key",
+				Message:  fmt.Sprintf("translation_key %q already has a %q translation (%s)", meta.TranslationKey, meta.Lang, existing),
+			})
+			continue
+		}
+		out[meta.TranslationKey][meta.Lang] = p
+	}
+	if len(out) == 0 {
+		return nil, diags
+	}
+	return out, diags
+}
+
+// langResolvers resolves links within the source note's language first and
+// falls back to the whole site, so same-named notes in different languages
+// do not collide.
+type langResolvers struct {
+	all    resolverIndex
+	byLang map[string]resolverIndex
+}
+
+func buildLangResolvers(idx models.ResolveIndex, prefix string) (langResolvers, error) {
+	langs := map[string]bool{}
+	for _, meta := range idx.Meta {
+		if meta.Lang != "" {
+			langs[meta.Lang] = true
+		}
+	}
+	if len(langs) == 0 {
+		all, err := buildResolverIndex(idx, prefix)
+		return langResolvers{all: all}, err
+	}
+	out := langResolvers{byLang: map[string]resolverIndex{}}
+	// Collisions across languages are expected; first wins in the fallback index.
+	out.all, _ = collectResolverIndex(idx, prefix)
+	for lang := range langs {
+		res, err := buildResolverIndex(filterIndexByLang(idx, lang), prefix)
+		if err != nil {
+			return out, fmt.Errorf("lang %s: %w", lang, err)
+		}
+		out.byLang[lang] = res
+	}
+	return out, nil
+}
+
+func (l langResolvers) forLang(lang string) (resolverIndex, bool) {
+	res, ok := l.byLang[lang]
+	return res, ok
+}
+
+func (l langResolvers) resolve(lang, target, resolveBy string, rule rules.ResolveRule) (string, string, error) {
+	res, ok := l.forLang(lang)
+	if !ok {
+		return ResolveLink(target, resolveBy, rule, l.all)
+	}
+	resolved, tail, err := ResolveLink(target, resolveBy, rule, res)
+	if err == nil && resolved != "" {
+		return resolved, tail, nil
+	}
+	if fallback, fallbackTail, fallbackErr := ResolveLink(target, resolveBy, rule, l.all); fallbackErr == nil && fallback != "" {
+		return fallback, fallbackTail, nil
+	}
+	return resolved, tail, err
+}
+
+func filterIndexByLang(idx models.ResolveIndex, lang string) models.ResolveIndex {
+	out := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{},
+		Meta:   map[string]models.MetaEntry{},
+		Mounts: idx.Mounts,
+	}
+	for p, meta := range idx.Meta {
+		if meta.Lang != "" && meta.Lang != lang {
+			continue
+		}
+		out.Meta[p] = meta
+		if route, ok := idx.Routes[p]; ok {
+			out.Routes[p] = route
+		}
+	}
+	return out
+}
+
+type translationRef struct {
+	lang string
+	path string
+}
+
+// sitemapTranslations lists hreflang alternates for a page, including itself
+// and an x-default pointing at the default-language version.
+func sitemapTranslations(idx models.ResolveIndex, meta models.MetaEntry, defaultLang string) []translationRef {
+	if meta.TranslationKey == "" || meta.Lang == "" {
+		return nil
+	}
+	byLang := idx.Translations[meta.TranslationKey]
+	if len(byLang) < 2 {
+		return nil
+	}
+	listed := func(p string) bool {
+		route, ok := idx.Routes[p]
+		return ok && route.Status == 200 && !route.NoIndex
+	}
+	out := make([]translationRef, 0, len(byLang)+1)
+	for lang, p := range byLang {
+		if listed(p) {
+			out = append(out, translationRef{lang: lang, path: p})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].lang < out[j].lang })
+	if p, ok := byLang[defaultLang]; ok && listed(p) {
+		out = append(out, translationRef{lang: "x-default", path: p})
+	}
+	return out
+}
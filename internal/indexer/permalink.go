@@ -0,0 +1,180 @@
+package indexer
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+var permalinkVarRe = regexp.MustCompile(`\{\{\s*([^{}\s]*)\s*\}\}`)
+
+// permalinkVars holds the values a permalink template can reference.
+// Key is the note's path inside the vault (mount path included, no prefix).
+type permalinkVars struct {
+	Slug string
+	Type string
+	Key  string
+	Meta map[string]interface{}
+	Loc  *time.Location
+}
+
+// buildPermalink renders a permalink template. Supported variables:
+// slug, type, dir, filename, date.year, date.month, date.day and fm.<field>.
+// Everything except slug is slugified; dir keeps its folder segments.
+func buildPermalink(template string, vars permalinkVars, cfg rules.Rules) (string, error) {
+	if template == "" {
+		return "", fmt.Errorf("missing permalink template")
+	}
+	var renderErr error
+	rendered := permalinkVarRe.ReplaceAllStringFunc(template, func(match string) string {
+		if renderErr != nil {
+			return ""
+		}
+		name := permalinkVarRe.FindStringSubmatch(match)[1]
+		val, err := permalinkValue(name, vars, cfg)
+		if err != nil {
+			renderErr = err
+			return ""
+		}
+		return val
+	})
+	if renderErr != nil {
+		return "", renderErr
+	}
+	pathVal := strings.TrimSpace(rendered)
+	if pathVal == "" {
+		return "", fmt.Errorf("empty permalink")
+	}
+	if !strings.HasPrefix(pathVal, "/") {
+		pathVal = "/" + pathVal
+	}
+	for strings.Contains(pathVal, "//") {
+		pathVal = strings.ReplaceAll(pathVal, "//", "/")
+	}
+	if pathVal != "/" {
+		pathVal = strings.TrimRight(pathVal, "/")
+	}
+	return pathVal, nil
+}
+
+func permalinkValue(name string, vars permalinkVars, cfg rules.Rules) (string, error) {
+	switch {
+	case name == "slug":
+		if strings.TrimSpace(vars.Slug) == "" && cfg.Validation.PermalinkRequiresSlug.Action == "error" {
+			return "", newFieldError("slug", "slug required by permalink")
+		}
+		return vars.Slug, nil
+	case name == "type":
+		if vars.Type == "" {
+			return "", fmt.Errorf("permalink variable {{ type }}: note has no type")
+		}
+		return slug.Make(vars.Type), nil
+	case name == "dir":
+		dir := path.Dir(strings.TrimPrefix(vars.Key, "/"))
+		if dir == "." {
+			return "", nil
+		}
+		return slugifySegments(dir), nil
+	case name == "filename":
+		base := path.Base(vars.Key)
+		base = strings.TrimSuffix(base, path.Ext(base))
+		if base == "" || base == "." {
+			return "", fmt.Errorf("permalink variable {{ filename }}: empty filename")
+		}
+		return slug.Make(base), nil
+	case strings.HasPrefix(name, "date."):
+		t, ok, err := timeFromMeta(vars.Meta, "date", vars.Loc)
+		if err != nil {
+			return "", fmt.Errorf("permalink variable {{ %s }}: %w", name, err)
+		}
+		if !ok {
+			return "", newFieldError("date", "permalink variable {{ %s }}: missing frontmatter field date", name)
+		}
+		switch strings.TrimPrefix(name, "date.") {
+		case "year":
+			return strconv.Itoa(t.Year()), nil
+		case "month":
+			return fmt.Sprintf("%02d", int(t.Month())), nil
+		case "day":
+			return fmt.Sprintf("%02d", t.Day()), nil
+		}
+	case strings.HasPrefix(name, "fm."):
+		field := strings.TrimPrefix(name, "fm.")
+		val := scalarFromMeta(vars.Meta, field)
+		if val == "" {
+			return "", newFieldError(field, "permalink variable {{ %s }}: missing frontmatter field %s", name, field)
+		}
+		return slug.Make(val), nil
+	}
+	return "", fmt.Errorf("unknown permalink variable {{ %s }}", name)
+}
+
+// validatePermalinkTemplate reports variables the renderer does not know.
+func validatePermalinkTemplate(template string) error {
+	for _, m := range permalinkVarRe.FindAllStringSubmatch(template, -1) {
+		name := m[1]
+		switch {
+		case name == "slug", name == "type", name == "dir", name == "filename":
+		case name == "date.year", name == "date.month", name == "date.day":
+		case strings.HasPrefix(name, "fm.") && len(name) > len("fm."):
+		default:
+			return fmt.Errorf("unknown permalink variable {{ %s }}", name)
+		}
+	}
+	return nil
+}
+
+func permalinkUsesVar(template, name string) bool {
+	for _, m := range permalinkVarRe.FindAllStringSubmatch(template, -1) {
+		if m[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// autoSlug derives a slug for notes without one, as configured by types.<name>.auto_slug.
+func autoSlug(mode, title, key string) string {
+	base := path.Base(key)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	switch mode {
+	case "title":
+		return slug.Make(firstNonEmpty(title, base))
+	case "filename":
+		return slug.Make(base)
+	}
+	return ""
+}
+
+func slugifySegments(p string) string {
+	parts := strings.Split(p, "/")
+	out := parts[:0]
+	for _, part := range parts {
+		if s := slug.Make(part); s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func scalarFromMeta(meta map[string]interface{}, key string) string {
+	if meta == nil {
+		return ""
+	}
+	switch v := meta[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case int, int64, float64, bool:
+		return fmt.Sprint(v)
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return ""
+}
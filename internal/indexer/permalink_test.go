@@ -0,0 +1,76 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestBuildPermalinkVariables(t *testing.T) {
+	meta, _, err := parseFrontmatter([]byte("---\ndate: 2024-03-07\ncategory: Go Tips\n---\nbody"))
+	if err != nil {
+		t.Fatalf("parseFrontmatter: %v", err)
+	}
+	vars := permalinkVars{Slug: "hello", Type: "article", Key: "Notes/Deep Dive/My Note.md", Meta: meta}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"/{{ slug }}/", "/hello"},
+		{"/{{ type }}/{{ date.year }}/{{ date.month }}/{{ slug }}/", "/article/2024/03/hello"},
+		{"/{{ fm.category }}/{{slug}}/", "/go-tips/hello"},
+		{"/{{ dir }}/{{ filename }}/", "/notes/deep-dive/my-note"},
+	}
+	for _, tt := range tests {
+		got, err := buildPermalink(tt.template, vars, rules.Rules{})
+		if err != nil {
+			t.Fatalf("buildPermalink(%q): %v", tt.template, err)
+		}
+		if got != tt.want {
+			t.Fatalf("buildPermalink(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	rootVars := permalinkVars{Slug: "x", Key: "Root.md"}
+	if got, err := buildPermalink("/{{ dir }}/{{ filename }}", rootVars, rules.Rules{}); err != nil || got != "/root" {
+		t.Fatalf("root note permalink = %q, %v", got, err)
+	}
+}
+
+func TestBuildPermalinkMissingVariables(t *testing.T) {
+	vars := permalinkVars{Slug: "hello", Type: "article", Key: "a.md", Meta: map[string]interface{}{}}
+	tests := []struct {
+		template string
+		wantErr  string
+	}{
+		{"/{{ date.year }}/{{ slug }}", "missing frontmatter field date"},
+		{"/{{ fm.category }}/{{ slug }}", "missing frontmatter field category"},
+		{"/{{ author }}/{{ slug }}", "unknown permalink variable {{ author }}"},
+	}
+	for _, tt := range tests {
+		_, err := buildPermalink(tt.template, vars, rules.Rules{})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("buildPermalink(%q) error = %v, want %q", tt.template, err, tt.wantErr)
+		}
+	}
+	strict := rules.Rules{Validation: rules.ValidationRule{PermalinkRequiresSlug: rules.ActionRule{Action: "error"}}}
+	if _, err := buildPermalink("/{{ slug }}", permalinkVars{}, strict); err == nil {
+		t.Fatalf("expected slug required error")
+	}
+	if err := validatePermalinkTemplate("/{{ date.week }}/{{ slug }}"); err == nil {
+		t.Fatalf("expected unknown variable error")
+	}
+}
+
+func TestAutoSlug(t *testing.T) {
+	if got := autoSlug("title", "Hello, World!", "notes/x.md"); got != "hello-world" {
+		t.Fatalf("title auto slug = %q", got)
+	}
+	if got := autoSlug("title", "", "notes/My File.md"); got != "my-file" {
+		t.Fatalf("title fallback auto slug = %q", got)
+	}
+	if got := autoSlug("filename", "Ignored", "notes/Привет мир.md"); got != "privet-mir" {
+		t.Fatalf("filename auto slug = %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+const quarantineFileName = "quarantine.json"
+
+// RunOptions controls how Run treats notes that fail validation.
+type RunOptions struct {
+	// KeepGoing quarantines failing notes instead of failing the run.
+	KeepGoing bool
+	// MaxErrors fails a keep-going run with more errors than this; 0 means no limit.
+	MaxErrors int
+	// KeepPrevious serves a quarantined note from its route in the previous
+	// resolve.json when it had one.
+	KeepPrevious bool
+	// Templates lists the theme's template names. When set, frontmatter
+	// template and layout overrides are checked against it.
+	Templates []string
+}
+
+func (o RunOptions) tolerates(errCount int) bool {
+	if errCount == 0 {
+		return true
+	}
+	return o.KeepGoing && (o.MaxErrors <= 0 || errCount <= o.MaxErrors)
+}
+
+// QuarantinedNote is a note left out of the index by a keep-going run.
+type QuarantinedNote struct {
+	File string `json:"file"`
+	// KeptPath is the previous route still served for the note, if any.
+	KeptPath    string               `json:"kept_path,omitempty"`
+	Diagnostics []MarkdownDiagnostic `json:"diagnostics"`
+}
+
+type quarantineReport struct {
+	GeneratedAt string            `json:"generated_at"`
+	Notes       []QuarantinedNote `json:"notes"`
+}
+
+// quarantineNotes removes every note with an error diagnostic from idx and
+// rebuilds translations and redirects without them. Links to removed notes
+// then resolve as missing. With keepPrevious, a removed note's route, meta and
+// links from oldIdx are restored when its old path is still free. Errors that
+// surface only after removal are appended to diags and quarantine their notes too.
+func quarantineNotes(idx *models.ResolveIndex, oldIdx models.ResolveIndex, diags []MarkdownDiagnostic, keepPrevious bool) ([]MarkdownDiagnostic, []QuarantinedNote) {
+	failed := map[string]bool{}
+	for _, d := range diags {
+		if d.Severity == "error" && d.File != "" {
+			failed[d.File] = true
+		}
+	}
+	if len(failed) == 0 {
+		return diags, nil
+	}
+	oldPathByKey := map[string]string{}
+	for p, rt := range oldIdx.Routes {
+		if rt.S3Key != "" {
+			oldPathByKey[rt.S3Key] = p
+		}
+	}
+
+	kept := map[string]string{}
+	noKeep := map[string]bool{}
+	for {
+		for p, rt := range idx.Routes {
+			if failed[rt.S3Key] {
+				removeIndexPath(idx, p)
+			}
+		}
+		kept = map[string]string{}
+		if keepPrevious {
+			for key := range failed {
+				oldPath, ok := oldPathByKey[key]
+				if !ok || noKeep[key] {
+					continue
+				}
+				if _, taken := idx.Routes[oldPath]; taken {
+					continue
+				}
+				restoreIndexPath(idx, oldIdx, oldPath)
+				kept[key] = oldPath
+			}
+		}
+
+		translations, translationDiags := buildTranslations(*idx)
+		redirects, redirectDiags := buildRedirects(oldIdx, *idx)
+		added := false
+		for _, d := range append(translationDiags, redirectDiags...) {
+			if failed[d.File] && kept[d.File] == "" {
+				continue
+			}
+			diags = append(diags, d)
+			failed[d.File] = true
+			noKeep[d.File] = true
+			added = true
+		}
+		if !added {
+			idx.Translations = translations
+			idx.Redirects = nil
+			if len(redirects) > 0 {
+				idx.Redirects = redirects
+			}
+			break
+		}
+	}
+
+	byFile := map[string][]MarkdownDiagnostic{}
+	for _, d := range diags {
+		if failed[d.File] {
+			byFile[d.File] = append(byFile[d.File], d)
+		}
+	}
+	notes := make([]QuarantinedNote, 0, len(failed))
+	for key := range failed {
+		notes = append(notes, QuarantinedNote{File: key, KeptPath: kept[key], Diagnostics: byFile[key]})
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].File < notes[j].File })
+	return diags, notes
+}
+
+func removeIndexPath(idx *models.ResolveIndex, p string) {
+	delete(idx.Routes, p)
+	delete(idx.Meta, p)
+	delete(idx.LinkTargets, p)
+	delete(idx.Media, p)
+}
+
+func restoreIndexPath(idx *models.ResolveIndex, oldIdx models.ResolveIndex, p string) {
+	idx.Routes[p] = oldIdx.Routes[p]
+	if meta, ok := oldIdx.Meta[p]; ok {
+		idx.Meta[p] = meta
+	}
+	if targets, ok := oldIdx.LinkTargets[p]; ok {
+		idx.LinkTargets[p] = targets
+	}
+	if media, ok := oldIdx.Media[p]; ok && len(media) > 0 {
+		idx.Media[p] = media
+	}
+}
+
+// writeQuarantine writes quarantine.json next to resolve.json, or removes a
+// stale one when nothing is quarantined.
+func writeQuarantine(artifactsDir string, notes []QuarantinedNote) error {
+	path := filepath.Join(artifactsDir, quarantineFileName)
+	if len(notes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeAtomicJSON(path, quarantineReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Notes:       notes,
+	})
+}
@@ -0,0 +1,112 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+func quarantineTestIndex() models.ResolveIndex {
+	return models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/a":    {Status: 200, S3Key: "a.md"},
+			"/b":    {Status: 200, S3Key: "b.md"},
+			"/ru/b": {Status: 200, S3Key: "ru/b.md"},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/a":    {Lang: "en", TranslationKey: "a"},
+			"/b":    {Lang: "en", TranslationKey: "b"},
+			"/ru/b": {Lang: "ru", TranslationKey: "b"},
+		},
+		LinkTargets: map[string]map[string][]string{"/a": {}, "/b": {}, "/ru/b": {}},
+		Media:       map[string][]string{},
+	}
+}
+
+func TestQuarantineNotesRemovesFailingNotes(t *testing.T) {
+	idx := quarantineTestIndex()
+	diags := []MarkdownDiagnostic{
+		{Code: "NP-INDEX-DATE", Severity: "error", File: "b.md", Line: 3, Message: "invalid date"},
+		{Code: "NP-FM-TYPE", Severity: "warn", File: "a.md", Line: 2, Message: "warning only"},
+	}
+	out, notes := quarantineNotes(&idx, models.ResolveIndex{}, diags, false)
+	if len(out) != 2 || len(notes) != 1 || notes[0].File != "b.md" || notes[0].KeptPath != "" {
+		t.Fatalf("unexpected quarantine: %+v %+v", out, notes)
+	}
+	if _, ok := idx.Routes["/b"]; ok {
+		t.Fatalf("quarantined route still indexed")
+	}
+	if _, ok := idx.Routes["/a"]; !ok {
+		t.Fatalf("note with warnings was quarantined")
+	}
+	if idx.Translations["b"]["en"] != "" || idx.Translations["b"]["ru"] != "/ru/b" {
+		t.Fatalf("translations not rebuilt: %#v", idx.Translations)
+	}
+}
+
+func TestQuarantineNotesKeepsPreviousRoute(t *testing.T) {
+	oldIdx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/old-b": {Status: 200, S3Key: "b.md"}},
+		Meta:   map[string]models.MetaEntry{"/old-b": {Title: "B"}},
+	}
+	idx := quarantineTestIndex()
+	diags := []MarkdownDiagnostic{{Code: "NP-INDEX-DATE", Severity: "error", File: "b.md"}}
+	_, notes := quarantineNotes(&idx, oldIdx, diags, true)
+	if len(notes) != 1 || notes[0].KeptPath != "/old-b" {
+		t.Fatalf("unexpected quarantine: %+v", notes)
+	}
+	if idx.Meta["/old-b"].Title != "B" || idx.Routes["/old-b"].S3Key != "b.md" {
+		t.Fatalf("previous route not restored: %+v", idx.Routes)
+	}
+	if _, ok := idx.Routes["/b"]; ok {
+		t.Fatalf("new route of quarantined note still indexed")
+	}
+}
+
+func TestRunOptionsTolerates(t *testing.T) {
+	if (RunOptions{}).tolerates(1) {
+		t.Fatalf("default options must fail on errors")
+	}
+	if !(RunOptions{KeepGoing: true}).tolerates(100) {
+		t.Fatalf("keep-going without max-errors must tolerate errors")
+	}
+	if (RunOptions{KeepGoing: true, MaxErrors: 2}).tolerates(3) || !(RunOptions{KeepGoing: true, MaxErrors: 2}).tolerates(2) {
+		t.Fatalf("max-errors threshold not applied")
+	}
+}
+
+func TestRunKeepGoingFailedNoteDoesNotReserveRoute(t *testing.T) {
+	root := t.TempDir()
+	writeVaultFile(t, root, "config.yaml", fmt.Sprintf("site:\n  base_url: \"https://example.com/\"\ncontent:\n  source: local\n  local_dir: %[1]s/content\npaths:\n  artifacts_dir: %[1]s/artifacts\n  snapshot_file: %[1]s/snapshot/objects.json\nrules_path: %[1]s/rules.yaml\n", root))
+	writeVaultFile(t, root, "rules.yaml", "types:\n  page:\n    template: page.html\n    permalink: \"/{{ slug }}/\"\nvalidation:\n  duplicate_route:\n    action: error\n  unique_slug:\n    action: error\n  single_page_of_type:\n    page: 1\n")
+	writeVaultFile(t, root, "content/a-bad.md", "---\ntype: page\ntitle: Bad\nslug: same\npublish_at: not-a-date\n---\n")
+	writeVaultFile(t, root, "content/b-good.md", "---\ntype: page\ntitle: Good\nslug: same\n---\n")
+
+	cfg, err := config.Load(filepath.Join(root, "config.yaml"))
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	report, err := RunWithReport(context.Background(), cfg, RunOptions{KeepGoing: true})
+	if err != nil {
+		t.Fatalf("RunWithReport: %v", err)
+	}
+	if len(report.Quarantined) != 1 || report.Quarantined[0].File != "a-bad.md" {
+		t.Fatalf("quarantined = %+v", report.Quarantined)
+	}
+	for _, d := range report.Diagnostics {
+		if d.Code != "NP-INDEX-DATE" {
+			t.Fatalf("unexpected diagnostic %+v", d)
+		}
+	}
+	idx, err := loadResolve(filepath.Join(cfg.Paths.ArtifactsDir, resolveFileName))
+	if err != nil {
+		t.Fatalf("loadResolve: %v", err)
+	}
+	if route, ok := idx.Routes["/same"]; !ok || route.S3Key != "b-good.md" {
+		t.Fatalf("routes = %+v", idx.Routes)
+	}
+}
@@ -0,0 +1,127 @@
+package indexer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+const (
+	redirectSourceFrom  = "redirect_from"
+	redirectSourceMoved = "moved"
+)
+
+// buildRedirects assembles the redirect table for idx: `redirect_from`
+// aliases, notes whose permalink changed since oldIdx, and redirects from
+// earlier runs (retargeted when their destination moved again). Frontmatter
+// aliases win over automatic entries. Targets are always live routes and
+// sources never are, so the table has no chains.
+func buildRedirects(oldIdx, idx models.ResolveIndex) (map[string]models.Redirect, []MarkdownDiagnostic) {
+	out := map[string]models.Redirect{}
+	var errs []MarkdownDiagnostic
+
+	paths := make([]string, 0, len(idx.Meta))
+	for p := range idx.Meta {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	owner := map[string]string{}
+	for _, p := range paths {
+		meta := idx.Meta[p]
+		fail := func(msg string) {
+			errs = append(errs, MarkdownDiagnostic{
+				Code:     "NP-INDEX-REDIRECT",
+				Severity: "error",
+				File:     idx.Routes[p].S3Key,
+				Line:     1,
+				Field:    "redirect_from",
+				Message:  msg,
+			})
+		}
+		for _, raw := range extractFieldValues(meta.FM["redirect_from"]) {
+			from, err := normalizeRedirectFrom(raw)
+			if err != nil {
+				fail(err.Error())
+				continue
+			}
+			if _, ok := idx.Routes[from]; ok {
+				fail(fmt.Sprintf("redirect_from %q is an existing route", from))
+				continue
+			}
+			if prev, ok := owner[from]; ok && prev != p {
+				fail(fmt.Sprintf("redirect_from %q already used by %s", from, prev))
+				continue
+			}
+			owner[from] = p
+			out[from] = models.Redirect{To: p, Source: redirectSourceFrom}
+		}
+	}
+
+	newPathByKey := map[string]string{}
+	for p, rt := range idx.Routes {
+		if rt.S3Key != "" {
+			newPathByKey[rt.S3Key] = p
+		}
+	}
+	// currentPath maps a path from the previous index to where that note lives now.
+	currentPath := func(oldPath string) (string, bool) {
+		if _, ok := idx.Routes[oldPath]; ok {
+			return oldPath, true
+		}
+		rt, ok := oldIdx.Routes[oldPath]
+		if !ok || rt.S3Key == "" {
+			return "", false
+		}
+		p, ok := newPathByKey[rt.S3Key]
+		return p, ok
+	}
+	addAuto := func(from, to string) {
+		if from == to {
+			return
+		}
+		if _, ok := idx.Routes[from]; ok {
+			return
+		}
+		if _, ok := out[from]; ok {
+			return
+		}
+		out[from] = models.Redirect{To: to, Source: redirectSourceMoved}
+	}
+
+	for oldPath, rt := range oldIdx.Routes {
+		if rt.S3Key == "" || rt.Status != 200 {
+			continue
+		}
+		if to, ok := newPathByKey[rt.S3Key]; ok {
+			addAuto(oldPath, to)
+		}
+	}
+	for from, r := range oldIdx.Redirects {
+		if r.Source != redirectSourceMoved {
+			continue
+		}
+		if to, ok := currentPath(r.To); ok {
+			addAuto(from, to)
+		}
+	}
+	return out, errs
+}
+
+func normalizeRedirectFrom(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("empty redirect_from")
+	}
+	if strings.Contains(raw, "://") || strings.HasPrefix(raw, "//") {
+		return "", fmt.Errorf("redirect_from %q must be a site path", raw)
+	}
+	trailing := strings.HasSuffix(raw, "/")
+	clean := path.Clean("/" + raw)
+	if trailing && clean != "/" {
+		clean += "/"
+	}
+	return clean, nil
+}
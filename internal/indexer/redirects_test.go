@@ -0,0 +1,65 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+func TestBuildRedirectsFromFrontmatterAndMoves(t *testing.T) {
+	oldIdx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/old-post": {Status: 200, S3Key: "post.md"},
+			"/about":    {Status: 200, S3Key: "about.md"},
+		},
+		Redirects: map[string]models.Redirect{
+			"/ancient-post": {To: "/old-post", Source: "moved"},
+			"/stale-alias":  {To: "/about", Source: "redirect_from"},
+		},
+	}
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/blog/post": {Status: 200, S3Key: "post.md"},
+			"/about":     {Status: 200, S3Key: "about.md"},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/blog/post": {},
+			"/about":     {FM: map[string]interface{}{"redirect_from": []interface{}{"company", "/team/"}}},
+		},
+	}
+	got, errs := buildRedirects(oldIdx, idx)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := map[string]models.Redirect{
+		"/old-post":     {To: "/blog/post", Source: "moved"},
+		"/ancient-post": {To: "/blog/post", Source: "moved"},
+		"/company":      {To: "/about", Source: "redirect_from"},
+		"/team/":        {To: "/about", Source: "redirect_from"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for from, w := range want {
+		if got[from] != w {
+			t.Fatalf("%s: got %+v, want %+v", from, got[from], w)
+		}
+	}
+}
+
+func TestBuildRedirectsRejectsCollisions(t *testing.T) {
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/a": {Status: 200, S3Key: "a.md"},
+			"/b": {Status: 200, S3Key: "b.md"},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/a": {FM: map[string]interface{}{"redirect_from": []interface{}{"/b", "/shared"}}},
+			"/b": {FM: map[string]interface{}{"redirect_from": "/shared"}},
+		},
+	}
+	_, errs := buildRedirects(models.ResolveIndex{}, idx)
+	if len(errs) != 2 {
+		t.Fatalf("expected route and duplicate errors, got %v", errs)
+	}
+}
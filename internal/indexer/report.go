@@ -0,0 +1,134 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+// Report summarizes an index run: notes added, changed, removed and
+// unchanged relative to the previous snapshot, plus every diagnostic found
+// and, for keep-going runs, the quarantined notes.
+type Report struct {
+	Added       int                  `json:"added"`
+	Changed     int                  `json:"changed"`
+	Removed     int                  `json:"removed"`
+	Unchanged   int                  `json:"unchanged"`
+	Diagnostics []MarkdownDiagnostic `json:"diagnostics"`
+	Quarantined []QuarantinedNote    `json:"quarantined,omitempty"`
+}
+
+// snapshotCounts compares the markdown keys of two snapshots.
+func snapshotCounts(oldSnap, newSnap map[string]models.SnapshotEntry) (added, changed, removed, unchanged int) {
+	for key, entry := range newSnap {
+		old, ok := oldSnap[key]
+		switch {
+		case !ok:
+			added++
+		case old.ETag != entry.ETag || old.Cascade != entry.Cascade:
+			changed++
+		default:
+			unchanged++
+		}
+	}
+	for key := range oldSnap {
+		if _, ok := newSnap[key]; !ok {
+			removed++
+		}
+	}
+	return added, changed, removed, unchanged
+}
+
+// noteError is a note failure with a diagnostic code and, when it is caused
+// by one frontmatter field, that field.
+type noteError struct {
+	Code  string
+	Field string
+	Msg   string
+}
+
+func (e *noteError) Error() string { return e.Msg }
+
+func newFieldError(field, format string, args ...interface{}) error {
+	return &noteError{Field: field, Msg: fmt.Sprintf(format, args...)}
+}
+
+func newNoteError(code, field, format string, args ...interface{}) error {
+	return &noteError{Code: code, Field: field, Msg: fmt.Sprintf(format, args...)}
+}
+
+// FrontmatterError is a YAML frontmatter parse failure. Line is the line in
+// the note (1-based) when the YAML parser reported one, otherwise 0.
+type FrontmatterError struct {
+	Line int
+	Err  error
+}
+
+func (e *FrontmatterError) Error() string { return "frontmatter yaml: " + e.Err.Error() }
+
+func (e *FrontmatterError) Unwrap() error { return e.Err }
+
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine extracts the first line number from a yaml.v3 error.
+func yamlErrorLine(err error) int {
+	m := yamlLineRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// appendDiagnostics adds found to *diags and reports whether any is an error.
+func appendDiagnostics(diags *[]MarkdownDiagnostic, found []MarkdownDiagnostic) bool {
+	failed := false
+	for _, d := range found {
+		*diags = append(*diags, d)
+		if d.Severity == "error" {
+			failed = true
+		}
+	}
+	return failed
+}
+
+// noteDiagnostics builds index diagnostics for one note, placing each at the
+// line of its frontmatter field when known.
+type noteDiagnostics struct {
+	key   string
+	lines map[string]int
+	start int
+}
+
+func newNoteDiagnostics(key string, body []byte) noteDiagnostics {
+	nd := noteDiagnostics{key: key, start: 1}
+	if body != nil {
+		nd.lines, nd.start = frontmatterLines(body)
+	}
+	return nd
+}
+
+func (nd noteDiagnostics) diag(code, field, msg string) MarkdownDiagnostic {
+	line := nd.start
+	if l, ok := nd.lines[field]; ok {
+		line = l
+	}
+	return MarkdownDiagnostic{Code: code, Severity: "error", File: nd.key, Line: line, Field: field, Message: msg}
+}
+
+// fromErr prefers the code and field carried by err over code and field.
+func (nd noteDiagnostics) fromErr(code, field string, err error) MarkdownDiagnostic {
+	var ne *noteError
+	if errors.As(err, &ne) {
+		if ne.Code != "" {
+			code = ne.Code
+		}
+		if ne.Field != "" {
+			field = ne.Field
+		}
+	}
+	return nd.diag(code, field, err.Error())
+}
@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+func TestSnapshotCounts(t *testing.T) {
+	oldSnap := map[string]models.SnapshotEntry{
+		"a.md": {ETag: "1"},
+		"b.md": {ETag: "1"},
+		"c.md": {ETag: "1", Cascade: "x"},
+		"d.md": {ETag: "1"},
+	}
+	newSnap := map[string]models.SnapshotEntry{
+		"a.md": {ETag: "1"},
+		"b.md": {ETag: "2"},
+		"c.md": {ETag: "1", Cascade: "y"},
+		"e.md": {ETag: "1"},
+	}
+	added, changed, removed, unchanged := snapshotCounts(oldSnap, newSnap)
+	if added != 1 || changed != 2 || removed != 1 || unchanged != 1 {
+		t.Fatalf("got added=%d changed=%d removed=%d unchanged=%d", added, changed, removed, unchanged)
+	}
+}
+
+func TestParseFrontmatterErrorLine(t *testing.T) {
+	body := []byte("\n---\ntitle: Ok\nbad: a: b\n---\nbody\n")
+	_, _, err := parseFrontmatter(body)
+	var fmErr *FrontmatterError
+	if !errors.As(err, &fmErr) {
+		t.Fatalf("expected FrontmatterError, got %v", err)
+	}
+	if fmErr.Line != 4 {
+		t.Fatalf("expected line 4, got %d (%v)", fmErr.Line, err)
+	}
+}
+
+func TestNoteDiagnosticsUsesFieldLine(t *testing.T) {
+	body := []byte("---\ntitle: T\ndate: nope\n---\n")
+	nd := newNoteDiagnostics("notes/a.md", body)
+	err := fmt.Errorf("permalink: %w", newFieldError("date", "invalid date %q", "nope"))
+	d := nd.fromErr("NP-INDEX-PERMALINK", "", err)
+	if d.Field != "date" || d.Line != 3 || d.Code != "NP-INDEX-PERMALINK" || d.File != "notes/a.md" {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+	d = nd.fromErr("NP-INDEX-ERROR", "", newNoteError("NP-INDEX-DUPLICATE-SLUG", "slug", "duplicate slug %q", "x"))
+	if d.Code != "NP-INDEX-DUPLICATE-SLUG" || d.Line != 1 {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+}
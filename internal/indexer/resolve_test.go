@@ -70,3 +70,126 @@ func TestResolverIndexWikiMapCollision(t *testing.T) {
 		t.Fatalf("expected wikimap collision error")
 	}
 }
+
+func TestResolverIndexMountPrecedence(t *testing.T) {
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/blog/note":   {S3Key: "@blog/Note.md", Status: 200},
+			"/garden/note": {S3Key: "@garden/notes/Note.md", Status: 200},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/blog/note":   {Title: "Blog Note"},
+			"/garden/note": {Title: "Garden Note"},
+		},
+		Mounts: map[string]models.MountInfo{
+			"blog":   {MountPath: "blog", Rank: 0},
+			"garden": {MountPath: "garden", Rank: 1},
+		},
+	}
+	resolver, err := buildResolverIndex(idx, "")
+	if err != nil {
+		t.Fatalf("buildResolverIndex: %v", err)
+	}
+	rule := rules.ResolveRule{Order: []string{"path", "filename"}, Ambiguity: "error"}
+	tests := []struct {
+		target   string
+		wantPath string
+	}{
+		{"Note", "/blog/note"},
+		{"garden/notes/Note", "/garden/note"},
+		{"blog/Note", "/blog/note"},
+	}
+	for _, tt := range tests {
+		got, _, err := ResolveLink(tt.target, "wikimap", rule, resolver)
+		if err != nil {
+			t.Fatalf("ResolveLink(%q): %v", tt.target, err)
+		}
+		if got != tt.wantPath {
+			t.Fatalf("ResolveLink(%q) = %q, want %q", tt.target, got, tt.wantPath)
+		}
+	}
+}
+
+func TestLangResolversPreferSameLanguage(t *testing.T) {
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/about":    {S3Key: "en/About.md", Status: 200},
+			"/ru/about": {S3Key: "ru/About.md", Status: 200},
+			"/contacts": {S3Key: "en/Contacts.md", Status: 200},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/about":    {Title: "About", Lang: "en", TranslationKey: "about"},
+			"/ru/about": {Title: "About", Lang: "ru", TranslationKey: "about"},
+			"/contacts": {Title: "Contacts", Lang: "en"},
+		},
+	}
+	resolvers, err := buildLangResolvers(idx, "")
+	if err != nil {
+		t.Fatalf("buildLangResolvers: %v", err)
+	}
+	rule := rules.ResolveRule{Order: []string{"filename"}, Ambiguity: "error"}
+	tests := []struct {
+		lang     string
+		target   string
+		wantPath string
+	}{
+		{"en", "About", "/about"},
+		{"ru", "About", "/ru/about"},
+		{"ru", "Contacts", "/contacts"},
+	}
+	for _, tt := range tests {
+		got, _, err := resolvers.resolve(tt.lang, tt.target, "wikimap", rule)
+		if err != nil {
+			t.Fatalf("resolve(%q, %q): %v", tt.lang, tt.target, err)
+		}
+		if got != tt.wantPath {
+			t.Fatalf("resolve(%q, %q) = %q, want %q", tt.lang, tt.target, got, tt.wantPath)
+		}
+	}
+
+	translations, errs := buildTranslations(idx)
+	if len(errs) != 0 {
+		t.Fatalf("buildTranslations errors: %v", errs)
+	}
+	if translations["about"]["ru"] != "/ru/about" || translations["about"]["en"] != "/about" {
+		t.Fatalf("unexpected translations: %#v", translations)
+	}
+}
+
+func TestLanguagePath(t *testing.T) {
+	tests := []struct {
+		prefix, path, want string
+	}{
+		{"", "/note", "/note"},
+		{"/ru", "/", "/ru/"},
+		{"/ru", "/note", "/ru/note"},
+		{"/ru", "/docs/", "/ru/docs/"},
+	}
+	for _, tt := range tests {
+		if got := languagePath(tt.prefix, tt.path); got != tt.want {
+			t.Fatalf("languagePath(%q, %q) = %q, want %q", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSitemapTranslationsSkipsUnlistedDefault(t *testing.T) {
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/about/":    {Status: 200, NoIndex: true},
+			"/ru/about/": {Status: 200},
+			"/de/about/": {Status: 200},
+		},
+		Translations: map[string]map[string]string{
+			"about": {"en": "/about/", "ru": "/ru/about/", "de": "/de/about/"},
+		},
+	}
+	refs := sitemapTranslations(idx, models.MetaEntry{TranslationKey: "about", Lang: "ru"}, "en")
+	if len(refs) != 2 || refs[0].lang != "de" || refs[1].lang != "ru" {
+		t.Fatalf("unexpected alternates: %+v", refs)
+	}
+	idx.Routes["/about/"] = models.RouteEntry{Status: 200}
+	refs = sitemapTranslations(idx, models.MetaEntry{TranslationKey: "about", Lang: "ru"}, "en")
+	if len(refs) != 4 || refs[3] != (translationRef{lang: "x-default", path: "/about/"}) {
+		t.Fatalf("unexpected alternates: %+v", refs)
+	}
+}
@@ -0,0 +1,151 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+	"github.com/cookiespooky/notepub/internal/yamlutil"
+)
+
+// collectionSortFields are the non-frontmatter fields collections sort by.
+var collectionSortFields = []string{"title", "slug", "created_at", "updated_at"}
+
+// validateRuleReferences checks names that point at other parts of the
+// rules: link types, collection links and sort/group fields. Each error and
+// warning starts with the rules file position of the offending key. Sorting
+// or grouping on an undeclared frontmatter field is only a warning: it works,
+// but is often a typo.
+func validateRuleReferences(cfg rules.Rules) ([]error, []string) {
+	var errs []error
+	var warnings []string
+	report := func(path, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", cfg.Pos(path), fmt.Sprintf(format, args...)))
+	}
+	checkField := func(path, by string, builtin, fmFields []string) {
+		msg, warn := checkCollectionField(by, builtin, fmFields)
+		switch {
+		case msg == "":
+		case warn:
+			warnings = append(warnings, fmt.Sprintf("%s: %s: %s", cfg.Pos(path), path, msg))
+		default:
+			report(path, "%s: %s", path, msg)
+		}
+	}
+	typeNames := sortedTypeNames(cfg)
+	checkTypes := func(path string, names []string) {
+		for i, name := range names {
+			if _, ok := cfg.Types[name]; !ok {
+				report(fmt.Sprintf("%s[%d]", path, i), "%s: unknown type %q%s", path, name, didYouMean(name, typeNames))
+			}
+		}
+	}
+
+	linkNames := make([]string, 0, len(cfg.Links))
+	for i, link := range cfg.Links {
+		linkNames = append(linkNames, link.Name)
+		base := fmt.Sprintf("links[%d]", i)
+		checkTypes(base+".from_types", link.FromTypes)
+		checkTypes(base+".to_types", link.ToTypes)
+	}
+	checkTypes("sitemap.include_types", cfg.Sitemap.IncludeTypes)
+	checkTypes("search.include_types", cfg.Search.IncludeTypes)
+
+	fmFields := declaredFMFields(cfg)
+	checkSortBy := func(path, by string) {
+		if by != "" {
+			checkField(path, by, collectionSortFields, fmFields)
+		}
+	}
+	for _, name := range sortedKeys(cfg.Collections) {
+		col := cfg.Collections[name]
+		base := "collections." + name
+		switch col.Kind {
+		case "filter":
+		case "forward", "backrefs":
+			if col.Link == "" {
+				report(base+".kind", "%s: kind %q requires link", base, col.Kind)
+			} else if !containsString(linkNames, col.Link) {
+				report(base+".link", "%s.link: unknown link %q%s", base, col.Link, didYouMean(col.Link, linkNames))
+			}
+		default:
+			report(base+".kind", "%s.kind: must be filter, forward or backrefs, got %q", base, col.Kind)
+		}
+		checkSortBy(base+".sort.by", col.Sort.By)
+		checkSortBy(base+".group_by.item_sort.by", col.GroupBy.ItemSort.By)
+		if by := col.GroupBy.By; by != "" {
+			checkField(base+".group_by.by", by, []string{"type"}, fmFields)
+		}
+	}
+	return errs, warnings
+}
+
+// checkCollectionField validates a sort or group_by field: one of builtin or
+// fm.<name>. It returns "" when valid; warn marks an fm.<name> the rules do
+// not declare, which still sorts but may be a typo.
+func checkCollectionField(by string, builtin, fmFields []string) (msg string, warn bool) {
+	if containsString(builtin, by) {
+		return "", false
+	}
+	if name, ok := strings.CutPrefix(by, "fm."); ok {
+		if containsString(fmFields, name) {
+			return "", false
+		}
+		return fmt.Sprintf("frontmatter field %q is not declared in fields or fm_schema%s", name, didYouMean(name, fmFields)), true
+	}
+	candidates := append([]string(nil), builtin...)
+	for _, f := range fmFields {
+		candidates = append(candidates, "fm."+f)
+	}
+	return fmt.Sprintf("unknown field %q (use %s or fm.<field>)%s", by, strings.Join(builtin, ", "), didYouMean(by, candidates)), false
+}
+
+// declaredFMFields lists frontmatter fields named anywhere in the rules.
+func declaredFMFields(cfg rules.Rules) []string {
+	seen := map[string]bool{}
+	add := func(names ...string) {
+		for _, n := range names {
+			if n != "" {
+				seen[n] = true
+			}
+		}
+	}
+	add(cfg.Fields.Required...)
+	add(cfg.Fields.Optional...)
+	for name := range cfg.Fields.Defaults {
+		add(name)
+	}
+	for name := range cfg.FMFields {
+		add(name)
+	}
+	for _, t := range cfg.Types {
+		add(t.Required...)
+		add(t.Optional...)
+	}
+	return sortedKeys(seen)
+}
+
+func didYouMean(name string, candidates []string) string {
+	if s := yamlutil.Suggest(name, candidates); s != "" {
+		return fmt.Sprintf(" (did you mean %q?)", s)
+	}
+	return ""
+}
+
+// sortedTypeNames returns type names in declaration order when known.
+func sortedTypeNames(cfg rules.Rules) []string {
+	if len(cfg.TypeOrder) == len(cfg.Types) {
+		return cfg.TypeOrder
+	}
+	return sortedKeys(cfg.Types)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
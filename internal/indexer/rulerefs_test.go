@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestValidateRulesReportsBrokenReferences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(`fields:
+  optional: ["order"]
+types:
+  page:
+    permalink: "/{{ slug }}/"
+  article:
+    permalink: "/a/{{ slug }}/"
+links:
+  - name: "related"
+    kind: "field"
+    field: "related"
+    from_types: ["page", "artcle"]
+    to_types: ["page"]
+collections:
+  feed:
+    kind: "backrefs"
+    link: "relatd"
+    sort:
+      by: "create_at"
+  ordered:
+    kind: "filter"
+    sort:
+      by: "fm.ordr"
+    group_by:
+      by: "fm.topic"
+      item_sort:
+        by: "fm.order"
+sitemap:
+  include_types: ["page", "note"]
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := rules.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	warnings, err := ValidateRules(cfg)
+	if err == nil {
+		t.Fatalf("expected reference errors")
+	}
+	want := []string{
+		path + `:12: links[0].from_types: unknown type "artcle" (did you mean "article"?)`,
+		path + `:29: sitemap.include_types: unknown type "note"`,
+		path + `:17: collections.feed.link: unknown link "relatd" (did you mean "related"?)`,
+		path + `:19: collections.feed.sort.by: unknown field "create_at"`,
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("missing %q in:\n%v", w, err)
+		}
+	}
+	if strings.Contains(err.Error(), "fm.order") || strings.Contains(err.Error(), `"order"`) {
+		t.Errorf("declared field reported:\n%v", err)
+	}
+	if strings.Contains(err.Error(), "topic") || strings.Contains(err.Error(), "ordr") {
+		t.Errorf("undeclared frontmatter field reported as an error:\n%v", err)
+	}
+	wantWarnings := []string{
+		path + `:23: collections.ordered.sort.by: frontmatter field "ordr" is not declared in fields or fm_schema (did you mean "order"?)`,
+		path + `:25: collections.ordered.group_by.by: frontmatter field "topic" is not declared`,
+	}
+	if len(warnings) != len(wantWarnings) {
+		t.Fatalf("warnings = %q", warnings)
+	}
+	for i, w := range wantWarnings {
+		if !strings.HasPrefix(warnings[i], w) {
+			t.Errorf("warning %d = %q, want prefix %q", i, warnings[i], w)
+		}
+	}
+}
+
+func TestRulesLoadRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(`collections:
+  feed:
+    kind: "filter"
+    materialise: true
+search:
+  include-types: ["page"]
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := rules.Load(path)
+	if err == nil {
+		t.Fatalf("expected unknown key error")
+	}
+	for _, w := range []string{
+		path + `:4: unknown key "materialise" in collections.feed (did you mean "materialize"?)`,
+		path + `:6: unknown key "include-types" in search (did you mean "include_types"?)`,
+	} {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("missing %q in:\n%v", w, err)
+		}
+	}
+}
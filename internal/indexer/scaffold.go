@@ -0,0 +1,273 @@
+package indexer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gosimple/slug"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+// maxNewNoteCandidates caps the slug suffixes ScaffoldNote tries.
+const maxNewNoteCandidates = 1000
+
+// NewNoteOptions describes a note for `notepub new`.
+type NewNoteOptions struct {
+	Type  string
+	Title string
+	// Slug overrides the slug derived from Title.
+	Slug string
+	// ContentDir is the local content directory the note is written under.
+	ContentDir string
+	// Dir overrides types.<name>.dir, relative to ContentDir.
+	Dir string
+	// BodyTemplate is a text/template for the note body (theme archetype);
+	// empty yields an empty body.
+	BodyTemplate string
+	Now          time.Time
+}
+
+// NewNote is a scaffolded note ready to be written.
+type NewNote struct {
+	// File is the note path, joined onto ContentDir.
+	File    string
+	Slug    string
+	Path    string
+	Content []byte
+}
+
+// NewNoteBodyData is passed to archetype body templates.
+type NewNoteBodyData struct {
+	Type  string
+	Title string
+	Slug  string
+	Date  string
+}
+
+// ScaffoldNote builds frontmatter for a new note of opts.Type from the rules
+// contract: type, title and slug, the global and per-type required fields,
+// fields the permalink reads, and per-type optional fields, each filled from
+// fields.defaults or an empty value of its fm_schema type. The slug is made
+// unique against idx and files already in the target folder.
+func ScaffoldNote(rulesCfg rules.Rules, idx models.ResolveIndex, opts NewNoteOptions) (NewNote, error) {
+	typeDef, ok := rulesCfg.Types[opts.Type]
+	if !ok {
+		return NewNote{}, fmt.Errorf("unknown type %q", opts.Type)
+	}
+	title := strings.TrimSpace(opts.Title)
+	if title == "" {
+		return NewNote{}, fmt.Errorf("title is required")
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = typeDef.Dir
+	}
+	dir, err := cleanTypeDir(dir)
+	if err != nil {
+		return NewNote{}, err
+	}
+
+	base := slug.Make(firstNonEmpty(opts.Slug, title))
+	if base == "" {
+		return NewNote{}, fmt.Errorf("cannot derive a slug from %q; pass --slug", title)
+	}
+	date := opts.Now.Format("2006-01-02")
+
+	fields := newNoteFields(rulesCfg, typeDef)
+	values := map[string]interface{}{"type": opts.Type, "title": title}
+	for _, f := range fields {
+		if _, ok := values[f]; ok || f == "slug" {
+			continue
+		}
+		if def, ok := rulesCfg.Fields.Defaults[f]; ok {
+			values[f] = def
+			continue
+		}
+		if f == "date" {
+			values[f] = date
+			continue
+		}
+		values[f] = emptyFieldValue(rulesCfg.FMFields[f])
+	}
+
+	usedSlugs := map[string]bool{}
+	for _, meta := range idx.Meta {
+		if meta.Slug != "" {
+			usedSlugs[strings.ToLower(meta.Slug)] = true
+		}
+	}
+	slugDependent := permalinkUsesVar(typeDef.Permalink, "slug") || permalinkUsesVar(typeDef.Permalink, "filename")
+	var note NewNote
+	for n := 1; n <= maxNewNoteCandidates && note.File == ""; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = base + "-" + strconv.Itoa(n)
+		}
+		if usedSlugs[candidate] {
+			continue
+		}
+		file := path.Join(dir, candidate+".md")
+		if _, err := os.Stat(filepath.Join(opts.ContentDir, filepath.FromSlash(file))); err == nil {
+			continue
+		}
+		values["slug"] = candidate
+		pathVal, err := buildPermalink(typeDef.Permalink, permalinkVars{
+			Slug: candidate,
+			Type: opts.Type,
+			Key:  file,
+			Meta: values,
+			Loc:  opts.Now.Location(),
+		}, rulesCfg)
+		if err != nil {
+			return NewNote{}, fmt.Errorf("permalink: %w", err)
+		}
+		if _, taken := idx.Routes[pathVal]; taken {
+			if !slugDependent {
+				return NewNote{}, fmt.Errorf("route %s already exists and the %q permalink does not depend on the slug", pathVal, opts.Type)
+			}
+			continue
+		}
+		if _, taken := idx.Redirects[pathVal]; taken {
+			if !slugDependent {
+				return NewNote{}, fmt.Errorf("route %s is a redirect and the %q permalink does not depend on the slug", pathVal, opts.Type)
+			}
+			continue
+		}
+		note = NewNote{File: file, Slug: candidate, Path: pathVal}
+	}
+	if note.File == "" {
+		return NewNote{}, fmt.Errorf("no free slug for %q after %d attempts", base, maxNewNoteCandidates)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	for _, f := range append([]string{"type", "title", "slug"}, fields...) {
+		val, ok := values[f]
+		if !ok {
+			continue
+		}
+		delete(values, f)
+		out, err := yaml.Marshal(map[string]interface{}{f: val})
+		if err != nil {
+			return NewNote{}, fmt.Errorf("frontmatter %s: %w", f, err)
+		}
+		buf.Write(out)
+	}
+	if rest := remainingOptional(rulesCfg, fields); len(rest) > 0 {
+		buf.WriteString("# optional: " + strings.Join(rest, ", ") + "\n")
+	}
+	buf.WriteString("---\n")
+	if strings.TrimSpace(opts.BodyTemplate) != "" {
+		tmpl, err := template.New(opts.Type).Option("missingkey=error").Parse(opts.BodyTemplate)
+		if err != nil {
+			return NewNote{}, fmt.Errorf("body template: %w", err)
+		}
+		buf.WriteString("\n")
+		if err := tmpl.Execute(&buf, NewNoteBodyData{Type: opts.Type, Title: title, Slug: note.Slug, Date: date}); err != nil {
+			return NewNote{}, fmt.Errorf("body template: %w", err)
+		}
+	}
+	note.Content = buf.Bytes()
+	return note, nil
+}
+
+// newNoteFields lists frontmatter fields a new note of typeDef starts with,
+// in order and without duplicates.
+func newNoteFields(cfg rules.Rules, typeDef rules.TypeDef) []string {
+	seen := map[string]bool{"type": true, "title": true}
+	out := []string{}
+	add := func(f string) {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			return
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	if permalinkUsesVar(typeDef.Permalink, "slug") {
+		add("slug")
+	}
+	for _, f := range cfg.Fields.Required {
+		add(f)
+	}
+	for _, f := range typeDef.Required {
+		add(f)
+	}
+	for _, m := range permalinkVarRe.FindAllStringSubmatch(typeDef.Permalink, -1) {
+		switch {
+		case strings.HasPrefix(m[1], "date."):
+			add("date")
+		case strings.HasPrefix(m[1], "fm."):
+			add(strings.TrimPrefix(m[1], "fm."))
+		}
+	}
+	for _, f := range typeDef.Optional {
+		add(f)
+	}
+	for _, f := range cfg.Fields.Optional {
+		if _, ok := cfg.Fields.Defaults[f]; ok {
+			add(f)
+		}
+	}
+	return out
+}
+
+// remainingOptional lists global optional fields not already in fields.
+func remainingOptional(cfg rules.Rules, fields []string) []string {
+	have := map[string]bool{}
+	for _, f := range fields {
+		have[f] = true
+	}
+	out := []string{}
+	for _, f := range cfg.Fields.Optional {
+		if !have[f] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func emptyFieldValue(schema rules.FieldSchema) interface{} {
+	switch schema.Type {
+	case "number":
+		return 0
+	case "boolean":
+		return false
+	case "string[]":
+		return []string{}
+	case "enum":
+		if len(schema.Values) > 0 {
+			return schema.Values[0]
+		}
+	}
+	return ""
+}
+
+// cleanTypeDir normalizes types.<name>.dir, which must stay inside the content dir.
+func cleanTypeDir(dir string) (string, error) {
+	dir = strings.TrimSpace(filepath.ToSlash(dir))
+	if dir == "" {
+		return "", nil
+	}
+	clean := path.Clean(dir)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("dir %q must be relative to the content directory", dir)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
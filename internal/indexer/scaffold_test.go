@@ -0,0 +1,95 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestScaffoldNote(t *testing.T) {
+	cfg := rules.Rules{
+		Fields: rules.FieldContract{
+			Required: []string{"type", "slug", "title"},
+			Optional: []string{"description", "draft"},
+			Defaults: map[string]interface{}{"draft": false},
+		},
+		FMFields: map[string]rules.FieldSchema{
+			"price":  {Type: "number"},
+			"status": {Type: "enum", Values: []string{"draft", "published"}},
+		},
+		Types: map[string]rules.TypeDef{
+			"product": {
+				Permalink: "/{{ date.year }}/{{ slug }}/",
+				Dir:       "shop",
+				Required:  []string{"price"},
+				Optional:  []string{"status"},
+			},
+		},
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "shop"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shop", "red-shoe-2.md"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/2026/red-shoe": {Status: 200}},
+		Meta:   map[string]models.MetaEntry{"/2026/red-shoe": {Slug: "red-shoe"}},
+	}
+	note, err := ScaffoldNote(cfg, idx, NewNoteOptions{
+		Type:         "product",
+		Title:        "Red Shoe",
+		ContentDir:   dir,
+		BodyTemplate: "# {{ .Title }}\n",
+		Now:          time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("ScaffoldNote: %v", err)
+	}
+	if note.File != "shop/red-shoe-3.md" || note.Slug != "red-shoe-3" || note.Path != "/2026/red-shoe-3" {
+		t.Fatalf("unexpected note: %+v", note)
+	}
+	want := "---\ntype: product\ntitle: Red Shoe\nslug: red-shoe-3\nprice: 0\ndate: \"2026-03-04\"\nstatus: draft\ndraft: false\n# optional: description\n---\n\n# Red Shoe\n"
+	if string(note.Content) != want {
+		t.Fatalf("unexpected content:\n%s", note.Content)
+	}
+	meta, _, err := parseFrontmatter(note.Content)
+	if err != nil || meta["slug"] != "red-shoe-3" {
+		t.Fatalf("scaffold does not parse: %v %v", meta, err)
+	}
+}
+
+func TestScaffoldNoteRejectsUnknownTypeAndBadDir(t *testing.T) {
+	cfg := rules.Rules{Types: map[string]rules.TypeDef{"page": {Permalink: "/{{ slug }}/"}}}
+	if _, err := ScaffoldNote(cfg, models.ResolveIndex{}, NewNoteOptions{Type: "post", Title: "X"}); err == nil {
+		t.Fatalf("expected unknown type error")
+	}
+	_, err := ScaffoldNote(cfg, models.ResolveIndex{}, NewNoteOptions{Type: "page", Title: "X", Dir: "../out"})
+	if err == nil || !strings.Contains(err.Error(), "relative") {
+		t.Fatalf("expected dir error, got %v", err)
+	}
+}
+
+func TestScaffoldNoteFixedPermalinkTaken(t *testing.T) {
+	cfg := rules.Rules{Types: map[string]rules.TypeDef{"page": {Permalink: "/about/"}}}
+	idx := models.ResolveIndex{Redirects: map[string]models.Redirect{"/about": {}}}
+	done := make(chan error, 1)
+	go func() {
+		_, err := ScaffoldNote(cfg, idx, NewNoteOptions{Type: "page", Title: "About", ContentDir: t.TempDir(), Now: time.Now()})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "redirect") {
+			t.Fatalf("expected redirect error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ScaffoldNote did not return")
+	}
+}
@@ -0,0 +1,53 @@
+package indexer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+// templateOverrides reads the template and layout frontmatter keys as theme
+// template names; a missing extension means .html ("landing" is landing.html).
+func templateOverrides(meta map[string]interface{}) (string, string) {
+	return templateName(stringFromMeta(meta, "template")), templateName(stringFromMeta(meta, "layout"))
+}
+
+func templateName(val string) string {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return ""
+	}
+	if path.Ext(val) == "" {
+		val += ".html"
+	}
+	return val
+}
+
+// checkTemplateOverrides reports template and layout overrides that name a
+// template the theme does not have. validation.missing_template decides the
+// severity: error fails the note, ignore skips the check, anything else warns.
+func checkTemplateOverrides(nd noteDiagnostics, meta models.MetaEntry, cfg rules.Rules, templates map[string]bool) []MarkdownDiagnostic {
+	if templates == nil {
+		return nil
+	}
+	severity := "warn"
+	switch strings.ToLower(strings.TrimSpace(cfg.Validation.MissingTemplate.Action)) {
+	case "error":
+		severity = "error"
+	case "ignore":
+		return nil
+	}
+	var diags []MarkdownDiagnostic
+	for _, o := range []struct{ field, name string }{{"template", meta.Template}, {"layout", meta.Layout}} {
+		if o.name == "" || templates[o.name] {
+			continue
+		}
+		d := nd.diag("NP-INDEX-MISSING-TEMPLATE", o.field, fmt.Sprintf("%s %q not found in theme", o.field, o.name))
+		d.Severity = severity
+		diags = append(diags, d)
+	}
+	return diags
+}
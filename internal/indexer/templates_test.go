@@ -0,0 +1,43 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestTemplateOverridesNormalizeNames(t *testing.T) {
+	tmpl, layout := templateOverrides(map[string]interface{}{"template": " landing ", "layout": "wide.html"})
+	if tmpl != "landing.html" || layout != "wide.html" {
+		t.Fatalf("overrides = %q, %q", tmpl, layout)
+	}
+	if tmpl, layout := templateOverrides(map[string]interface{}{"title": "x"}); tmpl != "" || layout != "" {
+		t.Fatalf("overrides without keys = %q, %q", tmpl, layout)
+	}
+}
+
+func TestCheckTemplateOverrides(t *testing.T) {
+	body := []byte("---\ntitle: T\ntemplate: landing\nlayout: wide\n---\n")
+	nd := newNoteDiagnostics("landing.md", body)
+	meta := models.MetaEntry{Template: "landing.html", Layout: "wide.html"}
+	templates := map[string]bool{"landing.html": true, "page.html": true}
+
+	diags := checkTemplateOverrides(nd, meta, rules.Rules{}, templates)
+	if len(diags) != 1 || diags[0].Field != "layout" || diags[0].Line != 4 || diags[0].Severity != "warn" || diags[0].Code != "NP-INDEX-MISSING-TEMPLATE" {
+		t.Fatalf("unexpected diagnostics: %+v", diags)
+	}
+
+	var cfg rules.Rules
+	cfg.Validation.MissingTemplate.Action = "error"
+	if diags := checkTemplateOverrides(nd, meta, cfg, templates); len(diags) != 1 || diags[0].Severity != "error" {
+		t.Fatalf("error action: %+v", diags)
+	}
+	cfg.Validation.MissingTemplate.Action = "ignore"
+	if diags := checkTemplateOverrides(nd, meta, cfg, templates); len(diags) != 0 {
+		t.Fatalf("ignore action: %+v", diags)
+	}
+	if diags := checkTemplateOverrides(nd, meta, rules.Rules{}, nil); len(diags) != 0 {
+		t.Fatalf("unknown theme must not be checked: %+v", diags)
+	}
+}
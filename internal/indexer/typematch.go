@@ -0,0 +1,131 @@
+package indexer
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+// inferType picks a type for a note without `type` using types.<name>.match.
+// Types are tried in rules.yaml order, match entries in list order; the
+// returned rule names the entry that matched, e.g. `types.post.match[0]`.
+func inferType(cfg rules.Rules, key string, meta map[string]interface{}) (string, string, bool) {
+	for _, name := range orderedTypeNames(cfg) {
+		for i, m := range cfg.Types[name].Match {
+			if typeMatches(m, key, meta, cfg.FMSchema) {
+				return name, fmt.Sprintf("types.%s.match[%d]", name, i), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func orderedTypeNames(cfg rules.Rules) []string {
+	if len(cfg.TypeOrder) == len(cfg.Types) {
+		return cfg.TypeOrder
+	}
+	names := make([]string, 0, len(cfg.Types))
+	for name := range cfg.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func typeMatches(m rules.TypeMatch, key string, meta map[string]interface{}, schema map[string]string) bool {
+	if m.Path == "" && m.Filename == "" && len(m.FM) == 0 {
+		return false
+	}
+	key = strings.TrimPrefix(key, "/")
+	if m.Path != "" && !matchPathGlob(m.Path, key) {
+		return false
+	}
+	if m.Filename != "" {
+		ok, err := path.Match(m.Filename, path.Base(key))
+		if err != nil || !ok {
+			return false
+		}
+	}
+	for field, expected := range m.FM {
+		if !collectionFMEq(meta, field, expected, schema) && !fmListContains(meta, field, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func fmListContains(meta map[string]interface{}, field string, expected interface{}) bool {
+	list, ok := meta[field].([]interface{})
+	if !ok {
+		return false
+	}
+	want := strings.TrimSpace(fmt.Sprint(expected))
+	for _, item := range list {
+		if strings.TrimSpace(fmt.Sprint(item)) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathGlob matches a slash-separated key against a glob where `*`
+// stays within one folder and `**` spans any number of folders.
+func matchPathGlob(pattern, key string) bool {
+	re, err := compilePathGlob(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(key)
+}
+
+func compilePathGlob(pattern string) (*regexp.Regexp, error) {
+	runes := []rune(strings.TrimPrefix(strings.TrimSpace(pattern), "/"))
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func validateTypeMatches(name string, def rules.TypeDef) error {
+	for i, m := range def.Match {
+		if m.Path == "" && m.Filename == "" && len(m.FM) == 0 {
+			return fmt.Errorf("type %q: match[%d] needs path, filename or fm", name, i)
+		}
+		if m.Path != "" {
+			if _, err := compilePathGlob(m.Path); err != nil {
+				return fmt.Errorf("type %q: match[%d].path %q: %w", name, i, m.Path, err)
+			}
+		}
+		if m.Filename != "" {
+			if _, err := path.Match(m.Filename, ""); err != nil {
+				return fmt.Errorf("type %q: match[%d].filename %q: %w", name, i, m.Filename, err)
+			}
+		}
+	}
+	return nil
+}
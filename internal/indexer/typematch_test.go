@@ -0,0 +1,58 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestInferTypeUsesRuleOrder(t *testing.T) {
+	cfg := rules.Rules{
+		Types: map[string]rules.TypeDef{
+			"post": {Match: []rules.TypeMatch{{Path: "posts/**"}}},
+			"recipe": {Match: []rules.TypeMatch{
+				{FM: map[string]interface{}{"tags": "recipe"}},
+				{Filename: "*.recipe.md"},
+			}},
+			"page": {Match: []rules.TypeMatch{{Path: "**/*.md"}}},
+		},
+		TypeOrder: []string{"recipe", "post", "page"},
+	}
+	tests := []struct {
+		key      string
+		meta     map[string]interface{}
+		wantType string
+		wantRule string
+	}{
+		{"posts/2024/hello.md", nil, "post", "types.post.match[0]"},
+		{"posts/soup.md", map[string]interface{}{"tags": []interface{}{"food", "recipe"}}, "recipe", "types.recipe.match[0]"},
+		{"kitchen/borscht.recipe.md", nil, "recipe", "types.recipe.match[1]"},
+		{"About.md", nil, "page", "types.page.match[0]"},
+	}
+	for _, tt := range tests {
+		gotType, gotRule, ok := inferType(cfg, tt.key, tt.meta)
+		if !ok || gotType != tt.wantType || gotRule != tt.wantRule {
+			t.Fatalf("inferType(%q) = %q, %q, %v; want %q, %q", tt.key, gotType, gotRule, ok, tt.wantType, tt.wantRule)
+		}
+	}
+}
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"posts/**", "posts/a.md", true},
+		{"posts/**", "posts/2024/a.md", true},
+		{"posts/**", "drafts/a.md", false},
+		{"posts/*.md", "posts/2024/a.md", false},
+		{"**/index.md", "index.md", true},
+		{"**/index.md", "docs/guide/index.md", true},
+		{"Заметки/*", "Заметки/Мысль.md", true},
+	}
+	for _, tt := range tests {
+		if got := matchPathGlob(tt.pattern, tt.key); got != tt.want {
+			t.Fatalf("matchPathGlob(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -41,7 +41,8 @@ func ListMarkdown(root, prefix string) ([]s3util.Object, error) {
 			return nil
 		}
 		name := strings.ToLower(d.Name())
-		if !strings.HasSuffix(name, ".md") {
+		// _defaults.yaml carries directory frontmatter defaults for the indexer.
+		if !strings.HasSuffix(name, ".md") && name != "_defaults.yaml" {
 			return nil
 		}
 		info, err := d.Info()
@@ -43,3 +43,30 @@ func TestMaskCodeWithSpaces(t *testing.T) {
 		t.Fatalf("non-code text must be preserved: %q", masked)
 	}
 }
+
+func TestFindShortcodes(t *testing.T) {
+	in := strings.Join([]string{
+		`{{< figure src="a b.png" caption='It\'s "here"' wide >}}`,
+		"`{{< inline >}}`",
+		"```",
+		"{{< fenced >}}",
+		"```",
+		`{{< tab "First tab" >}}x{{< /tab >}}`,
+	}, "\n")
+	got := FindShortcodes(in)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 shortcodes outside code, got %+v", got)
+	}
+	fig := got[0]
+	if fig.Name != "figure" || fig.Line != 1 || fig.Params["src"] != "a b.png" || fig.Params["caption"] != `It's "here"` || len(fig.Args) != 1 || fig.Args[0] != "wide" {
+		t.Fatalf("unexpected figure: %+v", fig)
+	}
+	if got[1].Name != "tab" || got[1].Line != 6 || got[1].Args[0] != "First tab" || !got[2].Closing {
+		t.Fatalf("unexpected tab tags: %+v", got[1:])
+	}
+
+	out := RewriteShortcodes(in, func(sc Shortcode) string { return "<" + sc.Name + ">" })
+	if !strings.Contains(out, "`{{< inline >}}`") || !strings.HasPrefix(out, "<figure>\n") || !strings.Contains(out, "<tab>x<tab>") {
+		t.Fatalf("unexpected rewrite: %q", out)
+	}
+}
@@ -0,0 +1,117 @@
+package mdproc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Shortcode is one {{< name args >}} or {{< /name >}} tag.
+type Shortcode struct {
+	Name    string
+	Closing bool
+	// Params holds key="value" arguments, Args the positional ones.
+	Params map[string]string
+	Args   []string
+	Raw    string
+	// Line is 1-based; only FindShortcodes sets it.
+	Line int
+}
+
+var shortcodeRe = regexp.MustCompile(`\{\{<\s*(/)?\s*([A-Za-z][A-Za-z0-9_-]*)(\s.*?)?\s*>\}\}`)
+
+// RewriteShortcodes replaces every shortcode tag outside code with the
+// result of replace, called in document order.
+func RewriteShortcodes(markdown string, replace func(Shortcode) string) string {
+	return RewriteOutsideCode(markdown, func(segment string) string {
+		return shortcodeRe.ReplaceAllStringFunc(segment, func(match string) string {
+			return replace(parseShortcode(match))
+		})
+	})
+}
+
+// FindShortcodes lists shortcode tags outside code with their line numbers.
+func FindShortcodes(markdown string) []Shortcode {
+	text := MaskCodeWithSpaces(NormalizeLineEndings(markdown))
+	var out []Shortcode
+	for _, loc := range shortcodeRe.FindAllStringIndex(text, -1) {
+		sc := parseShortcode(text[loc[0]:loc[1]])
+		sc.Line = strings.Count(text[:loc[0]], "\n") + 1
+		out = append(out, sc)
+	}
+	return out
+}
+
+func parseShortcode(raw string) Shortcode {
+	m := shortcodeRe.FindStringSubmatch(raw)
+	sc := Shortcode{Raw: raw, Params: map[string]string{}}
+	if m == nil {
+		return sc
+	}
+	sc.Closing = m[1] == "/"
+	sc.Name = m[2]
+	sc.Params, sc.Args = parseShortcodeArgs(m[3])
+	return sc
+}
+
+// parseShortcodeArgs splits `src="a b" wide 'x'` into params and positional
+// arguments. Values may be bare words or quoted with " or '; a backslash
+// escapes the quote character.
+func parseShortcodeArgs(s string) (map[string]string, []string) {
+	params := map[string]string{}
+	var args []string
+	i := 0
+	for {
+		for i < len(s) && isArgSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return params, args
+		}
+		if s[i] == '"' || s[i] == '\'' {
+			val, next := readQuoted(s, i)
+			args = append(args, val)
+			i = next
+			continue
+		}
+		start := i
+		for i < len(s) && !isArgSpace(s[i]) && s[i] != '=' {
+			i++
+		}
+		word := s[start:i]
+		if i < len(s) && s[i] == '=' {
+			i++
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				params[word], i = readQuoted(s, i)
+				continue
+			}
+			start = i
+			for i < len(s) && !isArgSpace(s[i]) {
+				i++
+			}
+			params[word] = s[start:i]
+			continue
+		}
+		args = append(args, word)
+	}
+}
+
+func readQuoted(s string, i int) (string, int) {
+	quote := s[i]
+	var b strings.Builder
+	for i++; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == quote:
+			b.WriteByte(quote)
+			i++
+		case s[i] == quote:
+			return b.String(), i + 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), i
+}
+
+func isArgSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
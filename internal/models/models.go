@@ -5,15 +5,32 @@ import "encoding/json"
 type SnapshotEntry struct {
 	ETag         string `json:"etag"`
 	LastModified string `json:"last_modified"`
+	Cascade      string `json:"cascade,omitempty"`
 }
 
 type ResolveIndex struct {
-	GeneratedAt string                         `json:"generated_at"`
-	Routes      map[string]RouteEntry          `json:"routes"`
-	Meta        map[string]MetaEntry           `json:"meta"`
-	Links       map[string]map[string][]string `json:"links,omitempty"`
-	LinkTargets map[string]map[string][]string `json:"link_targets,omitempty"`
-	Media       map[string][]string            `json:"media,omitempty"`
+	GeneratedAt  string                         `json:"generated_at"`
+	Routes       map[string]RouteEntry          `json:"routes"`
+	Meta         map[string]MetaEntry           `json:"meta"`
+	Links        map[string]map[string][]string `json:"links,omitempty"`
+	LinkTargets  map[string]map[string][]string `json:"link_targets,omitempty"`
+	Media        map[string][]string            `json:"media,omitempty"`
+	Mounts       map[string]MountInfo           `json:"mounts,omitempty"`
+	Translations map[string]map[string]string   `json:"translations,omitempty"`
+	Redirects    map[string]Redirect            `json:"redirects,omitempty"`
+}
+
+// Redirect is a 301 from an old path to a note's current path. Source is
+// "redirect_from" for frontmatter aliases and "moved" for permalink changes.
+type Redirect struct {
+	To     string `json:"to"`
+	Source string `json:"source"`
+}
+
+type MountInfo struct {
+	MountPath string `json:"mount_path,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	Rank      int    `json:"rank"`
 }
 
 type RouteEntry struct {
@@ -27,17 +44,27 @@ type RouteEntry struct {
 }
 
 type MetaEntry struct {
-	Type        string                 `json:"type,omitempty"`
-	Slug        string                 `json:"slug,omitempty"`
-	Title       string                 `json:"title,omitempty"`
-	Description string                 `json:"description,omitempty"`
-	Canonical   string                 `json:"canonical,omitempty"`
-	Robots      string                 `json:"robots,omitempty"`
-	OpenGraph   map[string]string      `json:"opengraph,omitempty"`
-	JSONLD      json.RawMessage        `json:"jsonld,omitempty"`
-	Category    *CategoryModel         `json:"category,omitempty"`
-	Image       string                 `json:"image,omitempty"`
-	FM          map[string]interface{} `json:"fm,omitempty"`
+	Type           string                 `json:"type,omitempty"`
+	Slug           string                 `json:"slug,omitempty"`
+	Title          string                 `json:"title,omitempty"`
+	Description    string                 `json:"description,omitempty"`
+	Canonical      string                 `json:"canonical,omitempty"`
+	Robots         string                 `json:"robots,omitempty"`
+	OpenGraph      map[string]string      `json:"opengraph,omitempty"`
+	JSONLD         json.RawMessage        `json:"jsonld,omitempty"`
+	Category       *CategoryModel         `json:"category,omitempty"`
+	Image          string                 `json:"image,omitempty"`
+	Lang           string                 `json:"lang,omitempty"`
+	TranslationKey string                 `json:"translation_key,omitempty"`
+	TypeRule       string                 `json:"type_rule,omitempty"`
+	Template       string                 `json:"template,omitempty"`
+	Layout         string                 `json:"layout,omitempty"`
+	Date           string                 `json:"date,omitempty"`
+	Updated        string                 `json:"updated,omitempty"`
+	PublishAt      string                 `json:"publish_at,omitempty"`
+	ExpireAt       string                 `json:"expire_at,omitempty"`
+	Draft          bool                   `json:"draft,omitempty"`
+	FM             map[string]interface{} `json:"fm,omitempty"`
 }
 
 type CategoryModel struct {
@@ -87,6 +114,7 @@ type CollectionItem struct {
 	Description string                 `json:"description,omitempty"`
 	Canonical   string                 `json:"canonical,omitempty"`
 	Image       string                 `json:"image,omitempty"`
+	CreatedAt   string                 `json:"created_at,omitempty"`
 	UpdatedAt   string                 `json:"updated_at,omitempty"`
 	NoIndex     bool                   `json:"noindex"`
 	FM          map[string]interface{} `json:"fm,omitempty"`
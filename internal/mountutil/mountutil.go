@@ -0,0 +1,280 @@
+package mountutil
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/localutil"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/s3util"
+)
+
+// keyMarker starts a namespaced content key: "@<mount>/<key within mount>".
+const keyMarker = "@"
+
+type Mount struct {
+	Name         string
+	Source       string
+	Dir          string
+	Bucket       string
+	Prefix       string
+	MountPath    string
+	TypesDefault string
+}
+
+type Reader struct {
+	mounts []Mount
+	byName map[string]Mount
+	client *s3.Client
+}
+
+// FromConfig returns content mounts ordered by precedence. Configs without
+// content.mounts yield a single unnamed mount so keys stay un-namespaced.
+func FromConfig(cfg config.Config) []Mount {
+	if len(cfg.Content.Mounts) == 0 {
+		return []Mount{{
+			Source: cfg.Content.Source,
+			Dir:    cfg.Content.LocalDir,
+			Bucket: cfg.S3.Bucket,
+			Prefix: cfg.S3.Prefix,
+		}}
+	}
+	byName := map[string]config.ContentMount{}
+	for _, m := range cfg.Content.Mounts {
+		byName[m.Name] = m
+	}
+	out := make([]Mount, 0, len(cfg.Content.Mounts))
+	added := map[string]bool{}
+	add := func(m config.ContentMount) {
+		if added[m.Name] {
+			return
+		}
+		added[m.Name] = true
+		out = append(out, Mount{
+			Name:         m.Name,
+			Source:       m.Source,
+			Dir:          m.Dir,
+			Bucket:       m.Bucket,
+			Prefix:       m.Prefix,
+			MountPath:    m.MountPath,
+			TypesDefault: m.TypesDefault,
+		})
+	}
+	for _, name := range cfg.Content.Precedence {
+		if m, ok := byName[strings.TrimSpace(name)]; ok {
+			add(m)
+		}
+	}
+	for _, m := range cfg.Content.Mounts {
+		add(m)
+	}
+	return out
+}
+
+func NewClient(ctx context.Context, cfg config.Config) (*s3.Client, error) {
+	needsS3 := false
+	for _, m := range FromConfig(cfg) {
+		if m.Source == "s3" {
+			needsS3 = true
+			break
+		}
+	}
+	if !needsS3 {
+		return nil, nil
+	}
+	return s3util.NewClient(ctx, s3util.Config{
+		Endpoint:       cfg.S3.Endpoint,
+		Region:         cfg.S3.Region,
+		ForcePathStyle: cfg.S3.ForcePathStyle,
+		Bucket:         cfg.S3.Bucket,
+		Prefix:         cfg.S3.Prefix,
+		AccessKey:      cfg.S3.AccessKey,
+		SecretKey:      cfg.S3.SecretKey,
+		Anonymous:      cfg.S3.Anonymous,
+	})
+}
+
+func NewReader(cfg config.Config, client *s3.Client) *Reader {
+	mounts := FromConfig(cfg)
+	byName := make(map[string]Mount, len(mounts))
+	for _, m := range mounts {
+		byName[m.Name] = m
+	}
+	return &Reader{mounts: mounts, byName: byName, client: client}
+}
+
+func (r *Reader) Mounts() []Mount {
+	return append([]Mount{}, r.mounts...)
+}
+
+func (r *Reader) Client() *s3.Client {
+	return r.client
+}
+
+// Info describes named mounts for resolve.json; it is nil for single-source configs.
+func (r *Reader) Info() map[string]models.MountInfo {
+	out := map[string]models.MountInfo{}
+	for i, m := range r.mounts {
+		if m.Name == "" {
+			continue
+		}
+		out[m.Name] = models.MountInfo{MountPath: m.MountPath, Prefix: m.Prefix, Rank: i}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (r *Reader) List(ctx context.Context) ([]s3util.Object, error) {
+	out := []s3util.Object{}
+	for _, m := range r.mounts {
+		var (
+			objects []s3util.Object
+			err     error
+		)
+		switch m.Source {
+		case "local":
+			objects, err = localutil.ListMarkdown(m.Dir, m.Prefix)
+		case "s3":
+			if r.client == nil {
+				return nil, fmt.Errorf("s3 client is not initialized")
+			}
+			objects, err = s3util.ListObjects(ctx, r.client, m.Bucket, m.Prefix)
+		default:
+			return nil, fmt.Errorf("unsupported content source: %s", m.Source)
+		}
+		if err != nil {
+			if m.Name != "" {
+				return nil, fmt.Errorf("mount %s: %w", m.Name, err)
+			}
+			return nil, err
+		}
+		for _, obj := range objects {
+			obj.Key = NamespacedKey(m.Name, obj.Key)
+			out = append(out, obj)
+		}
+	}
+	return out, nil
+}
+
+func (r *Reader) Fetch(ctx context.Context, key string) ([]byte, error) {
+	m, raw, ok := r.Lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("no content mount for key %q", key)
+	}
+	switch m.Source {
+	case "local":
+		return localutil.FetchObject(m.Dir, raw)
+	case "s3":
+		if r.client == nil {
+			return nil, fmt.Errorf("s3 client is not initialized")
+		}
+		return s3util.FetchObject(ctx, r.client, m.Bucket, raw)
+	default:
+		return nil, fmt.Errorf("unsupported content source: %s", m.Source)
+	}
+}
+
+// Lookup returns the mount owning key and the key within that mount's source.
+// Un-namespaced keys belong to the highest-precedence mount.
+func (r *Reader) Lookup(key string) (Mount, string, bool) {
+	name, raw := SplitKey(key)
+	if name != "" {
+		if m, ok := r.byName[name]; ok {
+			return m, raw, true
+		}
+	}
+	if len(r.mounts) == 0 {
+		return Mount{}, "", false
+	}
+	return r.mounts[0], key, true
+}
+
+// Candidates returns the mounts that may hold key, in the order to try them,
+// and the key within those mounts. A namespaced key names its mount; a plain
+// key may live in any mount, so every mount is returned in precedence order.
+func (r *Reader) Candidates(key string) ([]Mount, string) {
+	name, raw := SplitKey(key)
+	if name != "" {
+		if m, ok := r.byName[name]; ok {
+			return []Mount{m}, raw
+		}
+	}
+	return append([]Mount{}, r.mounts...), key
+}
+
+func NamespacedKey(name, key string) string {
+	if name == "" {
+		return key
+	}
+	return keyMarker + name + "/" + strings.TrimPrefix(key, "/")
+}
+
+// SplitKey splits a namespaced key into mount name and key within the mount.
+// Plain keys return an empty mount name.
+func SplitKey(key string) (string, string) {
+	if !strings.HasPrefix(key, keyMarker) {
+		return "", key
+	}
+	rest := strings.TrimPrefix(key, keyMarker)
+	idx := strings.Index(rest, "/")
+	if idx <= 0 {
+		return "", key
+	}
+	return rest[:idx], rest[idx+1:]
+}
+
+// LogicalKey maps a content key to its path inside the merged vault
+// (mount_path + key without the mount prefix). Plain keys are returned as-is.
+func LogicalKey(key string, mounts map[string]models.MountInfo) string {
+	name, raw := SplitKey(key)
+	if name == "" {
+		return key
+	}
+	info, ok := mounts[name]
+	if !ok {
+		return key
+	}
+	raw = strings.TrimPrefix(raw, info.Prefix)
+	raw = strings.TrimPrefix(raw, "/")
+	if info.MountPath == "" {
+		return raw
+	}
+	return path.Join(info.MountPath, raw)
+}
+
+// Rank returns the precedence of the mount owning key; lower wins.
+func Rank(key string, mounts map[string]models.MountInfo) int {
+	name, _ := SplitKey(key)
+	if name == "" {
+		return 0
+	}
+	if info, ok := mounts[name]; ok {
+		return info.Rank
+	}
+	return len(mounts)
+}
+
+// RankedPaths returns indexed route paths ordered by mount precedence, then path,
+// so first-wins lookups prefer notes from higher-precedence mounts.
+func RankedPaths(idx models.ResolveIndex) []string {
+	paths := make([]string, 0, len(idx.Routes))
+	for p := range idx.Routes {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		ri := Rank(idx.Routes[paths[i]].S3Key, idx.Mounts)
+		rj := Rank(idx.Routes[paths[j]].S3Key, idx.Mounts)
+		if ri != rj {
+			return ri < rj
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
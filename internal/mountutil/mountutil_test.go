@@ -0,0 +1,122 @@
+package mountutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+func TestSplitKey(t *testing.T) {
+	cases := []struct {
+		in       string
+		wantName string
+		wantRest string
+	}{
+		{"notes/a.md", "", "notes/a.md"},
+		{"@blog/notes/a.md", "blog", "notes/a.md"},
+		{"@blog", "", "@blog"},
+		{"@/a.md", "", "@/a.md"},
+	}
+	for _, tc := range cases {
+		name, rest := SplitKey(tc.in)
+		if name != tc.wantName || rest != tc.wantRest {
+			t.Fatalf("SplitKey(%q) = (%q, %q), want (%q, %q)", tc.in, name, rest, tc.wantName, tc.wantRest)
+		}
+	}
+	if got := NamespacedKey("blog", "notes/a.md"); got != "@blog/notes/a.md" {
+		t.Fatalf("NamespacedKey = %q", got)
+	}
+	if got := NamespacedKey("", "notes/a.md"); got != "notes/a.md" {
+		t.Fatalf("NamespacedKey without name = %q", got)
+	}
+}
+
+func TestLogicalKey(t *testing.T) {
+	mounts := map[string]models.MountInfo{
+		"docs": {MountPath: "docs", Prefix: "content/", Rank: 1},
+		"root": {Rank: 0},
+	}
+	cases := map[string]string{
+		"@docs/content/guide/a.md": "docs/guide/a.md",
+		"@root/a.md":               "a.md",
+		"plain/a.md":               "plain/a.md",
+		"@unknown/a.md":            "@unknown/a.md",
+	}
+	for in, want := range cases {
+		if got := LogicalKey(in, mounts); got != want {
+			t.Fatalf("LogicalKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReaderListAndFetchLocalMounts(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "blog", "post.md"), "# post")
+	writeFile(t, filepath.Join(dir, "garden", "idea.md"), "# idea")
+	writeFile(t, filepath.Join(dir, "garden", "image.png"), "png")
+
+	cfg := config.Config{Content: config.ContentConfig{
+		Source: "mounts",
+		Mounts: []config.ContentMount{
+			{Name: "garden", Source: "local", Dir: filepath.Join(dir, "garden")},
+			{Name: "blog", Source: "local", Dir: filepath.Join(dir, "blog"), MountPath: "blog"},
+		},
+		Precedence: []string{"blog"},
+	}}
+	r := NewReader(cfg, nil)
+	mounts := r.Mounts()
+	if len(mounts) != 2 || mounts[0].Name != "blog" || mounts[1].Name != "garden" {
+		t.Fatalf("unexpected mount order: %+v", mounts)
+	}
+	info := r.Info()
+	if info["blog"].Rank != 0 || info["garden"].Rank != 1 {
+		t.Fatalf("unexpected ranks: %+v", info)
+	}
+
+	objects, err := r.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	keys := map[string]bool{}
+	for _, obj := range objects {
+		keys[obj.Key] = true
+	}
+	if len(keys) != 2 || !keys["@blog/post.md"] || !keys["@garden/idea.md"] {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	body, err := r.Fetch(context.Background(), "@garden/idea.md")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(body) != "# idea" {
+		t.Fatalf("Fetch body = %q", string(body))
+	}
+}
+
+func TestFromConfigLegacySingleSource(t *testing.T) {
+	cfg := config.Config{
+		Content: config.ContentConfig{Source: "local", LocalDir: "/tmp/content"},
+		S3:      config.S3Config{Prefix: "notes/"},
+	}
+	mounts := FromConfig(cfg)
+	if len(mounts) != 1 || mounts[0].Name != "" || mounts[0].Dir != "/tmp/content" || mounts[0].Prefix != "notes/" {
+		t.Fatalf("unexpected legacy mount: %+v", mounts)
+	}
+	if NewReader(cfg, nil).Info() != nil {
+		t.Fatalf("expected nil mount info for legacy config")
+	}
+}
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
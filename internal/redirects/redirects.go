@@ -0,0 +1,119 @@
+// Package redirects exports the resolve index redirect table in formats
+// understood by static hosts and web servers.
+package redirects
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+// Rule is one permanent redirect.
+type Rule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+// Formats lists the supported export formats.
+var Formats = []string{"netlify", "nginx", "apache", "caddy", "json"}
+
+// Table collects redirects from idx: notes with `redirect_to` plus the
+// `redirects` table (redirect_from aliases and moved permalinks), sorted by From.
+func Table(idx models.ResolveIndex) []Rule {
+	out := make([]Rule, 0, len(idx.Redirects))
+	for p, rt := range idx.Routes {
+		if rt.Status == 301 && rt.RedirectTo != "" {
+			out = append(out, Rule{From: p, To: rt.RedirectTo, Status: 301})
+		}
+	}
+	for from, r := range idx.Redirects {
+		if _, ok := idx.Routes[from]; ok {
+			continue
+		}
+		out = append(out, Rule{From: from, To: r.To, Status: 301})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].From < out[j].From })
+	return out
+}
+
+// FileName is the conventional output file name for format.
+func FileName(format string) string {
+	switch format {
+	case "netlify":
+		return "_redirects"
+	case "nginx":
+		return "redirects.map"
+	case "apache":
+		return ".htaccess"
+	case "caddy":
+		return "redirects.caddy"
+	case "json":
+		return "redirects.json"
+	}
+	return ""
+}
+
+// Write renders rules in format to w.
+func Write(w io.Writer, format string, rules []Rule) error {
+	switch format {
+	case "netlify":
+		for _, r := range rules {
+			if _, err := fmt.Fprintf(w, "%s %s %d\n", escapeSpaces(r.From), escapeSpaces(r.To), r.Status); err != nil {
+				return err
+			}
+		}
+	case "nginx":
+		// Include inside the http block, then in a server block:
+		//   if ($notepub_redirect) { return 301 $notepub_redirect; }
+		if _, err := fmt.Fprintln(w, "map $uri $notepub_redirect {\n    default \"\";"); err != nil {
+			return err
+		}
+		for _, r := range rules {
+			if _, err := fmt.Fprintf(w, "    %s %s;\n", nginxQuote(r.From), nginxQuote(r.To)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, "}"); err != nil {
+			return err
+		}
+	case "apache":
+		for _, r := range rules {
+			if _, err := fmt.Fprintf(w, "Redirect %d %s %s\n", r.Status, apacheQuote(r.From), apacheQuote(r.To)); err != nil {
+				return err
+			}
+		}
+	case "caddy":
+		for _, r := range rules {
+			if _, err := fmt.Fprintf(w, "redir %s %s %d\n", escapeSpaces(r.From), escapeSpaces(r.To), r.Status); err != nil {
+				return err
+			}
+		}
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rules)
+	default:
+		return fmt.Errorf("unsupported redirects format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+func escapeSpaces(val string) string {
+	return strings.ReplaceAll(val, " ", "%20")
+}
+
+func nginxQuote(val string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val) + `"`
+}
+
+func apacheQuote(val string) string {
+	if !strings.ContainsAny(val, " \t\"") {
+		return val
+	}
+	return `"` + strings.ReplaceAll(val, `"`, `\"`) + `"`
+}
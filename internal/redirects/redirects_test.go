@@ -0,0 +1,43 @@
+package redirects
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+func TestTableAndFormats(t *testing.T) {
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/note":   {Status: 200},
+			"/legacy": {Status: 301, RedirectTo: "https://example.com/"},
+		},
+		Redirects: map[string]models.Redirect{
+			"/old note": {To: "/note", Source: "moved"},
+		},
+	}
+	rules := Table(idx)
+	if len(rules) != 2 || rules[0].From != "/legacy" || rules[1].From != "/old note" {
+		t.Fatalf("unexpected table: %+v", rules)
+	}
+	cases := map[string]string{
+		"netlify": "/old%20note /note 301\n",
+		"nginx":   `    "/old note" "/note";`,
+		"apache":  `Redirect 301 "/old note" /note`,
+		"caddy":   "redir /old%20note /note 301\n",
+		"json":    `"from": "/old note"`,
+	}
+	for format, want := range cases {
+		var b strings.Builder
+		if err := Write(&b, format, rules); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("%s: missing %q in:\n%s", format, want, b.String())
+		}
+	}
+	if err := Write(&strings.Builder{}, "iis", rules); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
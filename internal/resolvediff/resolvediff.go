@@ -0,0 +1,375 @@
+// Package resolvediff compares two resolve indexes to review what a deploy
+// changes publicly: routes, moves, noindex flips, redirects and page meta.
+package resolvediff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/indexer"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/redirects"
+)
+
+// Report is the difference between an old and a new resolve index. Slices
+// are sorted by path.
+type Report struct {
+	Added     []Route          `json:"added"`
+	Removed   []Removal        `json:"removed"`
+	Moved     []Move           `json:"moved"`
+	Changed   []Change         `json:"changed"`
+	NoIndex   []NoIndexFlip    `json:"noindex"`
+	Redirects []RedirectChange `json:"redirects"`
+	// BrokenLinks are links from pages that still exist to URLs that
+	// disappeared without a redirect.
+	BrokenLinks []BrokenLink `json:"broken_links"`
+}
+
+// Route is a published page.
+type Route struct {
+	Path  string `json:"path"`
+	Key   string `json:"key"`
+	Title string `json:"title,omitempty"`
+}
+
+// Removal is a page whose URL no longer serves it and whose note is gone or
+// no longer published (draft, scheduled or expired).
+// RedirectTo is empty when the URL disappears without a redirect.
+type Removal struct {
+	Route
+	RedirectTo string `json:"redirect_to,omitempty"`
+}
+
+// Move is a note served at a new URL (slug or permalink change).
+// RedirectTo is empty when the old URL disappears without a redirect.
+type Move struct {
+	Key        string `json:"key"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	OldSlug    string `json:"old_slug,omitempty"`
+	NewSlug    string `json:"new_slug,omitempty"`
+	RedirectTo string `json:"redirect_to,omitempty"`
+}
+
+// Change lists meta fields that differ for a page served at the same URL.
+type Change struct {
+	Path   string        `json:"path"`
+	Key    string        `json:"key"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is one differing field; "content" compares note ETags.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// NoIndexFlip is a page whose noindex flag changed.
+type NoIndexFlip struct {
+	Path string `json:"path"`
+	Old  bool   `json:"old"`
+	New  bool   `json:"new"`
+}
+
+// RedirectChange is a redirect that was added, removed or retargeted; an
+// empty OldTo or NewTo marks an added or removed redirect.
+type RedirectChange struct {
+	From  string `json:"from"`
+	OldTo string `json:"old_to,omitempty"`
+	NewTo string `json:"new_to,omitempty"`
+}
+
+// BrokenLink is a link from an existing page to a URL that disappeared.
+type BrokenLink struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Link string `json:"link"`
+}
+
+// Lost returns the old URLs that disappear without a redirect.
+func (r Report) Lost() []string {
+	out := []string{}
+	for _, rm := range r.Removed {
+		if rm.RedirectTo == "" {
+			out = append(out, rm.Path)
+		}
+	}
+	for _, mv := range r.Moved {
+		if mv.RedirectTo == "" {
+			out = append(out, mv.From)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Empty reports whether nothing public changed.
+func (r Report) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Moved) == 0 && len(r.Changed) == 0 &&
+		len(r.NoIndex) == 0 && len(r.Redirects) == 0
+}
+
+// Diff compares what oldIdx and newIdx publish at now. resolve.json keeps
+// drafts, scheduled and expired notes, so both are reduced to their public
+// view first; a note turning into a draft or expiring shows up as removed.
+func Diff(oldIdx, newIdx models.ResolveIndex, now time.Time) Report {
+	oldIdx = publicView(oldIdx, now)
+	newIdx = publicView(newIdx, now)
+	report := Report{
+		Added:       []Route{},
+		Removed:     []Removal{},
+		Moved:       []Move{},
+		Changed:     []Change{},
+		NoIndex:     []NoIndexFlip{},
+		Redirects:   []RedirectChange{},
+		BrokenLinks: []BrokenLink{},
+	}
+	oldPages := pages(oldIdx)
+	newPages := pages(newIdx)
+	oldRedirects := redirectMap(oldIdx)
+	newRedirects := redirectMap(newIdx)
+	newByKey := map[string]string{}
+	for p, rt := range newPages {
+		newByKey[rt.S3Key] = p
+	}
+	oldKeys := map[string]bool{}
+	for _, rt := range oldPages {
+		oldKeys[rt.S3Key] = true
+	}
+
+	lost := map[string]bool{}
+	for _, p := range sortedKeys(oldPages) {
+		rt := oldPages[p]
+		if newRt, ok := newPages[p]; ok {
+			compare(&report, p, rt, newRt, oldIdx.Meta[p], newIdx.Meta[p])
+			continue
+		}
+		redirectTo := newRedirects[p]
+		if redirectTo == "" {
+			lost[p] = true
+		}
+		if to, ok := newByKey[rt.S3Key]; ok && rt.S3Key != "" {
+			report.Moved = append(report.Moved, Move{
+				Key:        rt.S3Key,
+				From:       p,
+				To:         to,
+				OldSlug:    oldIdx.Meta[p].Slug,
+				NewSlug:    newIdx.Meta[to].Slug,
+				RedirectTo: redirectTo,
+			})
+			continue
+		}
+		report.Removed = append(report.Removed, Removal{
+			Route:      Route{Path: p, Key: rt.S3Key, Title: oldIdx.Meta[p].Title},
+			RedirectTo: redirectTo,
+		})
+	}
+	for _, p := range sortedKeys(newPages) {
+		rt := newPages[p]
+		if _, ok := oldPages[p]; ok || oldKeys[rt.S3Key] {
+			continue
+		}
+		report.Added = append(report.Added, Route{Path: p, Key: rt.S3Key, Title: newIdx.Meta[p].Title})
+	}
+
+	for _, from := range sortedKeys(oldRedirects) {
+		if to, ok := newRedirects[from]; !ok {
+			report.Redirects = append(report.Redirects, RedirectChange{From: from, OldTo: oldRedirects[from]})
+		} else if to != oldRedirects[from] {
+			report.Redirects = append(report.Redirects, RedirectChange{From: from, OldTo: oldRedirects[from], NewTo: to})
+		}
+	}
+	for _, from := range sortedKeys(newRedirects) {
+		if _, ok := oldRedirects[from]; !ok {
+			report.Redirects = append(report.Redirects, RedirectChange{From: from, NewTo: newRedirects[from]})
+		}
+	}
+	sort.SliceStable(report.Redirects, func(i, j int) bool { return report.Redirects[i].From < report.Redirects[j].From })
+
+	// Inbound links are only recorded as resolved paths, so they come from
+	// the old index; the linking page must still be published.
+	for _, from := range sortedKeys(oldIdx.Links) {
+		if _, ok := newPages[from]; !ok {
+			continue
+		}
+		byRule := oldIdx.Links[from]
+		for _, rule := range sortedKeys(byRule) {
+			for _, to := range byRule[rule] {
+				if lost[to] {
+					report.BrokenLinks = append(report.BrokenLinks, BrokenLink{From: from, To: to, Link: rule})
+				}
+			}
+		}
+	}
+	return report
+}
+
+func compare(report *Report, p string, oldRt, newRt models.RouteEntry, oldMeta, newMeta models.MetaEntry) {
+	if oldRt.NoIndex != newRt.NoIndex {
+		report.NoIndex = append(report.NoIndex, NoIndexFlip{Path: p, Old: oldRt.NoIndex, New: newRt.NoIndex})
+	}
+	fields := []FieldChange{}
+	add := func(field, oldVal, newVal string) {
+		if oldVal != newVal {
+			fields = append(fields, FieldChange{Field: field, Old: oldVal, New: newVal})
+		}
+	}
+	add("key", oldRt.S3Key, newRt.S3Key)
+	add("type", oldMeta.Type, newMeta.Type)
+	add("slug", oldMeta.Slug, newMeta.Slug)
+	add("title", oldMeta.Title, newMeta.Title)
+	add("description", oldMeta.Description, newMeta.Description)
+	add("canonical", oldMeta.Canonical, newMeta.Canonical)
+	add("robots", oldMeta.Robots, newMeta.Robots)
+	add("image", oldMeta.Image, newMeta.Image)
+	add("lang", oldMeta.Lang, newMeta.Lang)
+	if oldRt.S3Key == newRt.S3Key {
+		add("content", oldRt.ETag, newRt.ETag)
+	}
+	if len(fields) > 0 {
+		report.Changed = append(report.Changed, Change{Path: p, Key: newRt.S3Key, Fields: fields})
+	}
+}
+
+func publicView(idx models.ResolveIndex, now time.Time) models.ResolveIndex {
+	view, _ := indexer.PublishedView(indexer.ExcludeDrafts(idx), now)
+	return view
+}
+
+// pages returns routes that serve a note.
+func pages(idx models.ResolveIndex) map[string]models.RouteEntry {
+	out := map[string]models.RouteEntry{}
+	for p, rt := range idx.Routes {
+		if rt.Status == 200 {
+			out[p] = rt
+		}
+	}
+	return out
+}
+
+func redirectMap(idx models.ResolveIndex) map[string]string {
+	out := map[string]string{}
+	for _, r := range redirects.Table(idx) {
+		out[r.From] = r.To
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteJSON renders report as indented JSON with a summary.
+func WriteJSON(w io.Writer, report Report) error {
+	out := struct {
+		Report
+		Summary map[string]int `json:"summary"`
+	}{Report: report, Summary: map[string]int{
+		"added":        len(report.Added),
+		"removed":      len(report.Removed),
+		"moved":        len(report.Moved),
+		"changed":      len(report.Changed),
+		"noindex":      len(report.NoIndex),
+		"redirects":    len(report.Redirects),
+		"broken_links": len(report.BrokenLinks),
+		"lost":         len(report.Lost()),
+	}}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteText renders report for people reviewing a deploy.
+func WriteText(w io.Writer, report Report) error {
+	ew := &errWriter{w: w}
+	if report.Empty() {
+		ew.printf("No public changes.\n")
+		return ew.err
+	}
+	ew.printf("Routes: %d added, %d removed, %d moved, %d changed\n",
+		len(report.Added), len(report.Removed), len(report.Moved), len(report.Changed))
+	section := func(title string, n int) bool {
+		if n == 0 {
+			return false
+		}
+		ew.printf("\n%s (%d):\n", title, n)
+		return true
+	}
+	if section("Added", len(report.Added)) {
+		for _, r := range report.Added {
+			ew.printf("  + %s  %s\n", r.Path, r.Key)
+		}
+	}
+	if section("Removed", len(report.Removed)) {
+		for _, r := range report.Removed {
+			ew.printf("  - %s  %s%s\n", r.Path, r.Key, redirectNote(r.RedirectTo))
+		}
+	}
+	if section("Moved", len(report.Moved)) {
+		for _, m := range report.Moved {
+			ew.printf("  ~ %s -> %s  %s%s\n", m.From, m.To, m.Key, redirectNote(m.RedirectTo))
+		}
+	}
+	if section("Changed", len(report.Changed)) {
+		for _, c := range report.Changed {
+			ew.printf("  * %s\n", c.Path)
+			for _, f := range c.Fields {
+				ew.printf("      %s: %q -> %q\n", f.Field, f.Old, f.New)
+			}
+		}
+	}
+	if section("Noindex", len(report.NoIndex)) {
+		for _, n := range report.NoIndex {
+			ew.printf("  ! %s  noindex %t -> %t\n", n.Path, n.Old, n.New)
+		}
+	}
+	if section("Redirects", len(report.Redirects)) {
+		for _, r := range report.Redirects {
+			switch {
+			case r.OldTo == "":
+				ew.printf("  + %s -> %s\n", r.From, r.NewTo)
+			case r.NewTo == "":
+				ew.printf("  - %s -> %s\n", r.From, r.OldTo)
+			default:
+				ew.printf("  ~ %s -> %s (was %s)\n", r.From, r.NewTo, r.OldTo)
+			}
+		}
+	}
+	if section("Broken inbound links", len(report.BrokenLinks)) {
+		for _, l := range report.BrokenLinks {
+			ew.printf("  x %s -> %s (%s)\n", l.From, l.To, l.Link)
+		}
+	}
+	if lost := report.Lost(); len(lost) > 0 {
+		ew.printf("\n%d URL(s) disappear without a redirect.\n", len(lost))
+	}
+	return ew.err
+}
+
+func redirectNote(to string) string {
+	if to == "" {
+		return "  [no redirect]"
+	}
+	return "  [redirects to " + to + "]"
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
@@ -0,0 +1,159 @@
+package resolvediff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/models"
+)
+
+func page(key string) models.RouteEntry {
+	return models.RouteEntry{S3Key: key, ETag: "e-" + key, Status: 200}
+}
+
+func TestDiffClassifiesRoutes(t *testing.T) {
+	oldIdx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/":        page("home.md"),
+			"/a/":      page("a.md"),
+			"/b/":      page("b.md"),
+			"/gone/":   page("gone.md"),
+			"/hidden/": page("hidden.md"),
+			"/old-c/":  page("c.md"),
+		},
+		Meta: map[string]models.MetaEntry{
+			"/":       {Title: "Home"},
+			"/a/":     {Title: "A", Slug: "a"},
+			"/old-c/": {Slug: "old-c"},
+		},
+		Links: map[string]map[string][]string{
+			"/":   {"wiki": {"/gone/", "/old-c/"}},
+			"/a/": {"wiki": {"/b/"}},
+		},
+		Redirects: map[string]models.Redirect{"/legacy/": {To: "/a/", Source: "redirect_from"}},
+	}
+	hidden := page("hidden.md")
+	hidden.NoIndex = true
+	newIdx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/":        page("home.md"),
+			"/a/":      page("a.md"),
+			"/hidden/": hidden,
+			"/c/":      page("c.md"),
+			"/new/":    page("new.md"),
+		},
+		Meta: map[string]models.MetaEntry{
+			"/":     {Title: "Home"},
+			"/a/":   {Title: "A2", Slug: "a"},
+			"/c/":   {Slug: "c"},
+			"/new/": {Title: "New"},
+		},
+		Redirects: map[string]models.Redirect{
+			"/b/":      {To: "/a/", Source: "redirect_from"},
+			"/legacy/": {To: "/", Source: "redirect_from"},
+		},
+	}
+
+	r := Diff(oldIdx, newIdx, time.Now())
+	if len(r.Added) != 1 || r.Added[0].Path != "/new/" {
+		t.Fatalf("added = %+v", r.Added)
+	}
+	if len(r.Moved) != 1 || r.Moved[0].From != "/old-c/" || r.Moved[0].To != "/c/" || r.Moved[0].NewSlug != "c" || r.Moved[0].RedirectTo != "" {
+		t.Fatalf("moved = %+v", r.Moved)
+	}
+	if len(r.Removed) != 2 || r.Removed[0].Path != "/b/" || r.Removed[0].RedirectTo != "/a/" || r.Removed[1].Path != "/gone/" {
+		t.Fatalf("removed = %+v", r.Removed)
+	}
+	if got := r.Lost(); strings.Join(got, ",") != "/gone/,/old-c/" {
+		t.Fatalf("lost = %v", got)
+	}
+	if len(r.Changed) != 1 || r.Changed[0].Path != "/a/" || r.Changed[0].Fields[0].Field != "title" {
+		t.Fatalf("changed = %+v", r.Changed)
+	}
+	if len(r.NoIndex) != 1 || !r.NoIndex[0].New {
+		t.Fatalf("noindex = %+v", r.NoIndex)
+	}
+	if len(r.Redirects) != 2 || r.Redirects[0].From != "/b/" || r.Redirects[0].OldTo != "" || r.Redirects[1].OldTo != "/a/" || r.Redirects[1].NewTo != "/" {
+		t.Fatalf("redirects = %+v", r.Redirects)
+	}
+	if len(r.BrokenLinks) != 2 || r.BrokenLinks[0].To != "/gone/" || r.BrokenLinks[1].To != "/old-c/" {
+		t.Fatalf("broken links = %+v", r.BrokenLinks)
+	}
+}
+
+func TestDiffMoveWithRedirectIsNotLost(t *testing.T) {
+	oldIdx := models.ResolveIndex{Routes: map[string]models.RouteEntry{"/x/": page("x.md")}}
+	newIdx := models.ResolveIndex{
+		Routes:    map[string]models.RouteEntry{"/y/": page("x.md")},
+		Redirects: map[string]models.Redirect{"/x/": {To: "/y/", Source: "moved"}},
+	}
+	r := Diff(oldIdx, newIdx, time.Now())
+	if len(r.Moved) != 1 || r.Moved[0].RedirectTo != "/y/" || len(r.Lost()) != 0 || len(r.Added) != 0 {
+		t.Fatalf("report = %+v", r)
+	}
+}
+
+func TestDiffUnpublishedNotesAreRemoved(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	oldIdx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/a/": page("a.md"), "/b/": page("b.md"), "/c/": page("c.md")},
+		Meta:   map[string]models.MetaEntry{"/a/": {Title: "A"}, "/b/": {Title: "B"}, "/c/": {Title: "C", Draft: true}},
+		Links:  map[string]map[string][]string{"/b/": {"wikilinks": {"/a/"}}},
+	}
+	newIdx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/a/": page("a.md"), "/b/": page("b.md"), "/c/": page("c.md")},
+		Meta: map[string]models.MetaEntry{
+			"/a/": {Title: "A", Draft: true},
+			"/b/": {Title: "B", ExpireAt: "2026-04-01T00:00:00Z"},
+			"/c/": {Title: "C"},
+		},
+	}
+	r := Diff(oldIdx, newIdx, now)
+	if len(r.Removed) != 2 || r.Removed[0].Path != "/a/" || r.Removed[1].Path != "/b/" {
+		t.Fatalf("removed = %+v", r.Removed)
+	}
+	if len(r.Added) != 1 || r.Added[0].Path != "/c/" {
+		t.Fatalf("added = %+v", r.Added)
+	}
+	if lost := r.Lost(); len(lost) != 2 || r.Empty() {
+		t.Fatalf("lost = %v", lost)
+	}
+}
+
+func TestWriteOutputs(t *testing.T) {
+	idx := models.ResolveIndex{Routes: map[string]models.RouteEntry{"/": page("home.md")}}
+	var buf bytes.Buffer
+	if err := WriteText(&buf, Diff(idx, idx, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "No public changes.\n" {
+		t.Fatalf("text = %q", buf.String())
+	}
+
+	buf.Reset()
+	r := Diff(idx, models.ResolveIndex{Routes: map[string]models.RouteEntry{}}, time.Now())
+	if err := WriteText(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "- /  home.md  [no redirect]") || !strings.Contains(buf.String(), "1 URL(s) disappear without a redirect") {
+		t.Fatalf("text = %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Removed []Removal      `json:"removed"`
+		Summary map[string]int `json:"summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Removed) != 1 || out.Summary["lost"] != 1 {
+		t.Fatalf("json = %s", buf.String())
+	}
+}
@@ -2,14 +2,17 @@ package rules
 
 import (
 	"os"
+	"sort"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/cookiespooky/notepub/internal/yamlutil"
 )
 
 type Rules struct {
 	Version     int                       `yaml:"version"`
 	Fields      FieldContract             `yaml:"fields"`
-	FMSchema    map[string]string         `yaml:"fm_schema"`
+	FMFields    map[string]FieldSchema    `yaml:"fm_schema"`
 	Types       map[string]TypeDef        `yaml:"types"`
 	Links       []LinkRule                `yaml:"links"`
 	Collections map[string]CollectionRule `yaml:"collections"`
@@ -17,6 +20,44 @@ type Rules struct {
 	Search      SearchRule                `yaml:"search"`
 	Artifacts   ArtifactsRule             `yaml:"artifacts"`
 	Validation  ValidationRule            `yaml:"validation"`
+	// FMSchema maps fm_schema fields to their type; collections use it for comparisons.
+	FMSchema map[string]string `yaml:"-"`
+	// TypeOrder lists type names in declaration order; match rules are tried in this order.
+	TypeOrder []string `yaml:"-"`
+	// Source is the rules file path and Lines the line of each key in it,
+	// used to point validation errors at the offending entry.
+	Source string         `yaml:"-"`
+	Lines  yamlutil.Lines `yaml:"-"`
+}
+
+// Pos returns "file:line" for a dotted key path such as
+// "collections.recent.link", or the file alone when the line is unknown.
+func (r Rules) Pos(path string) string {
+	file := r.Source
+	if file == "" {
+		file = "rules"
+	}
+	return yamlutil.Pos(file, r.Lines[path])
+}
+
+// FieldSchema describes a frontmatter field. In rules.yaml it is either a
+// bare type (`price: number`) or a mapping with constraints.
+type FieldSchema struct {
+	Type     string   `yaml:"type"`
+	Values   []string `yaml:"values,omitempty"`
+	Min      *float64 `yaml:"min,omitempty"`
+	Max      *float64 `yaml:"max,omitempty"`
+	Pattern  string   `yaml:"pattern,omitempty"`
+	Severity string   `yaml:"severity,omitempty"`
+}
+
+func (f *FieldSchema) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Type = node.Value
+		return nil
+	}
+	type plain FieldSchema
+	return node.Decode((*plain)(f))
 }
 
 type FieldContract struct {
@@ -26,11 +67,24 @@ type FieldContract struct {
 }
 
 type TypeDef struct {
-	Template  string        `yaml:"template"`
-	Permalink string        `yaml:"permalink"`
+	Template  string `yaml:"template"`
+	Permalink string `yaml:"permalink"`
+	AutoSlug  string `yaml:"auto_slug,omitempty"`
+	// Dir is where `notepub new` creates notes of this type, relative to the content dir.
+	Dir       string        `yaml:"dir,omitempty"`
+	Match     []TypeMatch   `yaml:"match,omitempty"`
+	Required  []string      `yaml:"required,omitempty"`
+	Optional  []string      `yaml:"optional,omitempty"`
 	IncludeIn IncludeInRule `yaml:"include_in"`
 }
 
+// TypeMatch assigns a type to notes without `type`. All set conditions must hold.
+type TypeMatch struct {
+	Path     string                 `yaml:"path,omitempty"`
+	Filename string                 `yaml:"filename,omitempty"`
+	FM       map[string]interface{} `yaml:"fm,omitempty"`
+}
+
 type IncludeInRule struct {
 	Sitemap bool `yaml:"sitemap"`
 	Search  bool `yaml:"search"`
@@ -120,14 +174,25 @@ type CollectionsArtifactsRule struct {
 }
 
 type ValidationRule struct {
-	SinglePageOfType                    map[string]int `yaml:"single_page_of_type"`
-	DuplicateRoute                      ActionRule     `yaml:"duplicate_route"`
-	UnknownType                         ActionRule     `yaml:"unknown_type"`
-	UniqueSlug                          ActionRule     `yaml:"unique_slug"`
-	PermalinkRequiresSlug               ActionRule     `yaml:"permalink_requires_slug"`
-	MissingTemplate                     ActionRule     `yaml:"missing_template"`
-	MaterializeRequiresLimit            bool           `yaml:"materialize_requires_limit"`
-	MaterializeGroupByRequiresItemLimit bool           `yaml:"materialize_group_by_requires_item_limit"`
+	SinglePageOfType                    map[string]int    `yaml:"single_page_of_type"`
+	DuplicateRoute                      ActionRule        `yaml:"duplicate_route"`
+	UnknownType                         ActionRule        `yaml:"unknown_type"`
+	UniqueSlug                          ActionRule        `yaml:"unique_slug"`
+	PermalinkRequiresSlug               ActionRule        `yaml:"permalink_requires_slug"`
+	FMSchema                            ActionRule        `yaml:"fm_schema"`
+	MissingTemplate                     ActionRule        `yaml:"missing_template"`
+	MaterializeRequiresLimit            bool              `yaml:"materialize_requires_limit"`
+	MaterializeGroupByRequiresItemLimit bool              `yaml:"materialize_group_by_requires_item_limit"`
+	ExternalLinks                       ExternalLinksRule `yaml:"external_links"`
+}
+
+// ExternalLinksRule tunes `validate --external`. Durations use Go syntax ("500ms", "24h").
+type ExternalLinksRule struct {
+	Concurrency     int      `yaml:"concurrency"`
+	PerHostInterval string   `yaml:"per_host_interval"`
+	Timeout         string   `yaml:"timeout"`
+	CacheTTL        string   `yaml:"cache_ttl"`
+	Ignore          []string `yaml:"ignore"`
 }
 
 type ActionRule struct {
@@ -143,17 +208,21 @@ func Load(path string) (Rules, error) {
 		return Rules{}, err
 	}
 	var out Rules
-	if err := yaml.Unmarshal(data, &out); err != nil {
+	lines, err := yamlutil.DecodeStrict(path, data, &out)
+	if err != nil {
 		return Rules{}, err
 	}
+	out.Source = path
+	out.Lines = lines
 	if out.Version == 0 {
 		out.Version = 1
 	}
 	if out.Fields.Defaults == nil {
 		out.Fields.Defaults = map[string]interface{}{}
 	}
-	if out.FMSchema == nil {
-		out.FMSchema = map[string]string{}
+	out.FMSchema = make(map[string]string, len(out.FMFields))
+	for name, field := range out.FMFields {
+		out.FMSchema[name] = field.Type
 	}
 	if out.Types == nil {
 		out.Types = map[string]TypeDef{}
@@ -161,5 +230,29 @@ func Load(path string) (Rules, error) {
 	if out.Collections == nil {
 		out.Collections = map[string]CollectionRule{}
 	}
+	out.TypeOrder = typeOrder(data, out.Types)
 	return out, nil
 }
+
+func typeOrder(data []byte, types map[string]TypeDef) []string {
+	var doc struct {
+		Types yaml.Node `yaml:"types"`
+	}
+	order := make([]string, 0, len(types))
+	if err := yaml.Unmarshal(data, &doc); err == nil && doc.Types.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(doc.Types.Content); i += 2 {
+			name := doc.Types.Content[i].Value
+			if _, ok := types[name]; ok {
+				order = append(order, name)
+			}
+		}
+	}
+	if len(order) != len(types) {
+		order = order[:0]
+		for name := range types {
+			order = append(order, name)
+		}
+		sort.Strings(order)
+	}
+	return order
+}
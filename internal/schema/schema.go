@@ -0,0 +1,194 @@
+// Package schema generates JSON Schema for config.yaml and rules.yaml from
+// the Go types that load them, so editors can autocomplete and validate.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+// Names lists the documents Generate knows.
+var Names = []string{"config", "rules"}
+
+// FileName is the conventional file name for the named schema, written next
+// to the YAML file it describes.
+func FileName(name string) string {
+	return name + ".schema.json"
+}
+
+// enums constrains string fields, keyed by "<Go type>.<yaml key>". For list
+// fields the values apply to the items.
+var enums = map[string][]string{
+	"config.Config.compat_mode":         {"auto", "modern", "legacy"},
+	"config.RuntimeConfig.mode":         {"auto", "dev", "prod", "development", "production"},
+	"config.ContentConfig.source":       {"local", "s3"},
+	"config.ContentMount.source":        {"local", "s3"},
+	"config.MarkdownConfig.html_policy": {"safe", "unsafe", "deny"},
+
+	"rules.TypeDef.auto_slug":     {"title", "filename"},
+	"rules.LinkRule.kind":         {"field", "wikilinks", "markdown_links", "embeds", "tags", "auto_links"},
+	"rules.LinkRule.value_syntax": {"plain", "auto", "wikilink", "markdown_link", "markdown_image"},
+	"rules.LinkRule.resolve_by":   {"wikimap"},
+	"rules.ResolveRule.order":     {"path", "filename", "slug"},
+	"rules.ResolveRule.ambiguity": {"error", "warn"},
+	"rules.ResolveRule.missing":   {"error", "warn_skip", "ignore"},
+	"rules.ResolveRule.case":      {"sensitive", "insensitive"},
+	"rules.CollectionRule.kind":   {"filter", "forward", "backrefs"},
+	"rules.SortRule.dir":          {"asc", "desc"},
+	"rules.FieldSchema.type":      fieldTypes,
+	"rules.FieldSchema.severity":  {"error", "warn"},
+	"rules.ActionRule.action":     {"error", "warn", "ignore"},
+}
+
+// fieldTypes mirrors the fm_schema types accepted by the indexer.
+var fieldTypes = []string{"string", "number", "boolean", "date", "string[]", "enum", "url", "pattern"}
+
+// descriptions document keys whose meaning is not obvious from the name.
+var descriptions = map[string]string{
+	"config.Config.compat_mode":         "auto picks modern when settings or overrides are present.",
+	"config.Config.rules_path":          "Path to rules.yaml; defaults to rules.yaml next to this file.",
+	"config.ContentConfig.local_dir":    "Relative to this config file.",
+	"config.ContentConfig.precedence":   "Mount names in priority order for wikilink and path collisions.",
+	"config.ThemeConfig.dir":            "Relative to the working directory; the theme is <dir>/<name>.",
+	"config.ThemeConfig.extends":        "Parent theme in dir for missing templates and assets; none disables the embedded fallback.",
+	"config.RuntimeConfig.mode":         "auto uses dev URLs locally and prod URLs in CI.",
+	"rules.TypeDef.permalink":           "Route template, e.g. /{{ slug }}/; variables: slug, type, date.year|month|day, fm.<field>, dir, filename.",
+	"rules.TypeDef.dir":                 "Folder for `notepub new`, relative to the content dir.",
+	"rules.CollectionRule.link":         "Name of an entry in links; required for forward and backrefs.",
+	"rules.SortRule.by":                 "title, slug, created_at, updated_at or fm.<field>.",
+	"rules.GroupByRule.by":              "type or fm.<field>.",
+	"rules.Rules.fm_schema":             "Frontmatter field types: a bare type name or a mapping with constraints.",
+	"rules.ExternalLinksRule.timeout":   "Go duration, e.g. 10s.",
+	"rules.ExternalLinksRule.cache_ttl": "Go duration, e.g. 24h.",
+}
+
+// Generate returns the indented JSON Schema for "config" or "rules".
+func Generate(name string) ([]byte, error) {
+	var (
+		root  reflect.Type
+		title string
+	)
+	switch name {
+	case "config":
+		root, title = reflect.TypeOf(config.Config{}), "Notepub config.yaml"
+	case "rules":
+		root, title = reflect.TypeOf(rules.Rules{}), "Notepub rules.yaml"
+	default:
+		return nil, fmt.Errorf("unknown schema %q (use %s)", name, strings.Join(Names, " or "))
+	}
+	g := generator{defs: map[string]map[string]interface{}{}}
+	doc := g.structSchema(root)
+	doc["$schema"] = "http://json-schema.org/draft-07/schema#"
+	doc["title"] = title
+	if len(g.defs) > 0 {
+		defs := map[string]interface{}{}
+		for k, v := range g.defs {
+			defs[k] = v
+		}
+		doc["definitions"] = defs
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+type generator struct {
+	defs map[string]map[string]interface{}
+}
+
+// typeSchema returns the schema for t, referencing nested structs by definition.
+func (g *generator) typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // reserve against recursion
+			g.defs[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema describes a struct's yaml keys. Types with a custom YAML
+// unmarshaler also accept a scalar shorthand, e.g. `price: number`.
+func (g *generator) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var shorthand map[string]interface{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		key := t.String() + "." + name
+		s := g.typeSchema(f.Type)
+		if values := enums[key]; len(values) > 0 {
+			target := s
+			if items, ok := s["items"].(map[string]interface{}); ok {
+				target = items
+			}
+			target["enum"] = values
+			if shorthand == nil && reflect.PtrTo(t).Implements(unmarshalerType) {
+				shorthand = map[string]interface{}{"type": "string", "enum": values}
+			}
+		}
+		if d := descriptions[key]; d != "" {
+			s = withDescription(s, d)
+		}
+		props[name] = s
+	}
+	obj := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if shorthand != nil {
+		return map[string]interface{}{"anyOf": []interface{}{shorthand, obj}}
+	}
+	return obj
+}
+
+// withDescription adds a description; $ref siblings are ignored by draft-07
+// validators, so references are wrapped in allOf.
+func withDescription(s map[string]interface{}, d string) map[string]interface{} {
+	if _, ok := s["$ref"]; ok {
+		return map[string]interface{}{"allOf": []interface{}{s}, "description": d}
+	}
+	s["description"] = d
+	return s
+}
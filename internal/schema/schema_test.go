@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestGenerateIncludesEnums(t *testing.T) {
+	data, err := Generate("rules")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	var doc struct {
+		Properties  map[string]json.RawMessage `json:"properties"`
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				Enum  []string `json:"enum"`
+				Items struct {
+					Enum []string `json:"enum"`
+				} `json:"items"`
+			} `json:"properties"`
+			AdditionalProperties *bool             `json:"additionalProperties"`
+			AnyOf                []json.RawMessage `json:"anyOf"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if _, ok := doc.Properties["collections"]; !ok {
+		t.Fatalf("missing collections property")
+	}
+	if got := doc.Definitions["CollectionRule"].Properties["kind"].Enum; strings.Join(got, ",") != "filter,forward,backrefs" {
+		t.Fatalf("collection kind enum = %v", got)
+	}
+	if got := doc.Definitions["ResolveRule"].Properties["order"].Items.Enum; len(got) != 3 {
+		t.Fatalf("resolve order enum = %v", got)
+	}
+	if ap := doc.Definitions["LinkRule"].AdditionalProperties; ap == nil || *ap {
+		t.Fatalf("LinkRule should reject unknown keys")
+	}
+	if len(doc.Definitions["FieldSchema"].AnyOf) != 2 {
+		t.Fatalf("FieldSchema should accept a type name or a mapping")
+	}
+}
+
+func TestEnumKeysMatchFields(t *testing.T) {
+	known := map[string]bool{}
+	var walk func(reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || known[t.String()] {
+			return
+		}
+		known[t.String()] = true
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			known[t.String()+"."+name] = true
+			walk(t.Field(i).Type)
+		}
+	}
+	walk(reflect.TypeOf(config.Config{}))
+	walk(reflect.TypeOf(rules.Rules{}))
+	for key := range enums {
+		if !known[key] {
+			t.Errorf("enum key %q does not match a yaml field", key)
+		}
+	}
+	for key := range descriptions {
+		if !known[key] {
+			t.Errorf("description key %q does not match a yaml field", key)
+		}
+	}
+}
+
+func TestGenerateUnknown(t *testing.T) {
+	if _, err := Generate("theme"); err == nil {
+		t.Fatalf("expected error for unknown schema")
+	}
+}
@@ -1,6 +1,7 @@
 package serve
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"encoding/xml"
@@ -14,14 +15,13 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/cookiespooky/notepub/internal/config"
 	"github.com/cookiespooky/notepub/internal/indexer"
-	"github.com/cookiespooky/notepub/internal/localutil"
 	"github.com/cookiespooky/notepub/internal/mediautil"
 	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/mountutil"
+	"github.com/cookiespooky/notepub/internal/redirects"
 	"github.com/cookiespooky/notepub/internal/rules"
-	"github.com/cookiespooky/notepub/internal/s3util"
 	"github.com/yuin/goldmark/parser"
 )
 
@@ -30,6 +30,8 @@ type BuildOptions struct {
 	ArtifactsDir   string
 	NoIndex        bool
 	GenerateSearch bool
+	// Redirects writes the redirect table in this format (see redirects.Formats).
+	Redirects string
 }
 
 func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts BuildOptions) error {
@@ -51,6 +53,15 @@ func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts Bu
 		}
 	}
 
+	if opts.Redirects != "" && redirects.FileName(opts.Redirects) == "" {
+		return fmt.Errorf("unsupported redirects format %q (use %s)", opts.Redirects, strings.Join(redirects.Formats, ", "))
+	}
+
+	theme, err := LoadThemeConfig(cfg.Theme)
+	if err != nil {
+		return fmt.Errorf("load theme: %w", err)
+	}
+
 	resolvePath := filepath.Join(artifactsDir, "resolve.json")
 	if _, err := os.Stat(resolvePath); err != nil {
 		if opts.NoIndex {
@@ -58,7 +69,7 @@ func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts Bu
 		}
 		cfgForIndex := cfg
 		cfgForIndex.Paths.ArtifactsDir = artifactsDir
-		if err := indexer.Run(ctx, cfgForIndex); err != nil {
+		if _, err := indexer.RunWithReport(ctx, cfgForIndex, indexer.RunOptions{Templates: theme.TemplateNames()}); err != nil {
 			return fmt.Errorf("index before build: %w", err)
 		}
 	}
@@ -67,29 +78,13 @@ func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts Bu
 	if err != nil {
 		return err
 	}
+	idx, _ = indexer.PublishedView(indexer.ExcludeDrafts(idx), time.Now())
 
-	themeDir := filepath.Join(cfg.Theme.Dir, cfg.Theme.Name)
-	theme, err := LoadTheme(themeDir, cfg.Theme.TemplatesSubdir, cfg.Theme.AssetsSubdir)
+	s3client, err := mountutil.NewClient(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("load theme: %w", err)
-	}
-
-	var s3client *s3.Client
-	if cfg.Content.Source == "s3" {
-		s3client, err = s3util.NewClient(ctx, s3util.Config{
-			Endpoint:       cfg.S3.Endpoint,
-			Region:         cfg.S3.Region,
-			ForcePathStyle: cfg.S3.ForcePathStyle,
-			Bucket:         cfg.S3.Bucket,
-			Prefix:         cfg.S3.Prefix,
-			AccessKey:      cfg.S3.AccessKey,
-			SecretKey:      cfg.S3.SecretKey,
-			Anonymous:      cfg.S3.Anonymous,
-		})
-		if err != nil {
-			return fmt.Errorf("s3 client: %w", err)
-		}
+		return fmt.Errorf("s3 client: %w", err)
 	}
+	reader := mountutil.NewReader(cfg, s3client)
 
 	if err := resetDir(distDir); err != nil {
 		return err
@@ -103,6 +98,7 @@ func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts Bu
 
 	md := newMarkdownRenderer()
 	wikiMap := buildWikiMap(idx)
+	langWikiMaps := buildLangWikiMaps(idx, wikiMap)
 	paths := sortedRoutes(idx.Routes)
 	for _, pathVal := range paths {
 		route := idx.Routes[pathVal]
@@ -121,35 +117,26 @@ func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts Bu
 			continue
 		}
 
-		var body []byte
-		switch cfg.Content.Source {
-		case "local":
-			body, err = localutil.FetchObject(cfg.Content.LocalDir, route.S3Key)
-		case "s3":
-			if s3client == nil {
-				return fmt.Errorf("s3 client is not initialized")
-			}
-			body, err = s3util.FetchObject(ctx, s3client, cfg.S3.Bucket, route.S3Key)
-		default:
-			return fmt.Errorf("unsupported content source: %s", cfg.Content.Source)
-		}
+		body, err := reader.Fetch(ctx, route.S3Key)
 		if err != nil {
 			return fmt.Errorf("fetch %s: %w", route.S3Key, err)
 		}
-		rendered, err := renderMarkdownForBuild(string(body), route.S3Key, cfg.S3.Prefix, cfg.Site.MediaBaseURL, cfg.Site.BaseURL, wikiMap, md, cfg.Markdown.HTMLPolicy)
+		pageWikiMap := wikiMap
+		if l, ok := langWikiMaps[meta.Lang]; ok {
+			pageWikiMap = l
+		}
+		scData := buildPageData(meta, "", cfg)
+		scData.funcs = newTemplateContext(cfg, rulesCfg, idx, pathVal, md)
+		shortcodes := newShortcodeRenderer(theme, scData, md, cfg.Markdown.HTMLPolicy)
+		shortcodes.media = func(src string) string {
+			return mediautil.ResolveMediaLink(src, route.S3Key, cfg.S3.Prefix, cfg.Site.MediaBaseURL)
+		}
+		rendered, err := renderMarkdownForBuild(string(body), route.S3Key, cfg.S3.Prefix, cfg.Site.MediaBaseURL, cfg.Site.BaseURL, pageWikiMap, md, cfg.Markdown.HTMLPolicy, shortcodes)
 		if err != nil {
 			return fmt.Errorf("render markdown %s: %w", route.S3Key, err)
 		}
 
-		meta = normalizeMetaMediaURLs(meta, cfg.Site.MediaBaseURL, cfg.Site.BaseURL)
-		data := buildPageData(meta, rendered, cfg)
-		data.Template = templateForType(meta.Type, rulesCfg)
-		data.Page.NoIndex = route.NoIndex
-		data.SearchMode = "static"
-		if pathVal == "/" {
-			data.IsHome = true
-		}
-		data.Collections = buildCollections(idx, rulesCfg, pathVal)
+		data := buildRoutePageData(cfg, rulesCfg, idx, pathVal, rendered, md)
 		html, err := theme.RenderPage(data)
 		if err != nil {
 			return fmt.Errorf("render page %s: %w", pathVal, err)
@@ -159,6 +146,24 @@ func Build(ctx context.Context, cfg config.Config, rulesCfg rules.Rules, opts Bu
 		}
 	}
 
+	for _, from := range sortedRedirects(idx.Redirects) {
+		if _, ok := idx.Routes[from]; ok {
+			continue
+		}
+		if err := writeRedirectPage(outputPath(distDir, from), cfg.Site.BaseURL, idx.Redirects[from].To); err != nil {
+			return err
+		}
+	}
+	if opts.Redirects != "" {
+		var buf bytes.Buffer
+		if err := redirects.Write(&buf, opts.Redirects, redirects.Table(idx)); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(distDir, redirects.FileName(opts.Redirects)), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
 	notFound, err := theme.RenderNotFound(cfg.Site.BaseURL, cfg.Settings)
 	if err != nil {
 		notFound = "Not Found"
@@ -191,32 +196,16 @@ func resetDir(dir string) error {
 
 func copyThemeAssets(theme *Theme, distDir string) error {
 	assetFS := theme.AssetFS()
-	assetRoot := theme.assetsSubdir
-	if assetRoot == "" {
-		assetRoot = "assets"
-	}
-	if _, err := fs.Stat(assetFS, assetRoot); err != nil {
-		return nil
-	}
-	return fs.WalkDir(assetFS, assetRoot, func(p string, d fs.DirEntry, err error) error {
+	for _, f := range theme.Assets() {
+		data, err := fs.ReadFile(assetFS, f.Name)
 		if err != nil {
 			return err
 		}
-		if d.IsDir() {
-			return nil
-		}
-		rel := strings.TrimPrefix(p, assetRoot)
-		rel = strings.TrimPrefix(rel, "/")
-		if rel == "" {
-			return nil
-		}
-		data, err := fs.ReadFile(assetFS, p)
-		if err != nil {
+		if err := writeFile(filepath.Join(distDir, "assets", filepath.FromSlash(f.Name)), data); err != nil {
 			return err
 		}
-		dst := filepath.Join(distDir, "assets", filepath.FromSlash(rel))
-		return writeFile(dst, data)
-	})
+	}
+	return nil
 }
 
 func copyArtifacts(idx models.ResolveIndex, cfg config.Config, rulesCfg rules.Rules, artifactsDir, distDir string, generateSearch bool) error {
@@ -240,6 +229,15 @@ func copyArtifacts(idx models.ResolveIndex, cfg config.Config, rulesCfg rules.Ru
 		if err := copyFile(searchPath, filepath.Join(distDir, "search.json")); err != nil {
 			return err
 		}
+		langIndexes, err := filepath.Glob(filepath.Join(artifactsDir, "search.*.json"))
+		if err != nil {
+			return err
+		}
+		for _, p := range langIndexes {
+			if err := copyFile(p, filepath.Join(distDir, filepath.Base(p))); err != nil {
+				return err
+			}
+		}
 	} else if generateSearch {
 		if err := writeSearchIndex(distDir, idx, rulesCfg); err != nil {
 			return err
@@ -317,8 +315,8 @@ func writeMinimalSitemap(distDir, baseURL string, idx models.ResolveIndex, cfg r
 		}
 		loc := buildAbsoluteURL(baseURL, p)
 		lastmod := ""
-		if rt.LastModified != "" {
-			if t, err := time.Parse(time.RFC3339, rt.LastModified); err == nil {
+		if updated := firstNonEmpty(meta.Updated, rt.LastModified); updated != "" {
+			if t, err := time.Parse(time.RFC3339, updated); err == nil {
 				lastmod = t.UTC().Format("2006-01-02")
 			}
 		}
@@ -360,16 +358,38 @@ func writeSearchIndex(distDir string, idx models.ResolveIndex, rulesCfg rules.Ru
 	return writeFile(filepath.Join(distDir, "search.json"), b)
 }
 
-func renderMarkdownForBuild(markdown, baseKey, prefix, mediaBase, baseURL string, wikiMap map[string]string, mdRenderer markdownRenderer, htmlPolicy string) (string, error) {
+// buildRoutePageData is the page data build renders the note at pathVal with.
+func buildRoutePageData(cfg config.Config, rulesCfg rules.Rules, idx models.ResolveIndex, pathVal, body string, md markdownRenderer) PageData {
+	meta := normalizeMetaMediaURLs(idx.Meta[pathVal], cfg.Site.MediaBaseURL, cfg.Site.BaseURL)
+	data := buildPageData(meta, body, cfg)
+	data.Template = firstNonEmpty(meta.Template, templateForType(meta.Type, rulesCfg))
+	data.Layout = meta.Layout
+	data.Page.NoIndex = idx.Routes[pathVal].NoIndex
+	data.SearchMode = "static"
+	if pathVal == "/" || isLanguageHome(cfg, pathVal) {
+		data.IsHome = true
+	}
+	data.Collections = buildCollections(idx, rulesCfg, pathVal)
+	data.Translations = buildTranslations(idx, pathVal, cfg)
+	data.funcs = newTemplateContext(cfg, rulesCfg, idx, pathVal, md)
+	return data
+}
+
+func renderMarkdownForBuild(markdown, baseKey, prefix, mediaBase, baseURL string, wikiMap map[string]string, mdRenderer markdownRenderer, htmlPolicy string, shortcodes *shortcodeRenderer) (string, error) {
 	markdown = normalizeMarkdownImagesForBuild(markdown, baseKey, prefix, mediaBase)
+	markdown = shortcodes.extract(markdown)
 	markdown = normalizeMarkdownLinks(markdown, wikiMap, baseURL)
+	markdown, err := shortcodes.expand(markdown)
+	if err != nil {
+		return "", err
+	}
 	var buf strings.Builder
 	if err := mdRenderer.Convert([]byte(markdown), &buf); err != nil {
 		return "", err
 	}
 	body := postprocessRenderedHTML(buf.String())
 	body, _ = applyHTMLPolicy(body, htmlPolicy)
-	return body, nil
+	return shortcodes.fill(body), nil
 }
 
 func normalizeMetaMediaURLs(meta models.MetaEntry, mediaBase, baseURL string) models.MetaEntry {
@@ -464,6 +484,15 @@ func sortedRoutes(routes map[string]models.RouteEntry) []string {
 	return out
 }
 
+func sortedRedirects(table map[string]models.Redirect) []string {
+	out := make([]string, 0, len(table))
+	for p := range table {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
 func templateForType(typeName string, cfg rules.Rules) string {
 	if typeName == "" {
 		return ""
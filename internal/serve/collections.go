@@ -5,6 +5,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cookiespooky/notepub/internal/models"
 	"github.com/cookiespooky/notepub/internal/rules"
@@ -20,63 +21,71 @@ func buildCollections(idx models.ResolveIndex, cfg rules.Rules, currentPath stri
 
 	out := map[string]models.CollectionResult{}
 	for name, rule := range cfg.Collections {
-		items := []models.CollectionItem{}
-		switch rule.Kind {
-		case "filter":
-			for pathVal := range idx.Meta {
-				items = append(items, buildCollectionItem(idx, pathVal))
-			}
-		case "forward":
-			if idx.Links == nil {
-				break
-			}
-			fromSlug := resolveTemplate(rule.FromSlug, currentMeta.Slug)
-			if fromSlug == "" {
-				fromSlug = currentMeta.Slug
-			}
-			fromPath := slugIndex[fromSlug]
-			if fromPath == "" {
-				break
-			}
-			for _, target := range idx.Links[fromPath][rule.Link] {
-				items = append(items, buildCollectionItem(idx, target))
-			}
-		case "backrefs":
-			if idx.Links == nil {
-				break
-			}
-			toSlug := resolveTemplate(rule.ToSlug, currentMeta.Slug)
-			if toSlug == "" {
-				toSlug = currentMeta.Slug
-			}
-			toPath := slugIndex[toSlug]
-			if toPath == "" {
-				break
-			}
-			for _, source := range backrefs[rule.Link][toPath] {
-				items = append(items, buildCollectionItem(idx, source))
-			}
-		default:
-			continue
+		if result, ok := buildCollection(idx, cfg, rule, currentMeta, slugIndex, backrefs); ok {
+			out[name] = result
 		}
+	}
+	return out
+}
 
-		items = filterItems(items, rule.Where, cfg.FMSchema)
-		if rule.Sort.By != "" {
-			sortItems(items, rule.Sort, cfg.FMSchema)
+// buildCollection evaluates one collection rule for the current page. It
+// reports false for unknown kinds.
+func buildCollection(idx models.ResolveIndex, cfg rules.Rules, rule rules.CollectionRule, currentMeta models.MetaEntry, slugIndex map[string]string, backrefs map[string]map[string][]string) (models.CollectionResult, bool) {
+	items := []models.CollectionItem{}
+	switch rule.Kind {
+	case "filter":
+		for pathVal := range idx.Meta {
+			items = append(items, buildCollectionItem(idx, pathVal))
 		}
-		if rule.Limit > 0 && len(items) > rule.Limit {
-			items = items[:rule.Limit]
+	case "forward":
+		if idx.Links == nil {
+			break
 		}
-
-		result := models.CollectionResult{}
-		if rule.GroupBy.By != "" {
-			result.Groups = groupItems(items, rule.GroupBy, cfg.FMSchema)
-		} else {
-			result.Items = items
+		fromSlug := resolveTemplate(rule.FromSlug, currentMeta.Slug)
+		if fromSlug == "" {
+			fromSlug = currentMeta.Slug
+		}
+		fromPath := slugIndex[fromSlug]
+		if fromPath == "" {
+			break
+		}
+		for _, target := range idx.Links[fromPath][rule.Link] {
+			items = append(items, buildCollectionItem(idx, target))
 		}
-		out[name] = result
+	case "backrefs":
+		if idx.Links == nil {
+			break
+		}
+		toSlug := resolveTemplate(rule.ToSlug, currentMeta.Slug)
+		if toSlug == "" {
+			toSlug = currentMeta.Slug
+		}
+		toPath := slugIndex[toSlug]
+		if toPath == "" {
+			break
+		}
+		for _, source := range backrefs[rule.Link][toPath] {
+			items = append(items, buildCollectionItem(idx, source))
+		}
+	default:
+		return models.CollectionResult{}, false
 	}
-	return out
+
+	items = filterItems(items, rule.Where, cfg.FMSchema)
+	if rule.Sort.By != "" {
+		sortItems(items, rule.Sort, cfg.FMSchema)
+	}
+	if rule.Limit > 0 && len(items) > rule.Limit {
+		items = items[:rule.Limit]
+	}
+
+	result := models.CollectionResult{}
+	if rule.GroupBy.By != "" {
+		result.Groups = groupItems(items, rule.GroupBy, cfg.FMSchema)
+	} else {
+		result.Items = items
+	}
+	return result, true
 }
 
 func buildCollectionItem(idx models.ResolveIndex, pathVal string) models.CollectionItem {
@@ -90,7 +99,8 @@ func buildCollectionItem(idx models.ResolveIndex, pathVal string) models.Collect
 		Description: meta.Description,
 		Canonical:   meta.Canonical,
 		Image:       meta.Image,
-		UpdatedAt:   route.LastModified,
+		CreatedAt:   meta.Date,
+		UpdatedAt:   firstNonEmpty(meta.Updated, route.LastModified),
 		NoIndex:     route.NoIndex,
 		FM:          meta.FM,
 	}
@@ -238,11 +248,16 @@ func sortValue(item models.CollectionItem, by string, schema map[string]string)
 			return "", true
 		}
 		return strings.ToLower(item.Slug), false
-	case "updated_at", "created_at":
+	case "updated_at":
 		if item.UpdatedAt == "" {
 			return "", true
 		}
-		return item.UpdatedAt, false
+		return sortableTime(item.UpdatedAt), false
+	case "created_at":
+		if item.CreatedAt == "" {
+			return "", true
+		}
+		return sortableTime(item.CreatedAt), false
 	default:
 		if strings.HasPrefix(by, "fm.") {
 			key := strings.TrimPrefix(by, "fm.")
@@ -375,3 +390,12 @@ func toBool(val interface{}) bool {
 		return fmt.Sprint(val) == "true"
 	}
 }
+
+// sortableTime normalizes RFC 3339 timestamps to UTC so mixed offsets sort by instant.
+func sortableTime(val string) string {
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return val
+	}
+	return t.UTC().Format(time.RFC3339)
+}
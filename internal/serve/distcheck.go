@@ -0,0 +1,218 @@
+package serve
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	htmlnode "golang.org/x/net/html"
+
+	"github.com/cookiespooky/notepub/internal/indexer"
+)
+
+// distRef is one href/src/srcset reference found in a generated page.
+type distRef struct {
+	Attr string
+	URL  string
+	Line int
+}
+
+type distPage struct {
+	refs []distRef
+	ids  map[string]struct{}
+}
+
+// CheckDist parses every HTML file under distDir and checks href, src and
+// srcset references against the files in distDir, with #fragments checked
+// against element ids. The site is assumed to be deployed at baseURL, so
+// root-absolute paths outside its path are reported. Diagnostics use the
+// page's path inside distDir as File.
+func CheckDist(distDir, baseURL string) ([]indexer.MarkdownDiagnostic, error) {
+	base, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("parse base_url: %w", err)
+	}
+	basePath := "/" + strings.Trim(base.Path, "/")
+	if basePath != "/" {
+		basePath += "/"
+	}
+
+	pages := map[string]*distPage{}
+	err = filepath.WalkDir(distDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isHTMLFile(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(distDir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		pages[filepath.ToSlash(rel)] = scanDistPage(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(pages))
+	for name := range pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]indexer.MarkdownDiagnostic, 0)
+	for _, name := range names {
+		pageURL := distPageURL(basePath, name)
+		for _, ref := range pages[name].refs {
+			code, msg := checkDistRef(distDir, base, basePath, pageURL, name, ref.URL, pages)
+			if code == "" {
+				continue
+			}
+			out = append(out, indexer.MarkdownDiagnostic{
+				Code:     code,
+				Severity: "error",
+				File:     name,
+				Line:     ref.Line,
+				Message:  fmt.Sprintf("%s %q: %s", ref.Attr, ref.URL, msg),
+			})
+		}
+	}
+	return out, nil
+}
+
+func isHTMLFile(p string) bool {
+	ext := strings.ToLower(filepath.Ext(p))
+	return ext == ".html" || ext == ".htm"
+}
+
+// scanDistPage collects references and element ids with source line numbers.
+func scanDistPage(data []byte) *distPage {
+	page := &distPage{ids: map[string]struct{}{}}
+	z := htmlnode.NewTokenizer(bytes.NewReader(data))
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == htmlnode.ErrorToken {
+			return page
+		}
+		raw := z.Raw()
+		tokLine := line
+		line += bytes.Count(raw, []byte("\n"))
+		if tt != htmlnode.StartTagToken && tt != htmlnode.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		for _, attr := range tok.Attr {
+			switch attr.Key {
+			case "id":
+				page.ids[attr.Val] = struct{}{}
+			case "name":
+				if tok.Data == "a" {
+					page.ids[attr.Val] = struct{}{}
+				}
+			case "href", "src":
+				if tok.Data == "base" {
+					continue
+				}
+				page.refs = append(page.refs, distRef{Attr: attr.Key, URL: strings.TrimSpace(attr.Val), Line: tokLine})
+			case "srcset":
+				for _, candidate := range strings.Split(attr.Val, ",") {
+					fields := strings.Fields(candidate)
+					if len(fields) > 0 {
+						page.refs = append(page.refs, distRef{Attr: "srcset", URL: fields[0], Line: tokLine})
+					}
+				}
+			}
+		}
+	}
+}
+
+// distPageURL is the URL path a dist file is served at.
+func distPageURL(basePath, rel string) string {
+	if rel == "index.html" {
+		return basePath
+	}
+	if strings.HasSuffix(rel, "/index.html") {
+		return basePath + strings.TrimSuffix(rel, "index.html")
+	}
+	return basePath + rel
+}
+
+func checkDistRef(distDir string, base *url.URL, basePath, pageURL, pageName, raw string, pages map[string]*distPage) (string, string) {
+	if raw == "" {
+		return "", ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "NP-DIST-BAD-URL", err.Error()
+	}
+	if u.Scheme != "" || u.Host != "" {
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return "", ""
+		}
+		if !strings.EqualFold(u.Host, base.Host) {
+			return "", ""
+		}
+	}
+	target := pageName
+	if u.Path != "" || u.Host != "" {
+		abs := u.Path
+		if !strings.HasPrefix(abs, "/") {
+			abs = path.Join(path.Dir(pageURL+"x"), abs)
+			if strings.HasSuffix(u.Path, "/") {
+				abs += "/"
+			}
+		}
+		if abs+"/" != basePath && !strings.HasPrefix(abs, basePath) {
+			return "NP-DIST-OUTSIDE-BASE", fmt.Sprintf("path is outside base_url path %s", basePath)
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(abs, strings.TrimSuffix(basePath, "/")), "/")
+		file, ok := distFileFor(distDir, rel)
+		if !ok {
+			return "NP-DIST-MISSING", "no such file in dist"
+		}
+		target = file
+	}
+	if u.Fragment == "" {
+		return "", ""
+	}
+	page, ok := pages[target]
+	if !ok {
+		return "", ""
+	}
+	if _, ok := page.ids[u.Fragment]; !ok {
+		return "NP-DIST-MISSING-ANCHOR", fmt.Sprintf("no element with id %q in %s", u.Fragment, target)
+	}
+	return "", ""
+}
+
+// distFileFor maps a URL path inside the site to a dist file, the way static
+// hosts do: directories serve index.html and extensionless paths may be .html.
+func distFileFor(distDir, rel string) (string, bool) {
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	candidates := []string{}
+	if rel == "" {
+		candidates = append(candidates, "index.html")
+	} else {
+		candidates = append(candidates, rel, rel+"/index.html", rel+".html")
+	}
+	for _, c := range candidates {
+		info, err := os.Stat(filepath.Join(distDir, filepath.FromSlash(c)))
+		if err == nil && !info.IsDir() {
+			return c, true
+		}
+	}
+	return "", false
+}
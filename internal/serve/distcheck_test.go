@@ -0,0 +1,61 @@
+package serve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeDistFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	full := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", rel, err)
+	}
+}
+
+func TestCheckDistUnderSubpath(t *testing.T) {
+	dist := t.TempDir()
+	writeDistFile(t, dist, "index.html", `<html><body>
+<a href="/docs/guide/">guide</a>
+<a href="guide/#intro">intro</a>
+<a href="/guide/">root-absolute</a>
+<a href="https://example.com/docs/missing/">missing</a>
+<a href="https://other.example.org/x">external</a>
+<a href="mailto:a@example.com">mail</a>
+<img src="assets/logo.png" srcset="assets/logo.png 1x, assets/logo@2x.png 2x">
+</body></html>`)
+	writeDistFile(t, dist, "guide/index.html", `<html><body>
+<h2 id="intro">Intro</h2>
+<a href="../index.html#top">top</a>
+<a href="#intro">self</a>
+</body></html>`)
+	writeDistFile(t, dist, "assets/logo.png", "png")
+
+	diags, err := CheckDist(dist, "https://example.com/docs/")
+	if err != nil {
+		t.Fatalf("CheckDist: %v", err)
+	}
+	type key struct {
+		file string
+		line int
+		code string
+	}
+	want := map[key]bool{
+		{"index.html", 4, "NP-DIST-OUTSIDE-BASE"}:         true,
+		{"index.html", 5, "NP-DIST-MISSING"}:              true,
+		{"index.html", 8, "NP-DIST-MISSING"}:              true,
+		{"guide/index.html", 3, "NP-DIST-MISSING-ANCHOR"}: true,
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %+v", len(diags), len(want), diags)
+	}
+	for _, d := range diags {
+		if !want[key{d.File, d.Line, d.Code}] {
+			t.Fatalf("unexpected diagnostic %+v", d)
+		}
+	}
+}
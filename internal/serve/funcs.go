@@ -0,0 +1,594 @@
+package serve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+	"github.com/cookiespooky/notepub/internal/urlutil"
+)
+
+// templateContext binds the resolve index and the page being rendered to the
+// theme's template functions. Themes are parsed once; each render borrows a
+// clone of the templates whose functions read its context.
+type templateContext struct {
+	idx        models.ResolveIndex
+	rules      rules.Rules
+	path       string
+	loc        *time.Location
+	htmlPolicy string
+	md         markdownRenderer
+
+	// Filled from PageData and the theme at render time.
+	theme      *Theme
+	baseURL    string
+	assetsBase string
+	lang       string
+	settings   map[string]string
+
+	collections map[string]models.CollectionResult
+	slugIndex   map[string]string
+	backrefs    map[string]map[string][]string
+}
+
+func newTemplateContext(cfg config.Config, rulesCfg rules.Rules, idx models.ResolveIndex, pathVal string, md markdownRenderer) *templateContext {
+	return &templateContext{
+		idx:        idx,
+		rules:      rulesCfg,
+		path:       pathVal,
+		loc:        cfg.Location(),
+		htmlPolicy: cfg.Markdown.HTMLPolicy,
+		md:         md,
+	}
+}
+
+// parseFuncs is the function set themes are parsed with; it has the same
+// names as a bound context so templates referencing them parse.
+var parseFuncs = (&templateContext{}).funcMap()
+
+func (c *templateContext) funcMap() template.FuncMap {
+	return template.FuncMap{
+		"dateFormat":  c.dateFormat,
+		"absURL":      c.absURL,
+		"relURL":      c.relURL,
+		"markdownify": c.markdownify,
+		"truncate":    truncateText,
+		"pageByPath":  c.pageByPath,
+		"collection":  c.collection,
+		"where":       c.where,
+		"sortBy":      c.sortBy,
+		"first":       first,
+		"readingTime": readingTime,
+		"asset":       c.asset,
+		"settings":    c.setting,
+		"T":           c.translate,
+	}
+}
+
+// boundTemplates is a clone of the theme's templates with functions bound to
+// ctx. Clones are pooled: html/template escapes a clone on its first execution,
+// so reusing them keeps that cost out of every render. Each render copies its
+// context into ctx and has the clone to itself until it is released.
+type boundTemplates struct {
+	tmpl *template.Template
+	ctx  *templateContext
+	// src is the render's own context; lazily built caches are copied back.
+	src *templateContext
+}
+
+// acquireTemplates returns pooled templates with functions bound to data's
+// context; release them with releaseTemplates.
+func (t *Theme) acquireTemplates(data PageData) (*boundTemplates, error) {
+	b, _ := t.bound.Get().(*boundTemplates)
+	if b == nil {
+		clone, err := t.page.Clone()
+		if err != nil {
+			return nil, err
+		}
+		b = &boundTemplates{ctx: &templateContext{}}
+		b.tmpl = clone.Funcs(b.ctx.funcMap())
+	}
+	ctx := data.funcs
+	if ctx == nil {
+		ctx = &templateContext{}
+	}
+	ctx.theme = t
+	ctx.baseURL = data.BaseURL
+	ctx.assetsBase = data.AssetsBase
+	ctx.lang = data.Lang
+	ctx.settings = data.Settings
+	*b.ctx = *ctx
+	b.src = ctx
+	return b, nil
+}
+
+func (t *Theme) releaseTemplates(b *boundTemplates) {
+	if b == nil {
+		return
+	}
+	*b.src = *b.ctx
+	*b.ctx = templateContext{}
+	b.src = nil
+	t.bound.Put(b)
+}
+
+// templateDateLayouts are tried in order when dateFormat gets a string.
+var templateDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// dateFormat formats value (a time or a date string) with a Go layout in
+// site.timezone. Month and weekday names follow locale, or the page language.
+func (c *templateContext) dateFormat(layout string, value interface{}, locale ...string) (string, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		t = *v
+	case string:
+		raw := strings.TrimSpace(v)
+		if raw == "" {
+			return "", nil
+		}
+		parsed := false
+		for _, l := range templateDateLayouts {
+			if p, err := time.ParseInLocation(l, raw, c.location()); err == nil {
+				t, parsed = p, true
+				break
+			}
+		}
+		if !parsed {
+			return "", fmt.Errorf("dateFormat: cannot parse date %q", raw)
+		}
+	default:
+		return "", fmt.Errorf("dateFormat: unsupported value of type %T", value)
+	}
+	lang := c.lang
+	if len(locale) > 0 && locale[0] != "" {
+		lang = locale[0]
+	}
+	return formatLocalized(t.In(c.location()), layout, lang), nil
+}
+
+func (c *templateContext) location() *time.Location {
+	if c.loc == nil {
+		return time.UTC
+	}
+	return c.loc
+}
+
+// absURL joins a site path with base_url, keeping its subpath. External
+// URLs are returned unchanged.
+func (c *templateContext) absURL(p string) string {
+	if isExternal(p) || strings.HasPrefix(p, "mailto:") || strings.HasPrefix(p, "#") {
+		return p
+	}
+	return urlutil.JoinBaseURL(c.baseURL, p)
+}
+
+// relURL is absURL without scheme and host: "/repo/notes/" for a base_url of
+// https://user.github.io/repo.
+func (c *templateContext) relURL(p string) string {
+	abs := c.absURL(p)
+	if abs == p && (isExternal(p) || strings.HasPrefix(p, "mailto:") || strings.HasPrefix(p, "#")) {
+		return p
+	}
+	u, err := url.Parse(abs)
+	if err != nil || u.Host == "" {
+		return abs
+	}
+	u.Scheme, u.Host, u.User = "", "", nil
+	return u.String()
+}
+
+// markdownify renders a Markdown string; a single paragraph is unwrapped so
+// the result can be used inline.
+func (c *templateContext) markdownify(value interface{}) (template.HTML, error) {
+	src := toText(value)
+	if strings.TrimSpace(src) == "" {
+		return "", nil
+	}
+	md := c.md
+	if md == nil {
+		md = newMarkdownRenderer()
+	}
+	var buf strings.Builder
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return "", fmt.Errorf("markdownify: %w", err)
+	}
+	body := strings.TrimSpace(postprocessRenderedHTML(buf.String()))
+	body, _ = applyHTMLPolicy(body, c.htmlPolicy)
+	return template.HTML(unwrapParagraph(body)), nil
+}
+
+// truncateText shortens text to at most n characters, cutting at a word
+// boundary and appending an ellipsis: truncate 120 .Page.Description or
+// truncate 120 "..." .Page.Description.
+func truncateText(n int, args ...interface{}) (string, error) {
+	ellipsis := "…"
+	var value interface{}
+	switch len(args) {
+	case 1:
+		value = args[0]
+	case 2:
+		ellipsis = toText(args[0])
+		value = args[1]
+	default:
+		return "", fmt.Errorf("truncate: want length, optional ellipsis and text")
+	}
+	text := strings.TrimSpace(toText(value))
+	if n < 0 || utf8.RuneCountInString(text) <= n {
+		return text, nil
+	}
+	runes := []rune(text)
+	cut := string(runes[:n])
+	if !unicode.IsSpace(runes[n]) {
+		if i := strings.LastIndexAny(cut, " \t\n"); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " \t\n,.;:") + ellipsis, nil
+}
+
+// pageByPath returns the published page at a route path, or nil.
+func (c *templateContext) pageByPath(p string) *models.CollectionItem {
+	for _, candidate := range routeCandidates(p) {
+		route, ok := c.idx.Routes[candidate]
+		if !ok || route.Status != 200 {
+			continue
+		}
+		if _, ok := c.idx.Meta[candidate]; !ok {
+			continue
+		}
+		item := buildCollectionItem(c.idx, candidate)
+		return &item
+	}
+	return nil
+}
+
+// collection evaluates a rules collection for the current page on first use.
+func (c *templateContext) collection(name string) (models.CollectionResult, error) {
+	if result, ok := c.collections[name]; ok {
+		return result, nil
+	}
+	rule, ok := c.rules.Collections[name]
+	if !ok {
+		return models.CollectionResult{}, fmt.Errorf("collection: unknown collection %q", name)
+	}
+	if c.slugIndex == nil {
+		c.slugIndex = buildSlugIndex(c.idx)
+		c.backrefs = buildBackrefs(c.idx)
+	}
+	result, _ := buildCollection(c.idx, c.rules, rule, c.idx.Meta[c.path], c.slugIndex, c.backrefs)
+	if c.collections == nil {
+		c.collections = map[string]models.CollectionResult{}
+	}
+	c.collections[name] = result
+	return result, nil
+}
+
+// where filters items by field (title, slug, type, path, created_at,
+// updated_at, noindex or fm.<name>): where items "type" "post",
+// where items "fm.tags" "contains" "go". Operators are =, !=, in, not in
+// and contains.
+func (c *templateContext) where(list interface{}, field string, args ...interface{}) ([]models.CollectionItem, error) {
+	items, err := toCollectionItems(list)
+	if err != nil {
+		return nil, fmt.Errorf("where: %w", err)
+	}
+	op, want := "=", interface{}(nil)
+	switch len(args) {
+	case 1:
+		want = args[0]
+	case 2:
+		op, want = strings.ToLower(toText(args[0])), args[1]
+	default:
+		return nil, fmt.Errorf("where: want field, optional operator and value")
+	}
+	out := []models.CollectionItem{}
+	for _, item := range items {
+		got, _ := itemField(item, field)
+		var match bool
+		switch op {
+		case "=", "==", "eq":
+			match = sameValue(got, want)
+		case "!=", "ne":
+			match = !sameValue(got, want)
+		case "in":
+			match = valueInList(toText(got), want)
+		case "not in":
+			match = !valueInList(toText(got), want)
+		case "contains":
+			match = valueInList(toText(want), got)
+		default:
+			return nil, fmt.Errorf("where: unknown operator %q", op)
+		}
+		if match {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// sortBy returns items sorted like a collection sort: sortBy items "title" or
+// sortBy items "fm.price" "desc".
+func (c *templateContext) sortBy(list interface{}, field string, dir ...string) ([]models.CollectionItem, error) {
+	items, err := toCollectionItems(list)
+	if err != nil {
+		return nil, fmt.Errorf("sortBy: %w", err)
+	}
+	rule := rules.SortRule{By: field, Dir: "asc"}
+	if len(dir) > 0 {
+		rule.Dir = dir[0]
+	}
+	out := append([]models.CollectionItem(nil), items...)
+	sortItems(out, rule, c.rules.FMSchema)
+	return out, nil
+}
+
+// first returns the first n elements of any list.
+func first(n int, list interface{}) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("first: negative length %d", n)
+	}
+	if result, ok := list.(models.CollectionResult); ok {
+		list = result.Items
+	}
+	v := reflect.ValueOf(list)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if n > v.Len() {
+			n = v.Len()
+		}
+		return v.Slice(0, n).Interface(), nil
+	case reflect.String:
+		runes := []rune(v.String())
+		if n > len(runes) {
+			n = len(runes)
+		}
+		return string(runes[:n]), nil
+	}
+	return nil, fmt.Errorf("first: cannot slice %T", list)
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// readingTime estimates minutes to read text or rendered HTML at 200 words
+// per minute; any non-empty text takes at least one minute.
+func readingTime(value interface{}) int {
+	text := toText(value)
+	if _, ok := value.(template.HTML); ok {
+		text = htmlTagPattern.ReplaceAllString(text, " ")
+	}
+	words := len(strings.Fields(text))
+	if words == 0 {
+		return 0
+	}
+	return (words + 199) / 200
+}
+
+// asset returns the URL of a theme asset with a content hash, so browsers
+// and CDNs refetch it when the file changes.
+func (c *templateContext) asset(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if !isSafeAsset(name) {
+		return "", fmt.Errorf("asset: invalid name %q", name)
+	}
+	base := strings.TrimRight(c.assetsBase, "/")
+	if c.theme == nil {
+		return base + "/" + name, nil
+	}
+	hash, err := c.theme.assetHash(name)
+	if err != nil {
+		return "", fmt.Errorf("asset: %w", err)
+	}
+	return base + "/" + name + "?v=" + hash, nil
+}
+
+func (c *templateContext) setting(key string) string {
+	return c.settings[key]
+}
+
+// translate returns the interface string for key, falling back to the key
+// itself; extra arguments fill fmt verbs in the string.
+func (c *templateContext) translate(key string, args ...interface{}) string {
+	text := c.settings[key]
+	if text == "" {
+		text = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// assetHash returns a short content hash of a theme asset, cached per theme.
+func (t *Theme) assetHash(name string) (string, error) {
+	if cached, ok := t.assetHashes.Load(name); ok {
+		return cached.(string), nil
+	}
+	data, err := fs.ReadFile(t.AssetFS(), name)
+	if err != nil {
+		return "", fmt.Errorf("theme asset %q not found", name)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:10]
+	t.assetHashes.Store(name, hash)
+	return hash, nil
+}
+
+func toCollectionItems(list interface{}) ([]models.CollectionItem, error) {
+	switch v := list.(type) {
+	case nil:
+		return nil, nil
+	case []models.CollectionItem:
+		return v, nil
+	case models.CollectionResult:
+		if len(v.Groups) == 0 {
+			return v.Items, nil
+		}
+		out := []models.CollectionItem{}
+		for _, g := range v.Groups {
+			out = append(out, g.Items...)
+		}
+		return out, nil
+	case *models.CollectionItem:
+		if v == nil {
+			return nil, nil
+		}
+		return []models.CollectionItem{*v}, nil
+	}
+	return nil, fmt.Errorf("expected collection items, got %T", list)
+}
+
+func itemField(item models.CollectionItem, field string) (interface{}, bool) {
+	switch field {
+	case "path":
+		return item.Path, true
+	case "type":
+		return item.Type, true
+	case "slug":
+		return item.Slug, true
+	case "title":
+		return item.Title, true
+	case "description":
+		return item.Description, true
+	case "canonical":
+		return item.Canonical, true
+	case "image":
+		return item.Image, true
+	case "created_at":
+		return item.CreatedAt, true
+	case "updated_at":
+		return item.UpdatedAt, true
+	case "noindex":
+		return item.NoIndex, true
+	}
+	if key, ok := strings.CutPrefix(field, "fm."); ok {
+		val, ok := item.FM[key]
+		return val, ok
+	}
+	return nil, false
+}
+
+func sameValue(a, b interface{}) bool {
+	return strings.TrimSpace(toText(a)) == strings.TrimSpace(toText(b))
+}
+
+func toText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case template.HTML:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// dateLocale holds month and weekday names for dateFormat. Genitive month
+// names are used when the layout also prints the day ("2 января").
+type dateLocale struct {
+	months         [12]string
+	monthsGenitive [12]string
+	days           [7]string
+}
+
+var dateLocales = map[string]dateLocale{
+	"ru": {
+		months:         [12]string{"январь", "февраль", "март", "апрель", "май", "июнь", "июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"},
+		monthsGenitive: [12]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		days:           [7]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"},
+	},
+	"de": {
+		months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		days:   [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+	"fr": {
+		months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		days:   [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	},
+	"es": {
+		months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		days:   [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	},
+}
+
+var dayLayoutPattern = regexp.MustCompile(`(^|[^0-9])(_2|02|2)([^0-9]|$)`)
+
+// formatLocalized is time.Format with month and weekday names in lang.
+// Unknown languages, including English, use Go's names.
+func formatLocalized(t time.Time, layout, lang string) string {
+	base, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	base, _, _ = strings.Cut(base, "_")
+	loc, ok := dateLocales[base]
+	if !ok {
+		return t.Format(layout)
+	}
+	months := loc.months
+	if loc.monthsGenitive[0] != "" && dayLayoutPattern.MatchString(strings.NewReplacer("2006", "").Replace(layout)) {
+		months = loc.monthsGenitive
+	}
+	shorten := func(s string) string {
+		r := []rune(s)
+		if len(r) > 3 {
+			return string(r[:3])
+		}
+		return s
+	}
+	tokens := []struct {
+		name  string
+		value string
+	}{
+		{"January", months[t.Month()-1]},
+		{"Monday", loc.days[t.Weekday()]},
+		{"Jan", shorten(months[t.Month()-1])},
+		{"Mon", shorten(loc.days[t.Weekday()])},
+	}
+	var b strings.Builder
+	segment := 0
+	for i := 0; i < len(layout); {
+		matched := false
+		for _, tok := range tokens {
+			if strings.HasPrefix(layout[i:], tok.name) {
+				b.WriteString(t.Format(layout[segment:i]))
+				b.WriteString(tok.value)
+				i += len(tok.name)
+				segment = i
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i++
+		}
+	}
+	b.WriteString(t.Format(layout[segment:]))
+	return b.String()
+}
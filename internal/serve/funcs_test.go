@@ -0,0 +1,177 @@
+package serve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestFormatLocalized(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		layout, lang, want string
+	}{
+		{"2 January 2006", "en", "5 March 2024"},
+		{"2 January 2006", "ru", "5 марта 2024"},
+		{"January 2006", "ru-RU", "март 2024"},
+		{"Mon, 2 Jan", "de", "Die, 5 Mär"},
+		{"Monday 02.01.2006", "fr", "mardi 05.03.2024"},
+	}
+	for _, tc := range cases {
+		if got := formatLocalized(day, tc.layout, tc.lang); got != tc.want {
+			t.Fatalf("formatLocalized(%q, %q) = %q, want %q", tc.layout, tc.lang, got, tc.want)
+		}
+	}
+}
+
+func TestTemplateURLFuncs(t *testing.T) {
+	c := &templateContext{baseURL: "https://user.github.io/repo"}
+	if got := c.absURL("/notes/a/"); got != "https://user.github.io/repo/notes/a/" {
+		t.Fatalf("absURL = %q", got)
+	}
+	if got := c.relURL("/notes/a/"); got != "/repo/notes/a/" {
+		t.Fatalf("relURL = %q", got)
+	}
+	if got := c.relURL("https://example.com/x"); got != "https://example.com/x" {
+		t.Fatalf("relURL external = %q", got)
+	}
+}
+
+func TestTemplateListFuncs(t *testing.T) {
+	items := []models.CollectionItem{
+		{Path: "/b/", Title: "Beta", Type: "post", FM: map[string]interface{}{"price": 20, "tags": []interface{}{"go"}}},
+		{Path: "/a/", Title: "alpha", Type: "post", FM: map[string]interface{}{"price": 3}},
+		{Path: "/c/", Title: "Gamma", Type: "page"},
+	}
+	c := &templateContext{rules: rules.Rules{FMSchema: map[string]string{"price": "number"}}}
+
+	posts, err := c.where(items, "type", "post")
+	if err != nil || len(posts) != 2 {
+		t.Fatalf("where = %v, %v", posts, err)
+	}
+	tagged, err := c.where(items, "fm.tags", "contains", "go")
+	if err != nil || len(tagged) != 1 || tagged[0].Path != "/b/" {
+		t.Fatalf("where contains = %v, %v", tagged, err)
+	}
+	if _, err := c.where(items, "type", "~", "post"); err == nil {
+		t.Fatalf("expected unknown operator error")
+	}
+	sorted, err := c.sortBy(posts, "fm.price")
+	if err != nil || sorted[0].Path != "/a/" || posts[0].Path != "/b/" {
+		t.Fatalf("sortBy = %v, %v (input must be unchanged)", sorted, err)
+	}
+	top, err := first(1, sorted)
+	if err != nil || len(top.([]models.CollectionItem)) != 1 {
+		t.Fatalf("first = %v, %v", top, err)
+	}
+
+	if got, _ := truncateText(12, "Gardens grow slowly here"); got != "Gardens grow…" {
+		t.Fatalf("truncate = %q", got)
+	}
+	if got, _ := truncateText(10, "...", "Gardens grow slowly"); got != "Gardens..." {
+		t.Fatalf("truncate mid-word = %q", got)
+	}
+	if got := readingTime(strings.Repeat("word ", 450)); got != 3 {
+		t.Fatalf("readingTime = %d", got)
+	}
+}
+
+func TestRenderPageTemplateFuncs(t *testing.T) {
+	themeDir := t.TempDir()
+	writeThemeFile(t, themeDir, "assets/styles.css", "body{}")
+	writeThemeFile(t, themeDir, "templates/layout.html", `<link href="{{ asset "styles.css" }}">{{ .Body }}`)
+	writeThemeFile(t, themeDir, "templates/page.html", `{{ with pageByPath "/about" }}[{{ .Title }}]{{ end }}`+
+		`{{ range (collection "posts").Items }}({{ .Title }}){{ end }}`+
+		`{{ dateFormat "2 January 2006" .FM.date }}|{{ T "read_more" }}|{{ T "count" 3 }}|{{ settings "site_name" }}|{{ markdownify "*hi*" }}`)
+	theme, err := LoadTheme(themeDir, "", "")
+	if err != nil {
+		t.Fatalf("LoadTheme: %v", err)
+	}
+
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/about/": {Status: 200},
+			"/p1/":    {Status: 200},
+			"/p2/":    {Status: 200},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/about/": {Title: "About", Type: "page"},
+			"/p1/":    {Title: "First", Type: "post"},
+			"/p2/":    {Title: "Second", Type: "post"},
+		},
+	}
+	rulesCfg := rules.Rules{Collections: map[string]rules.CollectionRule{
+		"posts": {Kind: "filter", Where: rules.WhereRule{All: []map[string]interface{}{{"type_in": []interface{}{"post"}}}}, Sort: rules.SortRule{By: "title"}},
+	}}
+	cfg := config.Config{Site: config.SiteConfig{BaseURL: "https://example.com/site"}}
+	data := buildPageData(models.MetaEntry{Lang: "ru", FM: map[string]interface{}{"date": "2024-03-05"}}, "", cfg)
+	data.Settings = map[string]string{"site_name": "Garden", "count": "%d notes"}
+	data.funcs = newTemplateContext(cfg, rulesCfg, idx, "/about/", nil)
+
+	out, err := theme.RenderPage(data)
+	if err != nil {
+		t.Fatalf("RenderPage: %v", err)
+	}
+	for _, want := range []string{
+		`href="https://example.com/site/assets/styles.css?v=`,
+		"[About]",
+		"(First)(Second)",
+		"5 марта 2024|read_more|3 notes|Garden|<em>hi</em>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	// Rendering twice must work: parsed templates are cloned, not executed.
+	if _, err := theme.RenderPage(data); err != nil {
+		t.Fatalf("second RenderPage: %v", err)
+	}
+}
+
+func TestRenderPageConcurrentContexts(t *testing.T) {
+	themeDir := t.TempDir()
+	writeThemeFile(t, themeDir, "templates/layout.html", `{{ .Body }}`)
+	writeThemeFile(t, themeDir, "templates/page.html", `{{ settings "name" }}|{{ absURL "/x" }}`)
+	theme, err := LoadTheme(themeDir, "", "")
+	if err != nil {
+		t.Fatalf("LoadTheme: %v", err)
+	}
+	errs := make(chan error, 40)
+	for i := 0; i < cap(errs); i++ {
+		go func(i int) {
+			name := fmt.Sprintf("site%d", i)
+			cfg := config.Config{Site: config.SiteConfig{BaseURL: "https://" + name + ".example"}}
+			data := buildPageData(models.MetaEntry{}, "", cfg)
+			data.Settings = map[string]string{"name": name}
+			data.funcs = newTemplateContext(cfg, rules.Rules{}, models.ResolveIndex{}, "/", nil)
+			out, err := theme.RenderPage(data)
+			if err == nil && out != name+"|https://"+name+".example/x" {
+				err = fmt.Errorf("render %d got %q", i, out)
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeThemeFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
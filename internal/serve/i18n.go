@@ -0,0 +1,127 @@
+package serve
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/urlutil"
+)
+
+type Translation struct {
+	Lang    string
+	Name    string
+	Path    string
+	URL     string
+	Title   string
+	Current bool
+}
+
+// buildTranslations lists every language version of a page (including the
+// page itself) linked by translation_key, ordered as in config languages.
+func buildTranslations(idx models.ResolveIndex, pathVal string, cfg config.Config) []Translation {
+	meta, ok := idx.Meta[pathVal]
+	if !ok || meta.TranslationKey == "" {
+		return nil
+	}
+	byLang := idx.Translations[meta.TranslationKey]
+	if len(byLang) < 2 {
+		return nil
+	}
+	order := map[string]int{}
+	for i, lang := range cfg.Languages {
+		order[lang.Code] = i
+	}
+	out := make([]Translation, 0, len(byLang))
+	for lang, p := range byLang {
+		route, ok := idx.Routes[p]
+		if !ok || route.Status != 200 {
+			continue
+		}
+		name := lang
+		if langCfg, ok := cfg.Language(lang); ok {
+			name = langCfg.Name
+		}
+		out = append(out, Translation{
+			Lang:    lang,
+			Name:    name,
+			Path:    p,
+			URL:     urlutil.JoinBaseURL(cfg.Site.BaseURL, p),
+			Title:   idx.Meta[p].Title,
+			Current: p == pathVal,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		oi, iok := order[out[i].Lang]
+		oj, jok := order[out[j].Lang]
+		if iok != jok {
+			return iok
+		}
+		if oi != oj {
+			return oi < oj
+		}
+		return out[i].Lang < out[j].Lang
+	})
+	return out
+}
+
+func isLanguageHome(cfg config.Config, pathVal string) bool {
+	for _, lang := range cfg.Languages {
+		if lang.Prefix != "" && strings.TrimSuffix(pathVal, "/") == lang.Prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// searchPathLang reports whether requestPath is a search page and for which
+// language ("" is the site-wide /search).
+func searchPathLang(cfg config.Config, requestPath string) (string, bool) {
+	if requestPath == "/search" {
+		return "", true
+	}
+	for _, lang := range cfg.Languages {
+		if lang.Prefix != "" && requestPath == lang.Prefix+"/search" {
+			return lang.Code, true
+		}
+	}
+	return "", false
+}
+
+// buildLangWikiMaps returns per-language wiki maps where notes of the same
+// language shadow same-named notes in other languages.
+func buildLangWikiMaps(idx models.ResolveIndex, global map[string]string) map[string]map[string]string {
+	langs := map[string]bool{}
+	for _, meta := range idx.Meta {
+		if meta.Lang != "" {
+			langs[meta.Lang] = true
+		}
+	}
+	if len(langs) == 0 {
+		return nil
+	}
+	out := make(map[string]map[string]string, len(langs))
+	for lang := range langs {
+		filtered := models.ResolveIndex{
+			Routes: map[string]models.RouteEntry{},
+			Meta:   map[string]models.MetaEntry{},
+			Mounts: idx.Mounts,
+		}
+		for p, meta := range idx.Meta {
+			if meta.Lang != "" && meta.Lang != lang {
+				continue
+			}
+			filtered.Meta[p] = meta
+			if route, ok := idx.Routes[p]; ok {
+				filtered.Routes[p] = route
+			}
+		}
+		merged := cloneWikiMap(global)
+		for k, v := range buildWikiMap(filtered) {
+			merged[k] = v
+		}
+		out[lang] = merged
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package serve
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MultiSite dispatches requests to per-site servers by Host header.
+type MultiSite struct {
+	byHost   map[string]*Server
+	fallback *Server
+}
+
+// NewMultiSite maps site.host and site.host_aliases of every server to it.
+// defaultID selects the site used for unknown hosts; empty means 404.
+func NewMultiSite(servers []*Server, defaultID string) (*MultiSite, error) {
+	m := &MultiSite{byHost: map[string]*Server{}}
+	ids := map[string]bool{}
+	for _, srv := range servers {
+		id := srv.cfg.Site.ID
+		if ids[id] {
+			return nil, fmt.Errorf("duplicate site.id %q", id)
+		}
+		ids[id] = true
+		// Dispatch already matched the host, including the default site.
+		srv.hostDispatched = true
+		if defaultID != "" && id == defaultID {
+			m.fallback = srv
+		}
+		hosts := append([]string{srv.cfg.Site.Host}, srv.cfg.Site.HostAliases...)
+		registered := 0
+		for _, host := range hosts {
+			host = strings.ToLower(strings.TrimSpace(host))
+			if host == "" {
+				continue
+			}
+			if other, ok := m.byHost[host]; ok {
+				return nil, fmt.Errorf("host %q is used by sites %q and %q", host, other.cfg.Site.ID, id)
+			}
+			m.byHost[host] = srv
+			registered++
+		}
+		if registered == 0 && srv != m.fallback {
+			return nil, fmt.Errorf("site %q: site.host is required", id)
+		}
+	}
+	if defaultID != "" && m.fallback == nil {
+		return nil, fmt.Errorf("default site %q not found", defaultID)
+	}
+	return m, nil
+}
+
+func (m *MultiSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/health":
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+		return
+	case "/metrics":
+		expvar.Handler().ServeHTTP(w, r)
+		return
+	}
+	if srv := m.siteFor(r.Host); srv != nil {
+		srv.ServeHTTP(w, r)
+		return
+	}
+	metricRequestsTotal.Add(1)
+	trackStatus(http.StatusNotFound)
+	http.NotFound(w, r)
+}
+
+func (m *MultiSite) siteFor(hostport string) *Server {
+	host := strings.ToLower(stripPort(hostport))
+	if srv, ok := m.byHost[host]; ok {
+		return srv
+	}
+	return m.fallback
+}
@@ -0,0 +1,83 @@
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func newTestSiteServer(t *testing.T, id, host string, aliases ...string) *Server {
+	t.Helper()
+	artifacts := t.TempDir()
+	if err := os.WriteFile(filepath.Join(artifacts, "robots.txt"), []byte("site "+id), 0o644); err != nil {
+		t.Fatalf("write robots: %v", err)
+	}
+	cfg := config.Config{
+		Site:    config.SiteConfig{ID: id, Host: host, HostAliases: aliases},
+		Content: config.ContentConfig{Source: "local", LocalDir: t.TempDir()},
+		Paths:   config.PathsConfig{ArtifactsDir: artifacts},
+	}
+	store := NewResolveStore(filepath.Join(artifacts, "resolve.json"), rules.Rules{}, false, nil)
+	return New(cfg, store, nil, nil, nil, rules.Rules{})
+}
+
+func TestMultiSiteDispatchByHost(t *testing.T) {
+	blog := newTestSiteServer(t, "blog", "blog.example.com", "www.blog.example.com")
+	docs := newTestSiteServer(t, "docs", "docs.example.com")
+	handler, err := NewMultiSite([]*Server{blog, docs}, "docs")
+	if err != nil {
+		t.Fatalf("NewMultiSite: %v", err)
+	}
+
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"blog.example.com", "site blog"},
+		{"WWW.blog.example.com:8080", "site blog"},
+		{"docs.example.com", "site docs"},
+		{"unknown.example.com", "site docs"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/robots.txt", nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Fatalf("host %q: got %d %q, want %q", tc.host, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestMultiSiteUnknownHostWithoutDefault(t *testing.T) {
+	blog := newTestSiteServer(t, "blog", "blog.example.com")
+	handler, err := NewMultiSite([]*Server{blog}, "")
+	if err != nil {
+		t.Fatalf("NewMultiSite: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/robots.txt", nil)
+	req.Host = "other.example.com"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestMultiSiteRejectsDuplicateHosts(t *testing.T) {
+	a := newTestSiteServer(t, "a", "example.com")
+	b := newTestSiteServer(t, "b", "other.example.com", "EXAMPLE.com")
+	if _, err := NewMultiSite([]*Server{a, b}, ""); err == nil {
+		t.Fatalf("expected duplicate host error")
+	}
+	c := newTestSiteServer(t, "c", "")
+	if _, err := NewMultiSite([]*Server{c}, ""); err == nil {
+		t.Fatalf("expected missing host error")
+	}
+}
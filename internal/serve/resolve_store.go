@@ -16,7 +16,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cookiespooky/notepub/internal/indexer"
 	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/mountutil"
 	"github.com/cookiespooky/notepub/internal/rules"
 	"github.com/cookiespooky/notepub/internal/wikilink"
 )
@@ -25,8 +27,13 @@ type ResolveStore struct {
 	path          string
 	mu            sync.RWMutex
 	mtime         time.Time
+	full          models.ResolveIndex
 	idx           models.ResolveIndex
+	nextWindow    time.Time
+	onWindow      func(models.ResolveIndex, time.Time)
+	includeDrafts bool
 	wiki          map[string]string
+	wikiByLang    map[string]map[string]string
 	search        []searchDoc
 	media         map[string]struct{}
 	settingsMedia map[string]struct{}
@@ -57,6 +64,7 @@ func (s *ResolveStore) Get() (models.ResolveIndex, error) {
 			return s.cachedOrError(err)
 		}
 	}
+	s.refreshWindow()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.idx, nil
@@ -76,6 +84,7 @@ func (s *ResolveStore) GetWithWikiMap() (models.ResolveIndex, map[string]string,
 			return s.cachedWithWikiOrError(err)
 		}
 	}
+	s.refreshWindow()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.idx, cloneWikiMap(s.wiki), nil
@@ -98,6 +107,7 @@ func (s *ResolveStore) GetWithWikiMapContext(ctx context.Context) (models.Resolv
 			return s.cachedWithWikiOrError(err)
 		}
 	}
+	s.refreshWindow()
 	if err := ctx.Err(); err != nil {
 		return s.cachedWithWikiOrError(err)
 	}
@@ -106,7 +116,24 @@ func (s *ResolveStore) GetWithWikiMapContext(ctx context.Context) (models.Resolv
 	return s.idx, cloneWikiMap(s.wiki), nil
 }
 
+// WikiMapFor returns the wiki map for notes in lang, or nil when the site has
+// no per-language notes. Call after GetWithWikiMap so the index is loaded.
+func (s *ResolveStore) WikiMapFor(lang string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.wikiByLang[lang]
+	if !ok {
+		return nil
+	}
+	return cloneWikiMap(m)
+}
+
 func (s *ResolveStore) Search(query string, limit int, cursor string) ([]SearchItem, string, error) {
+	return s.SearchLang(query, "", limit, cursor)
+}
+
+// SearchLang searches notes of one language; an empty lang searches all notes.
+func (s *ResolveStore) SearchLang(query, lang string, limit int, cursor string) ([]SearchItem, string, error) {
 	idx, docs, err := s.getSearchDocs()
 	if err != nil {
 		return nil, "", err
@@ -118,6 +145,9 @@ func (s *ResolveStore) Search(query string, limit int, cursor string) ([]SearchI
 	}
 	results := make([]scoredItem, 0)
 	for _, doc := range docs {
+		if lang != "" && doc.Lang != lang {
+			continue
+		}
 		score := scoreQuery(doc, q, s.rules.Search.FieldsBoost)
 		if score <= 0 {
 			continue
@@ -160,16 +190,83 @@ func (s *ResolveStore) reload(mtime time.Time) error {
 	if err := json.Unmarshal(data, &idx); err != nil {
 		return err
 	}
+	now := time.Now()
 	s.mu.Lock()
-	s.idx = idx
-	s.wiki = buildWikiMap(idx)
-	s.search = buildSearchIndex(idx, s.rules)
-	s.media = buildMediaAllowlist(idx)
+	s.full = idx
+	s.applyView(now)
 	s.mtime = mtime
+	view, hook := s.idx, s.onWindow
 	s.mu.Unlock()
+	// Artifacts were written at generated_at; if a window opened or closed
+	// since then they list the wrong notes.
+	if generated, err := time.Parse(time.RFC3339, idx.GeneratedAt); err == nil && hook != nil {
+		if _, next := indexer.PublishedView(idx, generated); !next.IsZero() && !next.After(now) {
+			hook(view, mtime)
+		}
+	}
 	return nil
 }
 
+// OnWindowChange registers fn to run with the new published view whenever a
+// publish_at or expire_at passes while serving. loaded is the modification
+// time of the resolve.json the view was built from.
+func (s *ResolveStore) OnWindowChange(fn func(view models.ResolveIndex, loaded time.Time)) {
+	s.mu.Lock()
+	s.onWindow = fn
+	s.mu.Unlock()
+}
+
+// IncludeDrafts controls whether notes with `draft: true` are routed.
+func (s *ResolveStore) IncludeDrafts(include bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.includeDrafts = include
+	if !s.mtime.IsZero() {
+		s.applyView(time.Now())
+	}
+}
+
+// refreshWindow recomputes the published view once the next publish window
+// boundary has passed, so scheduled notes appear without a reindex.
+func (s *ResolveStore) refreshWindow() {
+	now := time.Now()
+	s.mu.RLock()
+	due := !s.nextWindow.IsZero() && !now.Before(s.nextWindow)
+	s.mu.RUnlock()
+	if !due {
+		return
+	}
+	s.mu.Lock()
+	if s.nextWindow.IsZero() || now.Before(s.nextWindow) {
+		s.mu.Unlock()
+		return
+	}
+	s.applyView(now)
+	view, hook, loaded := s.idx, s.onWindow, s.mtime
+	s.mu.Unlock()
+	log.Printf("publish window changed: %d routes published", len(view.Routes))
+	if hook != nil {
+		hook(view, loaded)
+	}
+}
+
+// applyView rebuilds the derived lookups from s.full as published at now,
+// with or without drafts.
+// Callers hold s.mu.
+func (s *ResolveStore) applyView(now time.Time) {
+	base := s.full
+	if !s.includeDrafts {
+		base = indexer.ExcludeDrafts(base)
+	}
+	view, next := indexer.PublishedView(base, now)
+	s.idx = view
+	s.nextWindow = next
+	s.wiki = buildWikiMap(view)
+	s.wikiByLang = buildLangWikiMaps(view, s.wiki)
+	s.search = buildSearchIndex(view, s.rules)
+	s.media = buildMediaAllowlist(view)
+}
+
 func (s *ResolveStore) cachedOrError(err error) (models.ResolveIndex, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -221,6 +318,7 @@ func (s *ResolveStore) getSearchDocs() (models.ResolveIndex, []searchDoc, error)
 			return s.cachedSearchOrError(err)
 		}
 	}
+	s.refreshWindow()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.idx, append([]searchDoc{}, s.search...), nil
@@ -237,9 +335,13 @@ func (s *ResolveStore) cachedSearchOrError(err error) (models.ResolveIndex, []se
 
 func buildWikiMap(idx models.ResolveIndex) map[string]string {
 	out := map[string]string{}
-	for pathVal, meta := range idx.Meta {
-		route, ok := idx.Routes[pathVal]
-		if ok && route.S3Key != "" {
+	for _, pathVal := range mountutil.RankedPaths(idx) {
+		meta, ok := idx.Meta[pathVal]
+		if !ok {
+			continue
+		}
+		route := idx.Routes[pathVal]
+		if route.S3Key != "" {
 			name := filenameBase(route.S3Key)
 			addWikiKey(out, name, pathVal)
 		}
@@ -248,8 +350,8 @@ func buildWikiMap(idx models.ResolveIndex) map[string]string {
 		}
 		addWikiKey(out, meta.Title, pathVal)
 		addWikiKey(out, meta.Slug, pathVal)
-		if ok && route.S3Key != "" {
-			if rel := normalizePathKey(route.S3Key); rel != "" {
+		if route.S3Key != "" {
+			if rel := normalizePathKey(mountutil.LogicalKey(route.S3Key, idx.Mounts)); rel != "" {
 				addWikiKey(out, rel, pathVal)
 			}
 		}
@@ -263,6 +365,7 @@ type searchDoc struct {
 	Description string
 	UpdatedAt   string
 	Type        string
+	Lang        string
 	lowerTitle  string
 	lowerDesc   string
 	lowerPath   string
@@ -276,6 +379,7 @@ type SearchItem struct {
 	Score     float64 `json:"score"`
 	Type      string  `json:"type"`
 	UpdatedAt string  `json:"updatedAt,omitempty"`
+	Lang      string  `json:"lang,omitempty"`
 }
 
 type scoredItem struct {
@@ -301,7 +405,7 @@ func buildSearchIndex(idx models.ResolveIndex, cfg rules.Rules) []searchDoc {
 		}
 		title := strings.TrimSpace(meta.Title)
 		desc := strings.TrimSpace(meta.Description)
-		updated := route.LastModified
+		updated := firstNonEmpty(meta.Updated, route.LastModified)
 		docType := meta.Type
 		if docType == "" {
 			docType = "page"
@@ -312,6 +416,7 @@ func buildSearchIndex(idx models.ResolveIndex, cfg rules.Rules) []searchDoc {
 			Description: desc,
 			UpdatedAt:   updated,
 			Type:        docType,
+			Lang:        meta.Lang,
 			lowerTitle:  strings.ToLower(title),
 			lowerDesc:   strings.ToLower(desc),
 			lowerPath:   strings.ToLower(pathVal),
@@ -329,6 +434,7 @@ func (d searchDoc) toItem() SearchItem {
 		Snippet:   d.Description,
 		Type:      d.Type,
 		UpdatedAt: d.UpdatedAt,
+		Lang:      d.Lang,
 	}
 }
 
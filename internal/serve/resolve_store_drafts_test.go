@@ -0,0 +1,69 @@
+package serve
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func writeTestResolve(t *testing.T, idx models.ResolveIndex) string {
+	t.Helper()
+	data, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	p := filepath.Join(t.TempDir(), "resolve.json")
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return p
+}
+
+func TestResolveStoreDraftRouting(t *testing.T) {
+	p := writeTestResolve(t, models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{
+			"/live":  {Status: 200, S3Key: "live.md"},
+			"/draft": {Status: 200, S3Key: "draft.md"},
+		},
+		Meta: map[string]models.MetaEntry{
+			"/live":  {Title: "Live"},
+			"/draft": {Title: "Draft", Draft: true},
+		},
+	})
+
+	store := NewResolveStore(p, rules.Rules{}, false, nil)
+	idx, err := store.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, ok := idx.Routes["/draft"]; ok {
+		t.Fatalf("expected draft to be excluded by default")
+	}
+	if _, ok := idx.Routes["/live"]; !ok {
+		t.Fatalf("expected /live to be routed")
+	}
+
+	store.IncludeDrafts(true)
+	idx, err = store.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, ok := idx.Routes["/draft"]; !ok {
+		t.Fatalf("expected draft to be routed in preview")
+	}
+}
+
+func TestInjectDraftBanner(t *testing.T) {
+	got := injectDraftBanner(`<html><body class="page"><main>x</main></body></html>`)
+	if !strings.Contains(got, `<body class="page">`+draftBanner+`<main>`) {
+		t.Fatalf("banner not placed after <body>: %s", got)
+	}
+	if got := injectDraftBanner("<p>x</p>"); !strings.HasPrefix(got, draftBanner) {
+		t.Fatalf("expected banner prefix without <body>: %s", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"log"
 	"mime"
 	"net"
 	"net/http"
@@ -16,14 +17,18 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/yuin/goldmark"
 
 	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/indexer"
 	"github.com/cookiespooky/notepub/internal/localutil"
+	"github.com/cookiespooky/notepub/internal/mediautil"
 	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/mountutil"
 	"github.com/cookiespooky/notepub/internal/rules"
 	"github.com/cookiespooky/notepub/internal/s3util"
 	"github.com/cookiespooky/notepub/internal/urlutil"
@@ -45,6 +50,8 @@ var (
 	metricCacheHit      = expvar.NewInt("notepub_cache_hit")
 	metricCacheMiss     = expvar.NewInt("notepub_cache_miss")
 	metricCacheStale    = expvar.NewInt("notepub_cache_stale")
+	metricSiteRequests  = expvar.NewMap("notepub_site_requests_total")
+	metricSiteResponses = expvar.NewMap("notepub_site_responses")
 )
 
 type Server struct {
@@ -56,10 +63,22 @@ type Server struct {
 	md         goldmark.Markdown
 	rules      rules.Rules
 	htmlPolicy string
+	mounts     *mountutil.Reader
+	// hostDispatched is set when a MultiSite has already routed by host.
+	hostDispatched bool
 }
 
 func New(cfg config.Config, store *ResolveStore, cache *HtmlCache, theme *Theme, s3client *s3.Client, rulesCfg rules.Rules) *Server {
 	md := newMarkdownRenderer()
+	var sitemapMu sync.Mutex
+	store.OnWindowChange(func(idx models.ResolveIndex, loaded time.Time) {
+		sitemapMu.Lock()
+		defer sitemapMu.Unlock()
+		if err := indexer.RewriteSitemaps(cfg, indexer.ExcludeDrafts(idx), loaded, rulesCfg); err != nil {
+			log.Printf("sitemap rewrite failed, sitemap.xml keeps the indexed routes until the next index run: %v", err)
+		}
+	})
+	store.IncludeDrafts(cfg.DraftPreview())
 	return &Server{
 		cfg:        cfg,
 		store:      store,
@@ -69,6 +88,7 @@ func New(cfg config.Config, store *ResolveStore, cache *HtmlCache, theme *Theme,
 		md:         md,
 		rules:      rulesCfg,
 		htmlPolicy: cfg.Markdown.HTMLPolicy,
+		mounts:     mountutil.NewReader(cfg, s3client),
 	}
 }
 
@@ -78,6 +98,7 @@ func (s *Server) Router() http.Handler {
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	metricRequestsTotal.Add(1)
+	metricSiteRequests.Add(s.cfg.Site.ID, 1)
 	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 	switch {
 	case r.URL.Path == "/health":
@@ -94,7 +115,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleMedia(rec, r)
 	case strings.HasPrefix(r.URL.Path, "/v1/search"):
 		s.handleSearch(rec, r)
-	case r.URL.Path == "/search":
+	case s.isSearchPath(r.URL.Path):
 		s.handleSearchPage(rec, r)
 	case r.URL.Path == "/favicon.ico":
 		s.handleFavicon(rec, r)
@@ -102,6 +123,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handlePage(rec, r)
 	}
 	trackStatus(rec.status)
+	trackSiteStatus(s.cfg.Site.ID, rec.status)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
@@ -115,6 +137,8 @@ func (s *Server) handleRobots(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	// Loading the index rewrites sitemaps if a publish window has passed.
+	_, _ = s.store.Get()
 	name := filepath.Base(r.URL.Path)
 	if !strings.HasPrefix(name, "sitemap") {
 		name = "sitemap-index.xml"
@@ -167,27 +191,49 @@ func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if s.cfg.Content.Source == "local" {
-		if prefix := s.cfg.S3.Prefix; prefix != "" && !strings.HasPrefix(key, prefix) {
-			key = path.Join(prefix, key)
+	mounts, key := s.mounts.Candidates(key)
+	for i, m := range mounts {
+		mountKey := key
+		if prefix := m.Prefix; prefix != "" && !strings.HasPrefix(mountKey, prefix) {
+			mountKey = path.Join(prefix, mountKey)
 		}
-		localPath, err := resolveLocalMediaPath(s.cfg.Content.LocalDir, key)
-		if err != nil {
-			http.NotFound(w, r)
+		switch m.Source {
+		case "local":
+			localPath, err := resolveLocalMediaPath(m.Dir, mountKey)
+			if err != nil {
+				continue
+			}
+			serveFile(w, r, localPath, "")
+			return
+		case "s3":
+			// Only the last candidate is served without checking that the
+			// object exists, so plain keys fall through to later mounts.
+			if i < len(mounts)-1 && !s.s3MediaExists(r.Context(), m.Bucket, mountKey) {
+				continue
+			}
+			s.serveS3Media(w, r, m.Bucket, mountKey)
 			return
 		}
-		serveFile(w, r, localPath, "")
-		return
 	}
+	http.NotFound(w, r)
+}
 
-	if prefix := s.cfg.S3.Prefix; prefix != "" && !strings.HasPrefix(key, prefix) {
-		key = path.Join(prefix, key)
+func (s *Server) s3MediaExists(ctx context.Context, bucket, key string) bool {
+	if s.s3client == nil {
+		return false
 	}
+	headCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	_, err := s.s3client.HeadObject(headCtx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key})
+	return err == nil
+}
+
+func (s *Server) serveS3Media(w http.ResponseWriter, r *http.Request, bucket, key string) {
 	if s.cfg.S3.Anonymous {
 		fetchCtx, cancelFetch := context.WithTimeout(r.Context(), fetchTimeout)
 		defer cancelFetch()
 		resp, err := s.s3client.GetObject(fetchCtx, &s3.GetObjectInput{
-			Bucket: &s.cfg.S3.Bucket,
+			Bucket: &bucket,
 			Key:    &key,
 		})
 		if err != nil {
@@ -204,7 +250,7 @@ func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
 		_, _ = io.Copy(w, resp.Body)
 		return
 	}
-	psURL, _, err := s3util.PresignGet(r.Context(), s.s3client, s.cfg.S3.Bucket, key)
+	psURL, _, err := s3util.PresignGet(r.Context(), s.s3client, bucket, key)
 	if err != nil {
 		http.NotFound(w, r)
 		return
@@ -235,7 +281,8 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	items, nextCursor, err := s.store.Search(q, limit, cursor)
+	lang := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("lang")))
+	items, nextCursor, err := s.store.SearchLang(q, lang, limit, cursor)
 	if err != nil {
 		http.Error(w, "search unavailable", http.StatusServiceUnavailable)
 		return
@@ -261,13 +308,18 @@ func (s *Server) handleSearchPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	routePath := r.URL.Path
+	lang, _ := searchPathLang(s.cfg, routePath)
+	if l := s.store.WikiMapFor(lang); l != nil {
+		wikiMap = l
+	}
 	q := strings.TrimSpace(r.URL.Query().Get("q"))
 	cursor := r.URL.Query().Get("cursor")
 	limit := 10
 	items := []SearchItem{}
 	nextCursor := ""
 	if len(q) >= 2 {
-		if res, next, err := s.store.Search(q, limit, cursor); err == nil {
+		if res, next, err := s.store.SearchLang(q, lang, limit, cursor); err == nil {
 			items = res
 			nextCursor = next
 		}
@@ -275,8 +327,8 @@ func (s *Server) handleSearchPage(w http.ResponseWriter, r *http.Request) {
 
 	meta := models.MetaEntry{
 		Title: "Search",
+		Lang:  lang,
 	}
-	routePath := "/search"
 	route, routeOK := idx.Routes[routePath]
 	if routeOK {
 		if route.Status == 301 && route.RedirectTo != "" {
@@ -298,49 +350,14 @@ func (s *Server) handleSearchPage(w http.ResponseWriter, r *http.Request) {
 		}
 		meta.Title = baseTitle + ": " + q
 	}
-	meta.Canonical = buildSearchCanonical(s.cfg.Site.BaseURL, q, cursor)
+	meta.Canonical = buildSearchCanonical(s.cfg.Site.BaseURL, routePath, q, cursor)
 	meta.Robots = "noindex, follow"
 
 	body := ""
 	if routeOK && route.S3Key != "" {
-		var markdown string
-		if s.cfg.Content.Source == "local" {
-			localPath, err := localutil.ResolvePath(s.cfg.Content.LocalDir, route.S3Key)
-			if err == nil {
-				if file, openErr := os.Open(localPath); openErr == nil {
-					defer file.Close()
-					if mdBody, readErr := readMarkdownLimited(file); readErr == nil {
-						markdown = mdBody
-					}
-				}
-			}
-		} else if s.cfg.S3.Anonymous {
-			fetchCtx, cancelFetch := context.WithTimeout(r.Context(), fetchTimeout)
-			resp, fetchErr := s.s3client.GetObject(fetchCtx, &s3.GetObjectInput{
-				Bucket: &s.cfg.S3.Bucket,
-				Key:    &route.S3Key,
-			})
-			cancelFetch()
-			if fetchErr == nil {
-				defer resp.Body.Close()
-				if mdBody, readErr := readMarkdownLimited(resp.Body); readErr == nil {
-					markdown = mdBody
-				}
-			}
-		} else {
-			psCtx, cancelPresign := context.WithTimeout(r.Context(), presignTimeout)
-			psURL, _, psErr := s3util.PresignGet(psCtx, s.s3client, s.cfg.S3.Bucket, route.S3Key)
-			cancelPresign()
-			if psErr == nil {
-				fetchCtx, cancelFetch := context.WithTimeout(r.Context(), fetchTimeout)
-				if mdBody, fetchErr := fetchPresigned(fetchCtx, psURL); fetchErr == nil {
-					markdown = mdBody
-				}
-				cancelFetch()
-			}
-		}
+		markdown, _ := s.fetchMarkdown(r.Context(), route.S3Key)
 		if markdown != "" {
-			if htmlBody, renderErr := s.renderMarkdown(markdown, route.S3Key, wikiMap); renderErr == nil {
+			if htmlBody, renderErr := s.renderMarkdown(markdown, route.S3Key, wikiMap, s.shortcodesFor(idx, routePath, route.S3Key)); renderErr == nil {
 				body = htmlBody
 			}
 		}
@@ -352,7 +369,9 @@ func (s *Server) handleSearchPage(w http.ResponseWriter, r *http.Request) {
 	data.SearchQuery = q
 	data.SearchItems = items
 	data.SearchNextCursor = nextCursor
-	data.Collections = buildCollections(idx, s.rules, "/search")
+	data.Collections = buildCollections(idx, s.rules, routePath)
+	data.Translations = buildTranslations(idx, routePath, s.cfg)
+	data.funcs = newTemplateContext(s.cfg, s.rules, idx, routePath, s.md)
 
 	rendered, err := s.theme.RenderPage(data)
 	if err != nil {
@@ -388,6 +407,13 @@ func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
 	}
 	pathVal, route, ok := resolveRoutePath(idx.Routes, requestPath)
 	if !ok {
+		if target, ok := resolveRedirect(idx.Redirects, requestPath); ok {
+			if raw := strings.TrimSpace(r.URL.RawQuery); raw != "" {
+				target += "?" + raw
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
 		s.renderNotFound(w, r)
 		return
 	}
@@ -424,93 +450,114 @@ func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
 		s.serveStaleOr503(w, r, pathVal)
 		return
 	}
-	var markdown string
-	if s.cfg.Content.Source == "local" {
-		localPath, err := localutil.ResolvePath(s.cfg.Content.LocalDir, route.S3Key)
+	markdown, err := s.fetchMarkdown(r.Context(), route.S3Key)
+	if err != nil {
+		s.serveStaleOr503(w, r, pathVal)
+		return
+	}
+
+	if l := s.store.WikiMapFor(idx.Meta[pathVal].Lang); l != nil {
+		wikiMap = l
+	}
+	htmlBody, err := s.renderMarkdown(markdown, route.S3Key, wikiMap, s.shortcodesFor(idx, pathVal, route.S3Key))
+	if err != nil {
+		s.serveStaleOr503(w, r, pathVal)
+		return
+	}
+	_ = s.cache.Write(s.cfg.Site.ID, pathVal, route.RouteETag, htmlBody)
+	s.writePage(w, pathVal, idx, route, htmlBody, "miss", false)
+}
+
+// fetchMarkdown reads a note body from the mount that owns key.
+func (s *Server) fetchMarkdown(ctx context.Context, key string) (string, error) {
+	m, raw, ok := s.mounts.Lookup(key)
+	if !ok {
+		return "", fmt.Errorf("no content mount for key %q", key)
+	}
+	if m.Source == "local" {
+		localPath, err := localutil.ResolvePath(m.Dir, raw)
 		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
+			return "", err
 		}
 		file, err := os.Open(localPath)
 		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
+			return "", err
 		}
 		defer file.Close()
-		body, err := readMarkdownLimited(file)
-		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
-		}
-		markdown = body
-	} else if s.cfg.S3.Anonymous {
-		fetchCtx, cancelFetch := context.WithTimeout(r.Context(), fetchTimeout)
+		return readMarkdownLimited(file)
+	}
+	if s.cfg.S3.Anonymous {
+		fetchCtx, cancelFetch := context.WithTimeout(ctx, fetchTimeout)
 		defer cancelFetch()
 		resp, err := s.s3client.GetObject(fetchCtx, &s3.GetObjectInput{
-			Bucket: &s.cfg.S3.Bucket,
-			Key:    &route.S3Key,
+			Bucket: &m.Bucket,
+			Key:    &raw,
 		})
 		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
+			return "", err
 		}
 		defer resp.Body.Close()
-		body, err := readMarkdownLimited(resp.Body)
-		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
-		}
-		markdown = body
-	} else {
-		psCtx, cancelPresign := context.WithTimeout(r.Context(), presignTimeout)
-		defer cancelPresign()
-		psURL, _, err := s3util.PresignGet(psCtx, s.s3client, s.cfg.S3.Bucket, route.S3Key)
-		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
-		}
-		fetchCtx, cancelFetch := context.WithTimeout(r.Context(), fetchTimeout)
-		defer cancelFetch()
-		body, err := fetchPresigned(fetchCtx, psURL)
-		if err != nil {
-			s.serveStaleOr503(w, r, pathVal)
-			return
-		}
-		markdown = body
+		return readMarkdownLimited(resp.Body)
 	}
-
-	htmlBody, err := s.renderMarkdown(markdown, route.S3Key, wikiMap)
+	psCtx, cancelPresign := context.WithTimeout(ctx, presignTimeout)
+	psURL, _, err := s3util.PresignGet(psCtx, s.s3client, m.Bucket, raw)
+	cancelPresign()
 	if err != nil {
-		s.serveStaleOr503(w, r, pathVal)
-		return
+		return "", err
 	}
-	_ = s.cache.Write(s.cfg.Site.ID, pathVal, route.RouteETag, htmlBody)
-	s.writePage(w, pathVal, idx, route, htmlBody, "miss", false)
+	fetchCtx, cancelFetch := context.WithTimeout(ctx, fetchTimeout)
+	defer cancelFetch()
+	return fetchPresigned(fetchCtx, psURL)
 }
 
-func (s *Server) renderMarkdown(markdown string, baseKey string, wikiMap map[string]string) (string, error) {
+func (s *Server) renderMarkdown(markdown string, baseKey string, wikiMap map[string]string, shortcodes *shortcodeRenderer) (string, error) {
 	markdown = normalizeMarkdownImages(markdown, baseKey, s.cfg.S3.Prefix, s.cfg.Site.MediaBaseURL)
+	markdown = shortcodes.extract(markdown)
 	markdown = normalizeMarkdownLinks(markdown, wikiMap, s.cfg.Site.BaseURL)
+	markdown, err := shortcodes.expand(markdown)
+	if err != nil {
+		return "", err
+	}
 	var buf strings.Builder
 	if err := s.md.Convert([]byte(markdown), &buf); err != nil {
 		return "", err
 	}
 	body := postprocessRenderedHTML(buf.String())
 	body, _ = applyHTMLPolicy(body, s.htmlPolicy)
-	return body, nil
+	return shortcodes.fill(body), nil
+}
+
+// shortcodesFor prepares shortcode rendering for the note at pathVal.
+func (s *Server) shortcodesFor(idx models.ResolveIndex, pathVal, baseKey string) *shortcodeRenderer {
+	data := buildPageData(idx.Meta[pathVal], "", s.cfg)
+	data.funcs = newTemplateContext(s.cfg, s.rules, idx, pathVal, s.md)
+	r := newShortcodeRenderer(s.theme, data, s.md, s.htmlPolicy)
+	r.media = func(src string) string {
+		return mediautil.ResolveMediaLink(src, baseKey, s.cfg.S3.Prefix, s.cfg.Site.MediaBaseURL)
+	}
+	return r
 }
 
 func (s *Server) writePage(w http.ResponseWriter, pathVal string, idx models.ResolveIndex, route models.RouteEntry, body string, cacheStatus string, stale bool) {
 	s.writePageHeaders(w, route, cacheStatus, stale)
 	meta := idx.Meta[pathVal]
 	data := buildPageData(meta, body, s.cfg)
-	data.Template = s.templateForType(meta.Type)
+	data.Template = firstNonEmpty(meta.Template, s.templateForType(meta.Type))
+	data.Layout = meta.Layout
 	data.Page.NoIndex = route.NoIndex
 	data.SearchMode = "server"
-	if pathVal == "/" {
+	if pathVal == "/" || isLanguageHome(s.cfg, pathVal) {
 		data.IsHome = true
 	}
 	data.Collections = buildCollections(idx, s.rules, pathVal)
+	data.Translations = buildTranslations(idx, pathVal, s.cfg)
+	data.funcs = newTemplateContext(s.cfg, s.rules, idx, pathVal, s.md)
+	data.IsDraft = meta.Draft
+	if data.IsDraft {
+		// Drafts are only routed in preview; keep them out of caches and indexes.
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	}
 	rendered, err := s.theme.RenderPage(data)
 	if err != nil {
 		if html, renderErr := s.theme.RenderError(err, data); renderErr == nil {
@@ -521,10 +568,31 @@ func (s *Server) writePage(w http.ResponseWriter, pathVal string, idx models.Res
 		}
 		return
 	}
+	if data.IsDraft {
+		rendered = injectDraftBanner(rendered)
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(rendered))
 }
 
+const draftBanner = `<div class="notepub-draft-banner" role="status" style="position:sticky;top:0;z-index:9999;padding:6px 12px;background:#ffe08a;color:#222;font:600 14px/1.4 sans-serif;text-align:center">Draft preview: this note is not published</div>`
+
+// injectDraftBanner inserts the draft banner right after the opening <body>
+// tag, or at the top of the page when the theme has none.
+func injectDraftBanner(page string) string {
+	lower := strings.ToLower(page)
+	start := strings.Index(lower, "<body")
+	if start < 0 {
+		return draftBanner + page
+	}
+	end := strings.Index(page[start:], ">")
+	if end < 0 {
+		return draftBanner + page
+	}
+	pos := start + end + 1
+	return page[:pos] + draftBanner + page[pos:]
+}
+
 func buildPageData(meta models.MetaEntry, body string, cfg config.Config) PageData {
 	data := PageData{
 		Title:      meta.Title,
@@ -533,7 +601,8 @@ func buildPageData(meta models.MetaEntry, body string, cfg config.Config) PageDa
 		AssetsBase: urlutil.JoinBaseURL(cfg.Site.BaseURL, "/assets"),
 		Meta:       MetaData{Robots: meta.Robots},
 		Body:       template.HTML(body),
-		Settings:   cloneSettings(cfg.Settings),
+		Settings:   cloneSettings(cfg.SettingsFor(meta.Lang)),
+		Lang:       firstNonEmpty(meta.Lang, cfg.DefaultLanguage(), cfg.Settings["site_language"]),
 		Page: PageInfo{
 			Type:        meta.Type,
 			Slug:        meta.Slug,
@@ -619,8 +688,13 @@ func (s *Server) serveStaleOr503(w http.ResponseWriter, r *http.Request, routePa
 	http.Error(w, "Index temporarily unavailable", http.StatusServiceUnavailable)
 }
 
+func (s *Server) isSearchPath(requestPath string) bool {
+	_, ok := searchPathLang(s.cfg, requestPath)
+	return ok
+}
+
 func (s *Server) hostAllowed(host string) bool {
-	if s.cfg.Site.Host == "" {
+	if s.hostDispatched || s.cfg.Site.Host == "" {
 		return true
 	}
 	if strings.EqualFold(host, s.cfg.Site.Host) {
@@ -642,6 +716,26 @@ func stripPort(hostport string) string {
 }
 
 func resolveRoutePath(routes map[string]models.RouteEntry, requestPath string) (string, models.RouteEntry, bool) {
+	for _, candidate := range routeCandidates(requestPath) {
+		if route, ok := routes[candidate]; ok {
+			return candidate, route, true
+		}
+	}
+	return "", models.RouteEntry{}, false
+}
+
+// resolveRedirect looks requestPath up in the redirect table with the same
+// trailing-slash tolerance as routes.
+func resolveRedirect(redirects map[string]models.Redirect, requestPath string) (string, bool) {
+	for _, candidate := range routeCandidates(requestPath) {
+		if r, ok := redirects[candidate]; ok && r.To != "" {
+			return r.To, true
+		}
+	}
+	return "", false
+}
+
+func routeCandidates(requestPath string) []string {
 	normalized := path.Clean("/" + strings.TrimSpace(requestPath))
 	if normalized == "." || normalized == "" {
 		normalized = "/"
@@ -660,12 +754,7 @@ func resolveRoutePath(routes map[string]models.RouteEntry, requestPath string) (
 			candidates = append(candidates, slashed)
 		}
 	}
-	for _, candidate := range candidates {
-		if route, ok := routes[candidate]; ok {
-			return candidate, route, true
-		}
-	}
-	return "", models.RouteEntry{}, false
+	return candidates
 }
 
 func fetchPresigned(ctx context.Context, url string) (string, error) {
@@ -695,8 +784,7 @@ func readMarkdownLimited(r io.Reader) (string, error) {
 
 func serveThemeAsset(w http.ResponseWriter, r *http.Request, theme *Theme, name string) bool {
 	assetFS := theme.AssetFS()
-	path := filepath.ToSlash(filepath.Join(theme.assetsSubdir, name))
-	file, err := assetFS.Open(path)
+	file, err := assetFS.Open(filepath.ToSlash(name))
 	if err != nil {
 		return false
 	}
@@ -776,8 +864,8 @@ func buildAbsoluteURL(baseURL, p string) string {
 	return urlutil.JoinBaseURL(baseURL, p)
 }
 
-func buildSearchCanonical(baseURL, q, cursor string) string {
-	base := urlutil.JoinBaseURL(baseURL, "/search")
+func buildSearchCanonical(baseURL, searchPath, q, cursor string) string {
+	base := urlutil.JoinBaseURL(baseURL, searchPath)
 	u, err := url.Parse(base)
 	if err != nil {
 		return base
@@ -810,6 +898,13 @@ func (r *statusRecorder) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+func trackSiteStatus(siteID string, status int) {
+	if status < 200 {
+		return
+	}
+	metricSiteResponses.Add(fmt.Sprintf("%s_%dxx", siteID, status/100), 1)
+}
+
 func trackStatus(status int) {
 	switch {
 	case status >= 200 && status < 300:
@@ -822,3 +917,12 @@ func trackStatus(status int) {
 		metric5xx.Add(1)
 	}
 }
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
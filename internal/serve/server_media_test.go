@@ -1,9 +1,14 @@
 package serve
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/rules"
 )
 
 func TestResolveLocalMediaPathFallbackToSiblingMediaDir(t *testing.T) {
@@ -28,3 +33,43 @@ func TestResolveLocalMediaPathFallbackToSiblingMediaDir(t *testing.T) {
 		t.Fatalf("resolveLocalMediaPath = %q, want %q", gotPath, wantPath)
 	}
 }
+
+func TestHandleMediaTriesMountsInPrecedenceOrder(t *testing.T) {
+	root := t.TempDir()
+	docsDir := filepath.Join(root, "docs", "content")
+	blogDir := filepath.Join(root, "blog", "content")
+	for _, dir := range []string{docsDir, filepath.Join(blogDir, "img")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(blogDir, "img", "cover.png"), []byte("blog cover"), 0o644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+	cfg := config.Config{
+		Content: config.ContentConfig{
+			Source: "mounts",
+			Mounts: []config.ContentMount{
+				{Name: "blog", Source: "local", Dir: blogDir},
+				{Name: "docs", Source: "local", Dir: docsDir},
+			},
+			Precedence: []string{"docs", "blog"},
+		},
+		Paths: config.PathsConfig{ArtifactsDir: t.TempDir()},
+	}
+	store := NewResolveStore(filepath.Join(cfg.Paths.ArtifactsDir, "resolve.json"), rules.Rules{}, true, nil)
+	srv := New(cfg, store, nil, nil, nil, rules.Rules{})
+
+	for _, target := range []string{"/media/img/cover.png", "/media/@blog/img/cover.png"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "blog cover" {
+			t.Fatalf("%s: got %d %q", target, rec.Code, rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/media/@docs/img/cover.png", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("namespaced key served from another mount: %d", rec.Code)
+	}
+}
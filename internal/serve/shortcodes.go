@@ -0,0 +1,278 @@
+package serve
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/mdproc"
+)
+
+// Shortcodes are rendered in two passes. Tags are first swapped for
+// placeholders so wikilink and inline rewriting leaves their arguments
+// alone; after that each tag is rendered through shortcodes/<name>.html and
+// replaced by a second placeholder that survives Markdown conversion and the
+// HTML policy, and is filled in last. The placeholders are private-use runes;
+// icon fonts use that range too, so the runes are escaped in the note first
+// (U+E000–U+E004 become U+E004 and a digit) and restored while filling.
+const (
+	shortcodeTagOpen  = "\uE000"
+	shortcodeTagClose = "\uE001"
+	shortcodeOutOpen  = "\uE002"
+	shortcodeOutClose = "\uE003"
+	shortcodeEscape   = "\uE004"
+)
+
+var (
+	shortcodeTagRe = regexp.MustCompile(shortcodeTagOpen + `(\d+)` + shortcodeTagClose)
+	shortcodeOutRe = regexp.MustCompile(`(?:<p>)?` + shortcodeOutOpen + `(\d+)` + shortcodeOutClose + `(?:</p>)?|` + shortcodeEscape + `[0-4]`)
+
+	shortcodeEscaper = strings.NewReplacer(
+		"\uE000", shortcodeEscape+"0",
+		"\uE001", shortcodeEscape+"1",
+		"\uE002", shortcodeEscape+"2",
+		"\uE003", shortcodeEscape+"3",
+		"\uE004", shortcodeEscape+"4",
+	)
+	shortcodeUnescaper = strings.NewReplacer(
+		shortcodeEscape+"0", "\uE000",
+		shortcodeEscape+"1", "\uE001",
+		shortcodeEscape+"2", "\uE002",
+		shortcodeEscape+"3", "\uE003",
+		shortcodeEscape+"4", "\uE004",
+	)
+)
+
+// ShortcodeData is what shortcodes/<name>.html templates render with.
+type ShortcodeData struct {
+	Name string
+	// Params holds key="value" arguments, Args the positional ones.
+	Params map[string]string
+	Args   []string
+	// Inner is the Markdown between paired tags, rendered to HTML; a single
+	// paragraph is unwrapped.
+	Inner template.HTML
+	Page  PageInfo
+	FM    map[string]interface{}
+	Lang  string
+}
+
+// Get returns a named parameter, or the positional argument when key is an
+// index: {{ .Get "src" }}, {{ .Get 0 }}.
+func (d ShortcodeData) Get(key interface{}) string {
+	if i, ok := key.(int); ok {
+		if i >= 0 && i < len(d.Args) {
+			return d.Args[i]
+		}
+		return ""
+	}
+	return d.Params[toText(key)]
+}
+
+// shortcodeRenderer expands the shortcodes of one note.
+type shortcodeRenderer struct {
+	theme      *Theme
+	page       PageData
+	md         markdownRenderer
+	htmlPolicy string
+	// media resolves a src parameter like a Markdown image target.
+	media func(string) string
+
+	// tmpl is borrowed from the theme while expand runs.
+	tmpl *template.Template
+	tags []mdproc.Shortcode
+	out  []string
+}
+
+func newShortcodeRenderer(theme *Theme, page PageData, md markdownRenderer, htmlPolicy string) *shortcodeRenderer {
+	return &shortcodeRenderer{theme: theme, page: page, md: md, htmlPolicy: htmlPolicy}
+}
+
+// extract escapes placeholder runes in markdown and swaps every shortcode tag
+// outside code for a placeholder. Raw keeps the escaped form so an unknown
+// tag written back is restored by fill like the text around it.
+func (r *shortcodeRenderer) extract(markdown string) string {
+	if r == nil || r.theme == nil {
+		return markdown
+	}
+	markdown = shortcodeEscaper.Replace(markdown)
+	return mdproc.RewriteShortcodes(markdown, func(sc mdproc.Shortcode) string {
+		sc.Args = unescapeShortcodeArgs(sc.Args)
+		for k, v := range sc.Params {
+			sc.Params[k] = unescapeShortcodeText(v)
+		}
+		r.tags = append(r.tags, sc)
+		return shortcodeTagOpen + strconv.Itoa(len(r.tags)-1) + shortcodeTagClose
+	})
+}
+
+// expand renders the tag placeholders in markdown. An opening tag pairs with
+// the next closing tag of the same name at the same nesting depth; without
+// one it renders on its own. Unknown shortcodes and stray closing tags are
+// left as written.
+func (r *shortcodeRenderer) expand(markdown string) (string, error) {
+	if r == nil || len(r.tags) == 0 {
+		return markdown, nil
+	}
+	bound, err := r.theme.acquireTemplates(r.page)
+	if err != nil {
+		return "", err
+	}
+	defer r.theme.releaseTemplates(bound)
+	r.tmpl = bound.tmpl
+	defer func() { r.tmpl = nil }()
+	return r.expandTags(markdown)
+}
+
+func (r *shortcodeRenderer) expandTags(markdown string) (string, error) {
+	var locs [][]int
+	var ids []int
+	for _, loc := range shortcodeTagRe.FindAllStringSubmatchIndex(markdown, -1) {
+		id, err := strconv.Atoi(markdown[loc[2]:loc[3]])
+		if err != nil || id >= len(r.tags) {
+			continue
+		}
+		locs = append(locs, loc)
+		ids = append(ids, id)
+	}
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(locs); i++ {
+		tag := r.tags[ids[i]]
+		b.WriteString(markdown[last:locs[i][0]])
+		last = locs[i][1]
+		if tag.Closing || !r.known(tag.Name) {
+			b.WriteString(tag.Raw)
+			continue
+		}
+		inner := ""
+		if j := r.closingTag(ids, i); j >= 0 {
+			expanded, err := r.expandTags(markdown[locs[i][1]:locs[j][0]])
+			if err != nil {
+				return "", err
+			}
+			inner = expanded
+			last = locs[j][1]
+			i = j
+		}
+		html, err := r.render(tag, inner)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(shortcodeOutOpen + strconv.Itoa(len(r.out)) + shortcodeOutClose)
+		r.out = append(r.out, html)
+	}
+	b.WriteString(markdown[last:])
+	return b.String(), nil
+}
+
+func (r *shortcodeRenderer) closingTag(ids []int, open int) int {
+	name := r.tags[ids[open]].Name
+	depth := 0
+	for j := open + 1; j < len(ids); j++ {
+		tag := r.tags[ids[j]]
+		if tag.Name != name {
+			continue
+		}
+		if !tag.Closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return j
+		}
+		depth--
+	}
+	return -1
+}
+
+func (r *shortcodeRenderer) known(name string) bool {
+	return templateExists(r.theme.page, shortcodeTemplate(name))
+}
+
+func (r *shortcodeRenderer) render(tag mdproc.Shortcode, inner string) (string, error) {
+	params := tag.Params
+	if src, ok := params["src"]; ok && r.media != nil {
+		params = make(map[string]string, len(tag.Params))
+		for k, v := range tag.Params {
+			params[k] = v
+		}
+		params["src"] = r.media(src)
+	}
+	data := ShortcodeData{
+		Name:   tag.Name,
+		Params: params,
+		Args:   tag.Args,
+		Page:   r.page.Page,
+		FM:     r.page.FM,
+		Lang:   r.page.Lang,
+	}
+	if strings.TrimSpace(inner) != "" {
+		var buf strings.Builder
+		if err := r.md.Convert([]byte(inner), &buf); err != nil {
+			return "", err
+		}
+		body := strings.TrimSpace(postprocessRenderedHTML(buf.String()))
+		body, _ = applyHTMLPolicy(body, r.htmlPolicy)
+		data.Inner = template.HTML(unwrapParagraph(r.fill(body)))
+	}
+	var buf strings.Builder
+	if err := r.tmpl.ExecuteTemplate(&buf, shortcodeTemplate(tag.Name), data); err != nil {
+		return "", fmt.Errorf("shortcode %s: %w", tag.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// fill replaces output placeholders in the converted HTML and restores
+// escaped runes in one pass; a placeholder that was a paragraph of its own
+// takes the paragraph's place. Nested shortcodes are filled into their
+// parent's Inner when the parent renders, so r.out is final and replacements
+// are never scanned again.
+func (r *shortcodeRenderer) fill(body string) string {
+	if r == nil || r.theme == nil {
+		return body
+	}
+	return shortcodeOutRe.ReplaceAllStringFunc(body, func(m string) string {
+		if strings.HasPrefix(m, shortcodeEscape) {
+			return unescapeShortcodeText(m)
+		}
+		sub := shortcodeOutRe.FindStringSubmatch(m)
+		id, err := strconv.Atoi(sub[1])
+		if err != nil || id >= len(r.out) {
+			return ""
+		}
+		html := r.out[id]
+		if strings.HasPrefix(m, "<p>") != strings.HasSuffix(m, "</p>") {
+			// Only one side of the paragraph matched; keep it.
+			if strings.HasPrefix(m, "<p>") {
+				return "<p>" + html
+			}
+			return html + "</p>"
+		}
+		return html
+	})
+}
+
+func unescapeShortcodeText(s string) string {
+	return shortcodeUnescaper.Replace(s)
+}
+
+func unescapeShortcodeArgs(args []string) []string {
+	for i, a := range args {
+		args[i] = unescapeShortcodeText(a)
+	}
+	return args
+}
+
+func shortcodeTemplate(name string) string {
+	return "shortcodes/" + name + ".html"
+}
+
+func unwrapParagraph(body string) string {
+	if strings.HasPrefix(body, "<p>") && strings.HasSuffix(body, "</p>") && strings.Count(body, "<p>") == 1 {
+		return strings.TrimSuffix(strings.TrimPrefix(body, "<p>"), "</p>")
+	}
+	return body
+}
@@ -0,0 +1,126 @@
+package serve
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestRenderMarkdownShortcodes(t *testing.T) {
+	themeDir := t.TempDir()
+	writeThemeFile(t, themeDir, "templates/page.html", `{{ .Body }}`)
+	writeThemeFile(t, themeDir, "templates/shortcodes/badge.html", `<b class="badge">{{ .Get 0 }}|{{ .Page.Title }}</b>`)
+	theme, err := LoadTheme(themeDir, "", "")
+	if err != nil {
+		t.Fatalf("LoadTheme: %v", err)
+	}
+	if got := strings.Join(theme.Shortcodes(), ","); !strings.Contains(got, "badge") || !strings.Contains(got, "figure") {
+		t.Fatalf("Shortcodes = %s", got)
+	}
+
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/goods/lamp/": {Status: 200}},
+		Meta:   map[string]models.MetaEntry{"/goods/lamp/": {Title: "Lamp", Description: "A desk lamp", FM: map[string]interface{}{"price": "20 EUR"}}},
+	}
+	cfg := config.Config{}
+	md := newMarkdownRenderer()
+	page := buildPageData(models.MetaEntry{Title: "Shop"}, "", cfg)
+	page.funcs = newTemplateContext(cfg, rules.Rules{}, idx, "/shop/", md)
+	shortcodes := newShortcodeRenderer(theme, page, md, "safe")
+	shortcodes.media = func(src string) string { return "/media/" + src }
+
+	src := strings.Join([]string{
+		"New: {{< badge hot >}} [[Lamp]]",
+		"",
+		`{{< figure src="lamp.png" caption="The *lamp*" >}}`,
+		"",
+		"{{< notice warning >}}",
+		"Mind the **cable**.",
+		"{{< /notice >}}",
+		"",
+		"{{< tabs >}}",
+		`{{< tab "Specs" >}}Steel{{< /tab >}}`,
+		`{{< tab "Care" >}}Dust it{{< /tab >}}`,
+		"{{< /tabs >}}",
+		"",
+		`{{< card "/goods/lamp/" >}}`,
+		"",
+		"{{< nope >}} and `{{< badge code >}}`",
+	}, "\n")
+	out, err := renderMarkdownForBuild(src, "shop.md", "", "", "", map[string]string{"lamp": "/goods/lamp/"}, md, "safe", shortcodes)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{
+		`<p>New: <b class="badge">hot|Shop</b> <a href="/goods/lamp/">Lamp</a></p>`,
+		`<img src="/media/lamp.png" alt="The *lamp*"`,
+		`<figcaption>The <em>lamp</em></figcaption>`,
+		`<div class="callout-body">Mind the <strong>cable</strong>.</div>`,
+		`<summary>Specs</summary>`,
+		`<div class="np-tab-body">Dust it</div>`,
+		`<span class="np-card-title">Lamp</span>`,
+		`<span class="np-card-price">20 EUR</span>`,
+		`{{&lt; nope &gt;}}`,
+		`<code>{{&lt; badge code &gt;}}</code>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<p><div") || strings.Contains(out, "<p><figure") {
+		t.Fatalf("block shortcode left inside a paragraph:\n%s", out)
+	}
+	if strings.ContainsAny(out, shortcodeTagOpen+shortcodeOutOpen) {
+		t.Fatalf("placeholder left in output:\n%s", out)
+	}
+}
+
+func TestRenderMarkdownShortcodesPrivateUseRunes(t *testing.T) {
+	themeDir := t.TempDir()
+	writeThemeFile(t, themeDir, "templates/page.html", `{{ .Body }}`)
+	writeThemeFile(t, themeDir, "templates/shortcodes/badge.html", `<b>{{ .Get 0 }}</b>`)
+	writeThemeFile(t, themeDir, "templates/shortcodes/box.html", `<div>{{ .Inner }}</div>`)
+	theme, err := LoadTheme(themeDir, "", "")
+	if err != nil {
+		t.Fatalf("LoadTheme: %v", err)
+	}
+	cfg := config.Config{}
+	md := newMarkdownRenderer()
+	page := buildPageData(models.MetaEntry{Title: "Icons"}, "", cfg)
+	page.funcs = newTemplateContext(cfg, rules.Rules{}, models.ResolveIndex{}, "/icons/", md)
+
+	cases := map[string]struct {
+		src  string
+		want []string
+	}{
+		"own output placeholder": {
+			src:  "{{< badge \"\uE0020\uE003\" >}}",
+			want: []string{"<b>\uE0020\uE003</b>"},
+		},
+		"tag placeholder next to a shortcode": {
+			src:  "\uE0007\uE001 {{< badge x >}} \uE0044",
+			want: []string{"\uE0007\uE001 <b>x</b> \uE0044"},
+		},
+		"nested": {
+			src:  "{{< box >}} {{< badge in >}}{{< /box >}}",
+			want: []string{"<div><b>in</b></div>"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			shortcodes := newShortcodeRenderer(theme, page, md, "safe")
+			out, err := renderMarkdownForBuild(tc.src, "icons.md", "", "", "", nil, md, "safe", shortcodes)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Fatalf("missing %q in:\n%q", want, out)
+				}
+			}
+		})
+	}
+}
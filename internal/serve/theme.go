@@ -3,32 +3,57 @@ package serve
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/cookiespooky/notepub/internal/config"
 	"github.com/cookiespooky/notepub/internal/models"
 	"github.com/cookiespooky/notepub/internal/urlutil"
 )
 
 //go:embed embed/templates/*.html
 //go:embed embed/templates/partials/*.html
+//go:embed embed/templates/shortcodes/*.html
 //go:embed embed/assets/*
 var embeddedFS embed.FS
 
 type Theme struct {
 	layoutName   string
 	pageName     string
-	layout       *template.Template
 	page         *template.Template
-	assetsDir    string
-	assetsSubdir string
-	assetFS      fs.FS
-	fallbackFS   fs.FS
+	layers       []themeLayer
+	templates    []ThemeFile
+	assetFS      layeredFS
 	hasHomeCSS   bool
 	usedFallback bool
+	assetHashes  sync.Map
+	// bound pools template clones for renders; see acquireTemplates.
+	bound sync.Pool
+}
+
+// themeLayer is one theme in the inheritance chain.
+type themeLayer struct {
+	name      string // theme directory, or "embedded"
+	root      fs.FS
+	templates fs.FS
+	assets    fs.FS
+}
+
+// ThemeFile is a template or asset and the layer that provides it.
+type ThemeFile struct {
+	// Name is relative to the templates or assets directory.
+	Name  string
+	Layer string
+	// Shadowed lists lower layers whose file of the same name is overridden.
+	Shadowed []string
 }
 
 type PageData struct {
@@ -57,6 +82,15 @@ type PageData struct {
 	SearchNextCursor string
 	SearchMode       string
 	Settings         map[string]string
+	Lang             string
+	Translations     []Translation
+	IsDraft          bool
+	// Layout replaces layout.html when the note sets layout: in frontmatter.
+	Layout string
+
+	// funcs binds template functions such as collection and pageByPath to
+	// the index and page; nil renders with site-independent defaults.
+	funcs *templateContext
 }
 
 type PageInfo struct {
@@ -98,98 +132,279 @@ func cloneSettings(src map[string]string) map[string]string {
 	return out
 }
 
-func LoadTheme(themeDir, templatesSubdir, assetsSubdir string) (*Theme, error) {
-	fallbackFS, err := fs.Sub(embeddedFS, "embed")
-	if err != nil {
-		return nil, err
+// EmbeddedTheme returns the built-in fallback theme, with templates/ and
+// assets/ at its root.
+func EmbeddedTheme() (fs.FS, error) {
+	return fs.Sub(embeddedFS, "embed")
+}
+
+// EmbeddedThemeName is the layer name of the built-in theme.
+const EmbeddedThemeName = "embedded"
+
+// LoadThemeConfig loads the configured theme over its theme.extends parent
+// and the embedded theme.
+func LoadThemeConfig(cfg config.ThemeConfig) (*Theme, error) {
+	dirs := []string{filepath.Join(cfg.Dir, cfg.Name)}
+	embedded := true
+	switch extends := strings.TrimSpace(cfg.Extends); extends {
+	case "", EmbeddedThemeName:
+	case "none":
+		embedded = false
+	default:
+		parent := filepath.Join(cfg.Dir, extends)
+		if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("theme.extends: theme %q not found at %s", extends, parent)
+		}
+		dirs = append(dirs, parent)
 	}
+	return loadThemeLayers(dirs, cfg.TemplatesSubdir, cfg.AssetsSubdir, embedded)
+}
 
+// LoadTheme loads themeDir over the embedded theme.
+func LoadTheme(themeDir, templatesSubdir, assetsSubdir string) (*Theme, error) {
+	return loadThemeLayers([]string{themeDir}, templatesSubdir, assetsSubdir, true)
+}
+
+// loadThemeLayers stacks theme directories, highest priority first. Each
+// template and asset is taken from the first layer that has a file of that
+// name; the embedded theme, when enabled, is the last layer.
+func loadThemeLayers(dirs []string, templatesSubdir, assetsSubdir string, embedded bool) (*Theme, error) {
 	if templatesSubdir == "" {
 		templatesSubdir = "templates"
 	}
 	if assetsSubdir == "" {
 		assetsSubdir = "assets"
 	}
-	assetsDir := filepath.Join(themeDir, assetsSubdir)
-	templatesDir := filepath.Join(themeDir, templatesSubdir)
-	layoutPath := filepath.Join(templatesDir, "layout.html")
-	pagePath := filepath.Join(templatesDir, "page.html")
-
-	layoutExists := fileExists(layoutPath)
-	pageExists := fileExists(pagePath)
-
-	if layoutExists || pageExists {
-		tmpl, err := parseTemplatesDir(templatesDir)
-		if err == nil {
-			hasHomeCSS := fileExists(filepath.Join(assetsDir, "home.css"))
-			return &Theme{
-				layoutName:   "layout.html",
-				pageName:     "page.html",
-				layout:       tmpl,
-				page:         tmpl,
-				assetsDir:    assetsDir,
-				assetsSubdir: assetsSubdir,
-				assetFS:      os.DirFS(themeDir),
-				fallbackFS:   fallbackFS,
-				hasHomeCSS:   hasHomeCSS,
-				usedFallback: false,
-			}, nil
+	layers := make([]themeLayer, 0, len(dirs)+1)
+	for _, dir := range dirs {
+		root := os.DirFS(dir)
+		layers = append(layers, themeLayer{
+			name:      dir,
+			root:      root,
+			templates: subFS(root, templatesSubdir),
+			assets:    subFS(root, assetsSubdir),
+		})
+	}
+	if embedded {
+		fallbackFS, err := EmbeddedTheme()
+		if err != nil {
+			return nil, err
 		}
+		layers = append(layers, themeLayer{
+			name:      EmbeddedThemeName,
+			root:      fallbackFS,
+			templates: subFS(fallbackFS, "templates"),
+			assets:    subFS(fallbackFS, "assets"),
+		})
 	}
 
-	fallbackTemplates, err := parseTemplatesFS(fallbackFS, "templates")
-	if err != nil {
-		return nil, err
+	files := resolveThemeFiles(layers, func(l themeLayer) fs.FS { return l.templates }, themeTemplateGlobs)
+	tmpl := template.New("theme").Funcs(parseFuncs)
+	usedFallback := true
+	byLayer := map[string]themeLayer{}
+	for _, l := range layers {
+		byLayer[l.name] = l
+	}
+	for _, f := range files {
+		data, err := fs.ReadFile(byLayer[f.Layer].templates, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.New(themeTemplateName(f.Name)).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", themeFilePath(f.Layer, templatesSubdir, f.Name), err)
+		}
+		if f.Layer != EmbeddedThemeName {
+			usedFallback = false
+		}
+	}
+	if tmpl.Lookup("layout.html") == nil && tmpl.Lookup("page.html") == nil {
+		return nil, fmt.Errorf("no layout.html or page.html in theme %s", strings.Join(dirs, ", "))
 	}
-	_, err = fs.Stat(fallbackFS, filepath.ToSlash(filepath.Join("assets", "home.css")))
-	hasHomeCSS := err == nil
 
+	assetLayers := make(layeredFS, 0, len(layers))
+	for _, l := range layers {
+		assetLayers = append(assetLayers, l.assets)
+	}
+	_, err := fs.Stat(assetLayers, "home.css")
 	return &Theme{
 		layoutName:   "layout.html",
 		pageName:     "page.html",
-		layout:       fallbackTemplates,
-		page:         fallbackTemplates,
-		assetsSubdir: "assets",
-		assetFS:      fallbackFS,
-		fallbackFS:   fallbackFS,
-		hasHomeCSS:   hasHomeCSS,
-		usedFallback: true,
+		page:         tmpl,
+		layers:       layers,
+		templates:    files,
+		assetFS:      assetLayers,
+		hasHomeCSS:   err == nil,
+		usedFallback: usedFallback,
 	}, nil
 }
 
+var themeTemplateGlobs = []string{"*.html", "partials/*.html", "shortcodes/*.html"}
+
+// themeTemplateName is the name a template file is executed by: its base
+// name, except shortcodes, which keep their directory so a shortcode cannot
+// shadow a page template of the same name.
+func themeTemplateName(file string) string {
+	if strings.HasPrefix(file, "shortcodes/") {
+		return file
+	}
+	return path.Base(file)
+}
+
+// resolveThemeFiles lists files matching globs across layers, each taken
+// from the first layer that has it.
+func resolveThemeFiles(layers []themeLayer, fsOf func(themeLayer) fs.FS, globs []string) []ThemeFile {
+	index := map[string]int{}
+	var out []ThemeFile
+	for _, l := range layers {
+		for _, name := range themeFileNames(fsOf(l), globs) {
+			if i, ok := index[name]; ok {
+				out[i].Shadowed = append(out[i].Shadowed, l.name)
+				continue
+			}
+			index[name] = len(out)
+			out = append(out, ThemeFile{Name: name, Layer: l.name})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func themeFilePath(layer, subdir, name string) string {
+	if layer == EmbeddedThemeName {
+		return layer + ":" + name
+	}
+	return filepath.Join(layer, subdir, filepath.FromSlash(name))
+}
+
+func subFS(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, filepath.ToSlash(dir))
+	if err != nil {
+		return fsys
+	}
+	return sub
+}
+
+// Layers returns the theme layer names, highest priority first.
+func (t *Theme) Layers() []string {
+	out := make([]string, 0, len(t.layers))
+	for _, l := range t.layers {
+		out = append(out, l.name)
+	}
+	return out
+}
+
+// ReadFile reads name, relative to the theme root (e.g.
+// "archetypes/default.md"), from the first layer that has it. A file missing
+// from every layer returns an error matching fs.ErrNotExist.
+func (t *Theme) ReadFile(name string) ([]byte, error) {
+	for _, l := range t.layers {
+		data, err := fs.ReadFile(l.root, name)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", themeFilePath(l.name, "", name), err)
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Templates lists every template and the layer it was loaded from.
+func (t *Theme) Templates() []ThemeFile {
+	return append([]ThemeFile(nil), t.templates...)
+}
+
+// TemplateNames lists the names RenderPage can execute, including
+// templates declared with define.
+func (t *Theme) TemplateNames() []string {
+	var names []string
+	for _, tmpl := range t.page.Templates() {
+		if name := tmpl.Name(); name != "theme" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Shortcodes lists the shortcode names the theme provides.
+func (t *Theme) Shortcodes() []string {
+	names := []string{}
+	for _, name := range t.TemplateNames() {
+		if rest, ok := strings.CutPrefix(name, "shortcodes/"); ok {
+			names = append(names, strings.TrimSuffix(rest, ".html"))
+		}
+	}
+	return names
+}
+
+// Assets lists every asset and the layer it is served from.
+func (t *Theme) Assets() []ThemeFile {
+	return resolveThemeFiles(t.layers, func(l themeLayer) fs.FS { return l.assets }, nil)
+}
+
+// themeFileNames lists files matching globs, or every file when globs is nil.
+func themeFileNames(fsys fs.FS, globs []string) []string {
+	var names []string
+	if globs == nil {
+		_ = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				names = append(names, p)
+			}
+			return nil
+		})
+		return names
+	}
+	for _, pattern := range globs {
+		matches, _ := fs.Glob(fsys, pattern)
+		names = append(names, matches...)
+	}
+	return names
+}
+
 func (t *Theme) UsedFallback() bool {
 	return t.usedFallback
 }
 
 func (t *Theme) RenderPage(data PageData) (string, error) {
+	bound, err := t.acquireTemplates(data)
+	if err != nil {
+		return "", err
+	}
+	defer t.releaseTemplates(bound)
+	page, layout := bound.tmpl, bound.tmpl
 	body := data.Body
 	bodyTemplate := t.pageName
-	if data.Template != "" && templateExists(t.page, data.Template) {
+	if data.Template != "" && templateExists(page, data.Template) {
 		bodyTemplate = data.Template
 	} else {
-		if data.IsHome && templateExists(t.page, "home.html") {
+		if data.IsHome && templateExists(page, "home.html") {
 			bodyTemplate = "home.html"
 		}
-		if data.IsCategory && templateExists(t.page, "category.html") {
+		if data.IsCategory && templateExists(page, "category.html") {
 			bodyTemplate = "category.html"
 		}
-		if data.IsSearch && templateExists(t.page, "search.html") {
+		if data.IsSearch && templateExists(page, "search.html") {
 			bodyTemplate = "search.html"
 		}
 	}
 	data.HasHomeCSS = t.hasHomeCSS
-	if t.page != nil && templateExists(t.page, bodyTemplate) {
+	if page != nil && templateExists(page, bodyTemplate) {
 		var buf bytes.Buffer
-		if err := t.page.ExecuteTemplate(&buf, bodyTemplate, data); err != nil {
+		if err := page.ExecuteTemplate(&buf, bodyTemplate, data); err != nil {
 			return "", err
 		}
 		body = template.HTML(buf.String())
 	}
 	data.Body = body
 
-	if t.layout != nil && templateExists(t.layout, t.layoutName) {
+	layoutName := t.layoutName
+	if data.Layout != "" && templateExists(layout, data.Layout) {
+		layoutName = data.Layout
+	}
+	if layout != nil && templateExists(layout, layoutName) {
 		var buf bytes.Buffer
-		if err := t.layout.ExecuteTemplate(&buf, t.layoutName, data); err != nil {
+		if err := layout.ExecuteTemplate(&buf, layoutName, data); err != nil {
 			return "", err
 		}
 		return buf.String(), nil
@@ -199,16 +414,22 @@ func (t *Theme) RenderPage(data PageData) (string, error) {
 
 func (t *Theme) RenderError(err error, data PageData) (string, error) {
 	data.Error = err.Error()
-	if t.layout != nil && templateExists(t.layout, "error.html") {
+	bound, bindErr := t.acquireTemplates(data)
+	if bindErr != nil {
+		return fmt.Sprintf("render error: %s", err.Error()), nil
+	}
+	defer t.releaseTemplates(bound)
+	layout := bound.tmpl
+	if layout != nil && templateExists(layout, "error.html") {
 		var buf bytes.Buffer
-		if execErr := t.layout.ExecuteTemplate(&buf, "error.html", data); execErr == nil {
+		if execErr := layout.ExecuteTemplate(&buf, "error.html", data); execErr == nil {
 			return buf.String(), nil
 		}
 	}
-	if t.layout != nil && templateExists(t.layout, t.layoutName) {
+	if layout != nil && templateExists(layout, t.layoutName) {
 		data.Body = template.HTML(fmt.Sprintf("<section class=\"section\"><h1 class=\"section-title\">Render error</h1><pre>%s</pre></section>", template.HTMLEscapeString(err.Error())))
 		var buf bytes.Buffer
-		if execErr := t.layout.ExecuteTemplate(&buf, t.layoutName, data); execErr == nil {
+		if execErr := layout.ExecuteTemplate(&buf, t.layoutName, data); execErr == nil {
 			return buf.String(), nil
 		}
 	}
@@ -216,44 +437,30 @@ func (t *Theme) RenderError(err error, data PageData) (string, error) {
 }
 
 func (t *Theme) RenderNotFound(baseURL string, settings map[string]string) (string, error) {
-	if t.layout != nil && templateExists(t.layout, "notfound.html") {
+	data := PageData{
+		BaseURL:    baseURL,
+		AssetsBase: urlutil.JoinBaseURL(baseURL, "/assets"),
+		Settings:   cloneSettings(settings),
+	}
+	bound, err := t.acquireTemplates(data)
+	if err != nil {
+		return "Not Found", nil
+	}
+	defer t.releaseTemplates(bound)
+	layout := bound.tmpl
+	if layout != nil && templateExists(layout, "notfound.html") {
 		var buf bytes.Buffer
-		data := PageData{
-			BaseURL:    baseURL,
-			AssetsBase: urlutil.JoinBaseURL(baseURL, "/assets"),
-			Settings:   cloneSettings(settings),
-		}
-		if err := t.layout.ExecuteTemplate(&buf, "notfound.html", data); err == nil {
+		if err := layout.ExecuteTemplate(&buf, "notfound.html", data); err == nil {
 			return buf.String(), nil
 		}
 	}
 	return "Not Found", nil
 }
 
+// AssetFS merges the assets directories of all layers; a path resolves in
+// the first layer that has it.
 func (t *Theme) AssetFS() fs.FS {
-	if t.assetFS != nil {
-		return t.assetFS
-	}
-	return t.fallbackFS
-}
-
-func parseTemplatesDir(dir string) (*template.Template, error) {
-	files, err := collectTemplateFiles(dir)
-	if err != nil {
-		return nil, err
-	}
-	if len(files) == 0 {
-		return nil, fmt.Errorf("no templates found in %s", dir)
-	}
-	return template.ParseFiles(files...)
-}
-
-func parseTemplatesFS(fsRoot fs.FS, subdir string) (*template.Template, error) {
-	fsDir, err := fs.Sub(fsRoot, subdir)
-	if err != nil {
-		return nil, err
-	}
-	return template.ParseFS(fsDir, "*.html", "partials/*.html")
+	return t.assetFS
 }
 
 func templateExists(t *template.Template, name string) bool {
@@ -263,21 +470,39 @@ func templateExists(t *template.Template, name string) bool {
 	return t.Lookup(name) != nil
 }
 
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	return err == nil && !info.IsDir()
+// layeredFS resolves each path in the first file system that has it;
+// directory listings are merged.
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, fsys := range l {
+		if f, err := fsys.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
 }
 
-func collectTemplateFiles(dir string) ([]string, error) {
-	root := filepath.Join(dir, "*.html")
-	partials := filepath.Join(dir, "partials", "*.html")
-	rootFiles, err := filepath.Glob(root)
-	if err != nil {
-		return nil, err
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := map[string]bool{}
+	var out []fs.DirEntry
+	found := false
+	for _, fsys := range l {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, e := range entries {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				out = append(out, e)
+			}
+		}
 	}
-	partialFiles, err := filepath.Glob(partials)
-	if err != nil {
-		return nil, err
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
 	}
-	return append(rootFiles, partialFiles...), nil
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
 }
@@ -0,0 +1,142 @@
+package serve
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+)
+
+func TestThemeLayersOverrideByName(t *testing.T) {
+	dir := t.TempDir()
+	writeThemeFile(t, dir, "child/templates/page.html", `<p class="child">{{ .Body }}</p>`)
+	writeThemeFile(t, dir, "child/assets/styles.css", "child")
+	writeThemeFile(t, dir, "base/templates/layout.html", `<main class="base">{{ .Body }}</main>`)
+	writeThemeFile(t, dir, "base/templates/page.html", `<p class="base">{{ .Body }}</p>`)
+	writeThemeFile(t, dir, "base/assets/extra.css", "base")
+
+	theme, err := LoadThemeConfig(config.ThemeConfig{Dir: dir, Name: "child", Extends: "base"})
+	if err != nil {
+		t.Fatalf("LoadThemeConfig: %v", err)
+	}
+	if got := theme.Layers(); len(got) != 3 || got[2] != EmbeddedThemeName {
+		t.Fatalf("layers = %v", got)
+	}
+	if theme.UsedFallback() {
+		t.Fatalf("custom layers provide templates, fallback must be false")
+	}
+
+	out, err := theme.RenderPage(PageData{Body: "hi"})
+	if err != nil {
+		t.Fatalf("RenderPage: %v", err)
+	}
+	if out != `<main class="base"><p class="child">hi</p></main>` {
+		t.Fatalf("rendered = %q", out)
+	}
+	out, err = theme.RenderPage(PageData{Body: "q", IsSearch: true})
+	if err != nil || !strings.Contains(out, `class="base"`) || strings.Contains(out, `class="child"`) {
+		t.Fatalf("search page should use the embedded search.html inside the base layout: %q, %v", out, err)
+	}
+
+	sources := map[string]ThemeFile{}
+	for _, f := range theme.Templates() {
+		sources[f.Name] = f
+	}
+	page := sources["page.html"]
+	if page.Layer != filepath.Join(dir, "child") || len(page.Shadowed) != 2 {
+		t.Fatalf("page.html = %+v", page)
+	}
+	if sources["search.html"].Layer != EmbeddedThemeName {
+		t.Fatalf("search.html = %+v", sources["search.html"])
+	}
+
+	for name, want := range map[string]string{"styles.css": "child", "extra.css": "base"} {
+		data, err := fs.ReadFile(theme.AssetFS(), name)
+		if err != nil || string(data) != want {
+			t.Fatalf("asset %s = %q, %v", name, data, err)
+		}
+	}
+	if _, err := fs.ReadFile(theme.AssetFS(), "search-modal.js"); err != nil {
+		t.Fatalf("embedded asset missing: %v", err)
+	}
+}
+
+func TestThemeReadFileUsesLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeThemeFile(t, dir, "child/templates/page.html", `{{ .Body }}`)
+	writeThemeFile(t, dir, "child/archetypes/default.md", "child default")
+	writeThemeFile(t, dir, "base/archetypes/default.md", "base default")
+	writeThemeFile(t, dir, "base/archetypes/post.md", "base post")
+
+	theme, err := LoadThemeConfig(config.ThemeConfig{Dir: dir, Name: "child", Extends: "base"})
+	if err != nil {
+		t.Fatalf("LoadThemeConfig: %v", err)
+	}
+	for name, want := range map[string]string{"archetypes/default.md": "child default", "archetypes/post.md": "base post"} {
+		data, err := theme.ReadFile(name)
+		if err != nil || string(data) != want {
+			t.Fatalf("%s = %q, %v", name, data, err)
+		}
+	}
+	if _, err := theme.ReadFile("archetypes/page.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing archetype err = %v", err)
+	}
+}
+
+func TestThemeWithoutEmbeddedFallback(t *testing.T) {
+	dir := t.TempDir()
+	writeThemeFile(t, dir, "solo/templates/page.html", `{{ .Body }}`)
+	theme, err := LoadThemeConfig(config.ThemeConfig{Dir: dir, Name: "solo", Extends: "none"})
+	if err != nil {
+		t.Fatalf("LoadThemeConfig: %v", err)
+	}
+	for _, f := range theme.Templates() {
+		if f.Layer == EmbeddedThemeName {
+			t.Fatalf("embedded template loaded with extends none: %+v", f)
+		}
+	}
+
+	if _, err := LoadThemeConfig(config.ThemeConfig{Dir: dir, Name: "solo", Extends: "missing"}); err == nil {
+		t.Fatalf("expected error for missing parent theme")
+	}
+	if _, err := LoadThemeConfig(config.ThemeConfig{Dir: dir, Name: "empty", Extends: "none"}); err == nil {
+		t.Fatalf("expected error for a theme without templates")
+	}
+}
+
+func TestThemeParseErrorNamesFile(t *testing.T) {
+	dir := t.TempDir()
+	writeThemeFile(t, dir, "templates/page.html", `{{ .Body `)
+	_, err := LoadTheme(dir, "", "")
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "templates", "page.html")) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRenderPageNoteOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeThemeFile(t, dir, "templates/layout.html", `<main>{{ .Body }}</main>`)
+	writeThemeFile(t, dir, "templates/wide.html", `<main class="wide">{{ .Body }}</main>`)
+	writeThemeFile(t, dir, "templates/page.html", `<p>{{ .Body }}</p>`)
+	writeThemeFile(t, dir, "templates/landing.html", `<h1>{{ .Body }}</h1>`)
+	theme, err := LoadTheme(dir, "", "")
+	if err != nil {
+		t.Fatalf("LoadTheme: %v", err)
+	}
+
+	out, err := theme.RenderPage(PageData{Body: "hi", Template: "landing.html", Layout: "wide.html"})
+	if err != nil || out != `<main class="wide"><h1>hi</h1></main>` {
+		t.Fatalf("rendered = %q, %v", out, err)
+	}
+	out, err = theme.RenderPage(PageData{Body: "hi", Layout: "missing.html"})
+	if err != nil || out != `<main><p>hi</p></main>` {
+		t.Fatalf("missing layout should fall back to layout.html: %q, %v", out, err)
+	}
+	names := strings.Join(theme.TemplateNames(), ",")
+	if !strings.Contains(names, "landing.html") || !strings.Contains(names, "wide.html") {
+		t.Fatalf("TemplateNames = %s", names)
+	}
+}
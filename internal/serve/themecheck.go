@@ -0,0 +1,419 @@
+package serve
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+// ThemeFinding is one problem reported by CheckTheme.
+type ThemeFinding struct {
+	Code     string
+	Severity string
+	Template string
+	// Subject is the page that was rendered, e.g. "type good (synthetic)".
+	Subject string
+	Message string
+}
+
+// builtinTemplates are chosen by the renderer rather than by a type.
+var builtinTemplates = []string{"layout.html", "page.html", "home.html", "category.html", "search.html", "notfound.html", "error.html"}
+
+// CheckTheme verifies a theme against the site's rules. It renders every
+// type with synthetic page data built from rules.yaml and, when idx has
+// routes, one real page per type and template override, with a placeholder
+// body. It also reports type templates the theme lacks, top-level templates
+// nothing uses, and settings keys and collections that templates name but the
+// site does not configure.
+func CheckTheme(theme *Theme, cfg config.Config, rulesCfg rules.Rules, idx models.ResolveIndex) []ThemeFinding {
+	var out []ThemeFinding
+	md := newMarkdownRenderer()
+	render := func(subject string, data PageData) {
+		if _, err := theme.RenderPage(data); err != nil {
+			out = append(out, renderFinding(subject, err))
+		}
+	}
+
+	for _, typeName := range checkTypeOrder(rulesCfg) {
+		td := rulesCfg.Types[typeName]
+		if td.Template != "" && !templateExists(theme.page, td.Template) {
+			out = append(out, ThemeFinding{
+				Code:     "NP-THEME-MISSING-TEMPLATE",
+				Severity: "error",
+				Template: td.Template,
+				Subject:  "type " + typeName,
+				Message:  fmt.Sprintf("types.%s.template %q is not in the theme; pages fall back to %s", typeName, td.Template, theme.pageName),
+			})
+		}
+		render("type "+typeName+" (synthetic)", syntheticPageData(cfg, rulesCfg, idx, typeName, md))
+	}
+	home := syntheticPageData(cfg, rulesCfg, idx, "", md)
+	home.IsHome = true
+	render("home (synthetic)", home)
+	search := syntheticPageData(cfg, rulesCfg, idx, "", md)
+	search.Template = ""
+	search.IsSearch = true
+	search.SearchQuery = "sample"
+	search.SearchItems = []SearchItem{{Title: "Sample result", Path: "/sample/", Snippet: "Sample snippet"}}
+	render("search (synthetic)", search)
+
+	for _, pathVal := range samplePaths(idx) {
+		data := buildRoutePageData(cfg, rulesCfg, idx, pathVal, "<p>Sample body.</p>", md)
+		render(pathVal, data)
+	}
+
+	out = append(out, unusedTemplates(theme, rulesCfg, idx)...)
+	out = append(out, unknownReferences(theme, cfg, rulesCfg)...)
+	return out
+}
+
+func checkTypeOrder(rulesCfg rules.Rules) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, name := range rulesCfg.TypeOrder {
+		if _, ok := rulesCfg.Types[name]; ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var rest []string
+	for name := range rulesCfg.Types {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// syntheticPageData is a page of typeName with every frontmatter field the
+// rules know about and one sample item in every collection.
+func syntheticPageData(cfg config.Config, rulesCfg rules.Rules, idx models.ResolveIndex, typeName string, md markdownRenderer) PageData {
+	slug := "sample"
+	if typeName != "" {
+		slug = "sample-" + typeName
+	}
+	meta := models.MetaEntry{
+		Type:        typeName,
+		Slug:        slug,
+		Title:       "Sample " + firstNonEmpty(typeName, "page"),
+		Description: "Sample description.",
+		Canonical:   withBaseURL("/"+slug+"/", cfg.Site.BaseURL),
+		Lang:        cfg.DefaultLanguage(),
+		FM:          sampleFrontmatter(rulesCfg, typeName),
+	}
+	data := buildPageData(meta, "<p>Sample body.</p>", cfg)
+	data.Template = templateForType(typeName, rulesCfg)
+	data.SearchMode = "static"
+	data.Collections = sampleCollections(rulesCfg, typeName)
+	data.funcs = newTemplateContext(cfg, rulesCfg, idx, "/"+slug+"/", md)
+	return data
+}
+
+func sampleFrontmatter(rulesCfg rules.Rules, typeName string) map[string]interface{} {
+	fm := map[string]interface{}{"title": "Sample " + firstNonEmpty(typeName, "page")}
+	if typeName != "" {
+		fm["type"] = typeName
+	}
+	td := rulesCfg.Types[typeName]
+	var fields []string
+	for _, list := range [][]string{rulesCfg.Fields.Required, rulesCfg.Fields.Optional, td.Required, td.Optional} {
+		fields = append(fields, list...)
+	}
+	for name := range rulesCfg.FMFields {
+		fields = append(fields, name)
+	}
+	for _, name := range fields {
+		if _, ok := fm[name]; ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		fm[name] = sampleFieldValue(name, rulesCfg.FMFields[name])
+	}
+	return fm
+}
+
+func sampleFieldValue(name string, schema rules.FieldSchema) interface{} {
+	switch schema.Type {
+	case "number":
+		if schema.Min != nil {
+			return *schema.Min
+		}
+		return 1
+	case "boolean":
+		return true
+	case "date":
+		return "2024-01-01"
+	case "url":
+		return "https://example.com/"
+	case "string[]":
+		return []interface{}{"sample"}
+	case "enum":
+		if len(schema.Values) > 0 {
+			return schema.Values[0]
+		}
+	}
+	return "Sample " + name
+}
+
+func sampleCollections(rulesCfg rules.Rules, typeName string) map[string]models.CollectionResult {
+	out := map[string]models.CollectionResult{}
+	for name, rule := range rulesCfg.Collections {
+		item := models.CollectionItem{
+			Path:        "/sample-item/",
+			Type:        typeName,
+			Slug:        "sample-item",
+			Title:       "Sample item",
+			Description: "Sample description.",
+			CreatedAt:   "2024-01-01T00:00:00Z",
+			UpdatedAt:   "2024-01-01T00:00:00Z",
+			FM:          sampleFrontmatter(rulesCfg, typeName),
+		}
+		if rule.GroupBy.By != "" {
+			out[name] = models.CollectionResult{Groups: []models.CollectionGroup{{Key: "sample", Items: []models.CollectionItem{item}}}}
+			continue
+		}
+		out[name] = models.CollectionResult{Items: []models.CollectionItem{item}}
+	}
+	return out
+}
+
+// samplePaths picks the first published route of each type and of each
+// distinct template or layout override.
+func samplePaths(idx models.ResolveIndex) []string {
+	paths := make([]string, 0, len(idx.Routes))
+	for p, route := range idx.Routes {
+		if route.Status == 200 {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range paths {
+		meta, ok := idx.Meta[p]
+		if !ok || meta.Draft {
+			continue
+		}
+		key := meta.Type + "|" + meta.Template + "|" + meta.Layout
+		if p == "/" {
+			key = "home"
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, p)
+	}
+	return out
+}
+
+// renderFinding describes an execution error; missing fields and nil
+// dereferences get their own codes.
+func renderFinding(subject string, err error) ThemeFinding {
+	f := ThemeFinding{Code: "NP-THEME-RENDER", Severity: "error", Subject: subject, Message: err.Error()}
+	var execErr texttemplate.ExecError
+	if errors.As(err, &execErr) {
+		f.Template = execErr.Name
+	}
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "can't evaluate field"):
+		f.Code = "NP-THEME-MISSING-FIELD"
+	case strings.Contains(msg, "nil pointer evaluating"), strings.Contains(msg, "nil data"):
+		f.Code = "NP-THEME-NIL"
+	}
+	return f
+}
+
+// unusedTemplates reports top-level templates that are not chosen by the
+// renderer, a type or a note override, and not included by a used template.
+func unusedTemplates(theme *Theme, rulesCfg rules.Rules, idx models.ResolveIndex) []ThemeFinding {
+	used := map[string]bool{}
+	var queue []string
+	use := func(name string) {
+		if name != "" && !used[name] {
+			used[name] = true
+			queue = append(queue, name)
+		}
+	}
+	for _, name := range builtinTemplates {
+		use(name)
+	}
+	for _, td := range rulesCfg.Types {
+		use(td.Template)
+	}
+	for _, meta := range idx.Meta {
+		use(meta.Template)
+		use(meta.Layout)
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		tmpl := theme.page.Lookup(name)
+		if tmpl == nil || tmpl.Tree == nil {
+			continue
+		}
+		walkTemplate(tmpl.Tree.Root, func(n parse.Node) {
+			if t, ok := n.(*parse.TemplateNode); ok {
+				use(t.Name)
+			}
+		})
+	}
+
+	var out []ThemeFinding
+	for _, f := range theme.Templates() {
+		if strings.Contains(f.Name, "/") || used[f.Name] || onlyDefines(theme.page.Lookup(f.Name)) {
+			continue
+		}
+		out = append(out, ThemeFinding{
+			Code:     "NP-THEME-UNUSED",
+			Severity: "warn",
+			Template: f.Name,
+			Subject:  f.Layer,
+			Message:  "no type, note or template uses this template",
+		})
+	}
+	return out
+}
+
+// onlyDefines reports whether a template file has no output of its own; such
+// files hold {{ define }} blocks that are used by their names.
+func onlyDefines(tmpl *template.Template) bool {
+	if tmpl == nil || tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return true
+	}
+	for _, n := range tmpl.Tree.Root.Nodes {
+		text, ok := n.(*parse.TextNode)
+		if !ok || strings.TrimSpace(string(text.Text)) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// unknownReferences reports .Settings.<key> and settings "<key>" for keys no
+// settings map has, and .Collections.<name> and collection "<name>" for
+// collections rules.yaml does not define.
+func unknownReferences(theme *Theme, cfg config.Config, rulesCfg rules.Rules) []ThemeFinding {
+	settings := map[string]bool{}
+	for k := range cfg.Settings {
+		settings[k] = true
+	}
+	for _, lang := range cfg.Languages {
+		for k := range lang.Settings {
+			settings[k] = true
+		}
+	}
+	var out []ThemeFinding
+	seen := map[string]bool{}
+	report := func(tmplName, kind, key string) {
+		id := tmplName + "|" + kind + "|" + key
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		f := ThemeFinding{Severity: "warn", Template: tmplName}
+		if kind == "settings" {
+			if settings[key] {
+				return
+			}
+			f.Code = "NP-THEME-SETTING"
+			f.Message = fmt.Sprintf("settings key %q is not set in config or settings notes", key)
+		} else {
+			if _, ok := rulesCfg.Collections[key]; ok {
+				return
+			}
+			f.Code = "NP-THEME-COLLECTION"
+			f.Message = fmt.Sprintf("collection %q is not defined in rules.yaml", key)
+		}
+		out = append(out, f)
+	}
+
+	names := theme.TemplateNames()
+	for _, name := range names {
+		tmpl := theme.page.Lookup(name)
+		if tmpl == nil || tmpl.Tree == nil {
+			continue
+		}
+		walkTemplate(tmpl.Tree.Root, func(n parse.Node) {
+			switch n := n.(type) {
+			case *parse.FieldNode:
+				if len(n.Ident) >= 2 && n.Ident[0] == "Settings" {
+					report(name, "settings", n.Ident[1])
+				}
+				if len(n.Ident) >= 2 && n.Ident[0] == "Collections" {
+					report(name, "collections", n.Ident[1])
+				}
+			case *parse.CommandNode:
+				if len(n.Args) < 2 {
+					return
+				}
+				fn, ok := n.Args[0].(*parse.IdentifierNode)
+				arg, isString := n.Args[1].(*parse.StringNode)
+				if !ok || !isString {
+					return
+				}
+				switch fn.Ident {
+				case "settings":
+					report(name, "settings", arg.Text)
+				case "collection":
+					report(name, "collections", arg.Text)
+				}
+			}
+		})
+	}
+	return out
+}
+
+// walkTemplate calls fn for every node under n.
+func walkTemplate(n parse.Node, fn func(parse.Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkTemplate(c, fn)
+		}
+	case *parse.ActionNode:
+		walkTemplate(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walkTemplate(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, c := range n.Args {
+			walkTemplate(c, fn)
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.ChainNode:
+		walkTemplate(n.Node, fn)
+	case *parse.TemplateNode:
+		walkTemplate(n.Pipe, fn)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, fn func(parse.Node)) {
+	walkTemplate(b.Pipe, fn)
+	walkTemplate(b.List, fn)
+	walkTemplate(b.ElseList, fn)
+}
@@ -0,0 +1,62 @@
+package serve
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cookiespooky/notepub/internal/config"
+	"github.com/cookiespooky/notepub/internal/models"
+	"github.com/cookiespooky/notepub/internal/rules"
+)
+
+func TestCheckThemeReportsRenderAndReferenceProblems(t *testing.T) {
+	dir := t.TempDir()
+	writeThemeFile(t, dir, "templates/layout.html", `{{ .Body }}{{ template "footer.html" . }}`)
+	writeThemeFile(t, dir, "templates/footer.html", `{{ settings "site_name" }}{{ .Settings.phone }}`)
+	writeThemeFile(t, dir, "templates/page.html", `{{ .Body }}`)
+	writeThemeFile(t, dir, "templates/good.html", `{{ .FM.price }} {{ .Page.Category.Title }}`)
+	writeThemeFile(t, dir, "templates/post.html", `{{ .Page.Titel }}{{ range (collection "recent").Items }}{{ end }}`)
+	writeThemeFile(t, dir, "templates/macros.html", `{{ define "card" }}x{{ end }}`)
+	writeThemeFile(t, dir, "templates/orphan.html", `<p>orphan</p>`)
+	theme, err := LoadTheme(dir, "", "")
+	if err != nil {
+		t.Fatalf("LoadTheme: %v", err)
+	}
+
+	rulesCfg := rules.Rules{
+		Types: map[string]rules.TypeDef{
+			"good": {Template: "good.html", Required: []string{"price"}},
+			"post": {Template: "post.html"},
+			"hub":  {Template: "hub.html"},
+		},
+		FMFields: map[string]rules.FieldSchema{"price": {Type: "number"}},
+	}
+	cfg := config.Config{Settings: map[string]string{"site_name": "Garden"}}
+	idx := models.ResolveIndex{
+		Routes: map[string]models.RouteEntry{"/goods/lamp/": {Status: 200}},
+		Meta:   map[string]models.MetaEntry{"/goods/lamp/": {Type: "good", Title: "Lamp"}},
+	}
+
+	got := map[string][]ThemeFinding{}
+	for _, f := range CheckTheme(theme, cfg, rulesCfg, idx) {
+		got[f.Code] = append(got[f.Code], f)
+	}
+	if fs := got["NP-THEME-NIL"]; len(fs) != 2 || fs[0].Template != "good.html" || fs[1].Subject != "/goods/lamp/" {
+		t.Fatalf("nil findings for synthetic and indexed good page: %+v", fs)
+	}
+	if fs := got["NP-THEME-MISSING-FIELD"]; len(fs) != 1 || !strings.Contains(fs[0].Message, "Titel") {
+		t.Fatalf("missing field findings: %+v", fs)
+	}
+	if fs := got["NP-THEME-MISSING-TEMPLATE"]; len(fs) != 1 || fs[0].Template != "hub.html" {
+		t.Fatalf("missing template findings: %+v", fs)
+	}
+	if fs := got["NP-THEME-UNUSED"]; len(fs) != 1 || fs[0].Template != "orphan.html" {
+		t.Fatalf("unused findings: %+v", fs)
+	}
+	if fs := got["NP-THEME-SETTING"]; len(fs) != 1 || !strings.Contains(fs[0].Message, `"phone"`) {
+		t.Fatalf("settings findings: %+v", fs)
+	}
+	if fs := got["NP-THEME-COLLECTION"]; len(fs) != 1 || fs[0].Template != "post.html" {
+		t.Fatalf("collection findings: %+v", fs)
+	}
+}
@@ -0,0 +1,371 @@
+package templateupdate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cookiespooky/notepub/internal/schema"
+)
+
+// InitOptions configures Init.
+type InitOptions struct {
+	Root string
+	// Layout is "modern" (.np/ holds config, rules, theme and scripts) or
+	// "legacy" (everything at the project root).
+	Layout string
+	// Theme is "embedded" to export ThemeFS for customization, or "blank" for
+	// empty templates/assets folders that fall back to the built-in theme.
+	Theme string
+	// ThemeFS holds the built-in theme (templates/ and assets/).
+	ThemeFS fs.FS
+	// SettingsNotes writes Site.md and Interface.md and points overrides at them.
+	SettingsNotes bool
+	// Version is the notepub release pinned in the CI workflow; "dev" and
+	// empty fall back to the template default.
+	Version string
+	// Force overwrites existing files.
+	Force bool
+}
+
+// Init bootstraps a new project: config, rules, starter notes, theme, build
+// script and CI workflow. It refuses to overwrite files unless Force is set.
+func Init(opts InitOptions) (string, error) {
+	layout := projectLayout(strings.ToLower(strings.TrimSpace(opts.Layout)))
+	if layout == "" {
+		layout = layoutModern
+	}
+	if layout != layoutModern && layout != layoutLegacy {
+		return "", fmt.Errorf("unsupported layout %q (use modern or legacy)", opts.Layout)
+	}
+	theme := strings.ToLower(strings.TrimSpace(opts.Theme))
+	if theme == "" {
+		theme = "embedded"
+	}
+	if theme != "embedded" && theme != "blank" {
+		return "", fmt.Errorf("unsupported theme %q (use embedded or blank)", opts.Theme)
+	}
+	if theme == "embedded" && opts.ThemeFS == nil {
+		return "", fmt.Errorf("embedded theme is not available")
+	}
+	if strings.TrimSpace(opts.Root) == "" {
+		opts.Root = "."
+	}
+	if err := os.MkdirAll(opts.Root, 0o755); err != nil {
+		return "", err
+	}
+	root, err := cleanRoot(opts.Root)
+	if err != nil {
+		return "", err
+	}
+	if existing := detectLayout(root); existing != layoutUnknown && !opts.Force {
+		return "", fmt.Errorf("%s already has a %s Notepub project (use --force to overwrite)", root, existing)
+	}
+
+	files, err := initFiles(root, layout, theme, opts)
+	if err != nil {
+		return "", err
+	}
+	if !opts.Force {
+		var clashes []string
+		for _, f := range files {
+			if exists(f.path) {
+				clashes = append(clashes, rel(root, f.path))
+			}
+		}
+		if len(clashes) > 0 {
+			return "", fmt.Errorf("refusing to overwrite %s (use --force)", strings.Join(clashes, ", "))
+		}
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(f.path, []byte(f.next), f.mode); err != nil {
+			return "", fmt.Errorf("write %s: %w", f.path, err)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Initialized %s project in %s (theme: %s)\n\n", layout, root, theme)
+	fmt.Fprintln(&b, "Created:")
+	for _, f := range files {
+		fmt.Fprintf(&b, "- %s\n", rel(root, f.path))
+	}
+	configPath := "config.yaml"
+	if layout == layoutModern {
+		configPath = ".np/config.yaml"
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Next steps:")
+	fmt.Fprintf(&b, "- notepub index --config %s\n", configPath)
+	fmt.Fprintf(&b, "- notepub serve --config %s\n", configPath)
+	fmt.Fprintln(&b, "- notepub new article \"My first article\" --config "+configPath)
+	return b.String(), nil
+}
+
+func initFiles(root string, layout projectLayout, theme string, opts InitOptions) ([]fileChange, error) {
+	version := opts.Version
+	if version == "dev" {
+		version = ""
+	}
+	base := root
+	buildScript := legacyBuildScript()
+	workflow := legacyDeployWorkflow("", version)
+	resolvedConfig := "config.resolved.yaml"
+	if layout == layoutModern {
+		base = filepath.Join(root, ".np")
+		buildScript = modernBuildScript()
+		workflow = modernDeployWorkflow("", version)
+		resolvedConfig = ".np/config.resolved.yaml"
+	}
+	configText := initConfig(layout, opts.SettingsNotes)
+
+	files := []fileChange{
+		{path: filepath.Join(base, "config.yaml"), next: withSchemaHeader(configText, "config"), mode: 0o644},
+		{path: filepath.Join(base, "rules.yaml"), next: withSchemaHeader(initRules(), "rules"), mode: 0o644},
+		{path: filepath.Join(base, "scripts", "build.sh"), next: buildScript, mode: 0o755},
+		{path: filepath.Join(root, ".github", "workflows", "deploy.yml"), next: workflow, mode: 0o644},
+		{path: filepath.Join(root, ".gitignore"), next: strings.Join([]string{".notepub/", "dist/", resolvedConfig}, "\n") + "\n", mode: 0o644},
+	}
+	if layout == layoutModern {
+		files = append(files, fileChange{path: filepath.Join(base, "scripts", "prepare-settings.py"), next: initPrepareSettings(), mode: 0o755})
+	}
+	for _, name := range schema.Names {
+		data, err := schema.Generate(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fileChange{path: filepath.Join(base, schema.FileName(name)), next: string(data), mode: 0o644})
+	}
+	for name, body := range initNotes() {
+		files = append(files, fileChange{path: filepath.Join(root, "content", filepath.FromSlash(name)), next: body, mode: 0o644})
+	}
+	if opts.SettingsNotes {
+		files = append(files,
+			fileChange{path: filepath.Join(root, "Site.md"), next: defaultSiteNote(configText), mode: 0o644},
+			fileChange{path: filepath.Join(root, "Interface.md"), next: defaultInterfaceNote(), mode: 0o644},
+		)
+	}
+
+	themeDir := filepath.Join(base, "theme")
+	if theme == "blank" {
+		files = append(files,
+			fileChange{path: filepath.Join(themeDir, "templates", ".gitkeep"), mode: 0o644},
+			fileChange{path: filepath.Join(themeDir, "assets", ".gitkeep"), mode: 0o644},
+		)
+	} else {
+		err := fs.WalkDir(opts.ThemeFS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(opts.ThemeFS, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, fileChange{path: filepath.Join(themeDir, filepath.FromSlash(path.Clean(p))), next: string(data), mode: 0o644})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("export theme: %w", err)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+func initConfig(layout projectLayout, settingsNotes bool) string {
+	contentDir := "./content"
+	themeDir := "."
+	notePrefix := "./"
+	if layout == layoutModern {
+		contentDir = "../content"
+		themeDir = "./.np"
+		notePrefix = "../"
+	}
+	var b strings.Builder
+	b.WriteString(`site:
+  id: default
+  base_url: "http://127.0.0.1:8080/"
+  media_base_url: "http://127.0.0.1:8080/media/" # local fallback; CI injects the deploy media URL automatically
+  title: "Notepub Site"
+  description: "Website powered by Notepub."
+  default_og_image: ""
+
+runtime:
+  # auto keeps local builds on 127.0.0.1 and lets CI inject production URLs.
+  mode: "auto"
+
+`)
+	if settingsNotes {
+		fmt.Fprintf(&b, `overrides:
+  site_note: "%sSite.md"
+  interface_note: "%sInterface.md"
+
+`, notePrefix, notePrefix)
+	}
+	b.WriteString(defaultSettingsBlock(""))
+	fmt.Fprintf(&b, `
+content:
+  source: "local"
+  local_dir: "%s"
+
+paths:
+  file_root: ./.notepub
+  artifacts_dir: ./.notepub/artifacts
+  snapshot_file: ./.notepub/snapshot/objects.json
+  cache_root: ./.notepub/cache
+
+theme:
+  dir: "%s"
+  name: "theme"
+  templates_subdir: templates
+  assets_subdir: assets
+
+server:
+  listen: "127.0.0.1:8080"
+`, contentDir, themeDir)
+	if layout == layoutModern {
+		// Match the wording update writes for modern projects.
+		return strings.ReplaceAll(b.String(), "deploy media URL", "GitHub Pages media URL")
+	}
+	return b.String()
+}
+
+func initRules() string {
+	return `version: 1
+
+fields:
+  required: ["type", "slug", "title"]
+  optional: ["description", "draft", "tags", "date"]
+  defaults:
+    draft: false
+
+fm_schema:
+  draft: boolean
+  tags: string[]
+  date: date
+
+types:
+  home:
+    template: "home.html"
+    permalink: "/"
+    include_in: { sitemap: true, search: false }
+  page:
+    template: "page.html"
+    permalink: "/{{ slug }}/"
+    include_in: { sitemap: true, search: true }
+  article:
+    template: "page.html"
+    permalink: "/articles/{{ slug }}/"
+    dir: "articles"
+    include_in: { sitemap: true, search: true }
+
+links:
+  - name: "wiki"
+    kind: "wikilinks"
+    from_types: ["home", "page", "article"]
+    resolve_by: "wikimap"
+    to_types: ["page", "article"]
+    resolve:
+      order: ["path", "filename", "slug"]
+      ambiguity: "error"
+      missing: "warn_skip"
+      case: "insensitive"
+
+collections:
+  recent_articles:
+    kind: "filter"
+    where:
+      all:
+        - fm_eq: { key: "draft", value: false }
+        - type_in: ["article"]
+    sort:
+      by: "created_at"
+      dir: "desc"
+      nulls_last: true
+    limit: 20
+
+sitemap:
+  include_types: ["home", "page", "article"]
+  exclude_drafts: true
+
+search:
+  include_types: ["page", "article"]
+  exclude_drafts: true
+  preview:
+    from: "description"
+    max_len: 180
+
+validation:
+  duplicate_route: { action: "error" }
+  unknown_type: { action: "error" }
+  unique_slug: { action: "error" }
+`
+}
+
+func initNotes() map[string]string {
+	return map[string]string{
+		"home.md": `---
+type: home
+slug: home
+title: "Home"
+description: "Start page of the site."
+---
+Welcome! Edit ` + "`content/home.md`" + ` to change this page.
+
+- [[Getting Started]]
+- [[Hello World]]
+`,
+		"getting-started.md": `---
+type: page
+slug: getting-started
+title: "Getting Started"
+description: "How this site is built."
+---
+Notes live in ` + "`content/`" + `. Each note has a ` + "`type`" + ` from ` + "`rules.yaml`" + `, which picks its template and URL.
+
+Create a new note with:
+
+` + "```bash\nnotepub new article \"My first article\"\n```" + `
+`,
+		"articles/hello-world.md": `---
+type: article
+slug: hello-world
+title: "Hello World"
+description: "The first article."
+date: 2026-01-01
+tags: [notepub]
+---
+This is an article. Link to other notes with wikilinks such as [[Getting Started]].
+`,
+	}
+}
+
+func initPrepareSettings() string {
+	return `#!/usr/bin/env python3
+"""Settings hook for .np/scripts/build.sh, generated by notepub init.
+
+notes <config>: print the config path to build with.
+assets <config> <dist>: add generated files (icons, manifest) to dist.
+
+Replace this file with the template project's version for icon, manifest
+and llms.txt generation.
+"""
+import sys
+
+
+def main() -> int:
+    if len(sys.argv) >= 3 and sys.argv[1] == "notes":
+        print(sys.argv[2])
+    return 0
+
+
+if __name__ == "__main__":
+    raise SystemExit(main())
+`
+}
@@ -0,0 +1,78 @@
+package templateupdate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInitModernPassesCheck(t *testing.T) {
+	root := t.TempDir()
+	themeFS := fstest.MapFS{
+		"templates/layout.html": {Data: []byte("layout")},
+		"assets/styles.css":     {Data: []byte("body{}")},
+	}
+	if _, err := Init(InitOptions{Root: root, Layout: "modern", ThemeFS: themeFS, SettingsNotes: true, Version: "v1.2.3"}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if got := detectLayout(root); got != layoutModern {
+		t.Fatalf("layout = %s, want modern", got)
+	}
+	for _, item := range checks(root, layoutModern) {
+		if strings.Contains(item, "missing") || strings.Contains(item, " not ") {
+			t.Fatalf("check failed after init: %s", item)
+		}
+	}
+	if readFile(t, filepath.Join(root, ".np", "theme", "templates", "layout.html")) != "layout" {
+		t.Fatalf("embedded theme was not exported")
+	}
+	cfg := readFile(t, filepath.Join(root, ".np", "config.yaml"))
+	if !strings.Contains(cfg, `local_dir: "../content"`) || !strings.Contains(cfg, `site_note: "../Site.md"`) {
+		t.Fatalf("modern config paths are not relative to .np:\n%s", cfg)
+	}
+	if ch, err := patchConfig(filepath.Join(root, ".np", "config.yaml"), true); err != nil || ch != nil {
+		t.Fatalf("update would rewrite the fresh config: %+v, %v", ch, err)
+	}
+	if !strings.Contains(readFile(t, filepath.Join(root, ".github", "workflows", "deploy.yml")), "v1.2.3") {
+		t.Fatalf("workflow does not pin the version")
+	}
+
+	if _, err := Init(InitOptions{Root: root, ThemeFS: themeFS}); err == nil {
+		t.Fatalf("expected Init to refuse an existing project")
+	}
+}
+
+func TestInitLegacyBlankTheme(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Init(InitOptions{Root: root, Layout: "legacy", Theme: "blank"}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if got := detectLayout(root); got != layoutLegacy {
+		t.Fatalf("layout = %s, want legacy", got)
+	}
+	if !exists(filepath.Join(root, "theme", "templates")) || exists(filepath.Join(root, "theme", "templates", "layout.html")) {
+		t.Fatalf("blank theme should create empty template folders")
+	}
+	if exists(filepath.Join(root, "Site.md")) {
+		t.Fatalf("settings notes written without SettingsNotes")
+	}
+	if strings.Contains(readFile(t, filepath.Join(root, "config.yaml")), "overrides:") {
+		t.Fatalf("overrides written without SettingsNotes")
+	}
+}
+
+func TestInitRefusesToOverwriteFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "content"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "content", "home.md"), "mine")
+	if _, err := Init(InitOptions{Root: root, Theme: "blank"}); err == nil || !strings.Contains(err.Error(), "content/home.md") {
+		t.Fatalf("expected overwrite refusal, got %v", err)
+	}
+	if _, err := Init(InitOptions{Root: root, Theme: "blank", Force: true}); err != nil {
+		t.Fatalf("Init --force: %v", err)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/cookiespooky/notepub/internal/schema"
 )
 
 type UpdateOptions struct {
@@ -178,6 +180,11 @@ func plannedLegacyChanges(root string) ([]fileChange, error) {
 	} else {
 		changes = append(changes, noteChanges...)
 	}
+	if schemaChanges, err := schemaChanges(root); err != nil {
+		return nil, err
+	} else {
+		changes = append(changes, schemaChanges...)
+	}
 	return changes, nil
 }
 
@@ -216,6 +223,11 @@ func plannedModernChanges(root string) ([]fileChange, error) {
 	} else {
 		changes = append(changes, noteChanges...)
 	}
+	if schemaChanges, err := schemaChanges(filepath.Join(root, ".np")); err != nil {
+		return nil, err
+	} else {
+		changes = append(changes, schemaChanges...)
+	}
 	return changes, nil
 }
 
@@ -277,6 +289,7 @@ func patchConfig(path string, modern bool) (*fileChange, error) {
 	if modern {
 		next = strings.ReplaceAll(next, "deploy media URL", "GitHub Pages media URL")
 	}
+	next = withSchemaHeader(next, "config")
 	if next == prev {
 		return nil, nil
 	}
@@ -287,6 +300,47 @@ func patchConfig(path string, modern bool) (*fileChange, error) {
 	return &fileChange{path: path, prev: prev, next: next, mode: mode}, nil
 }
 
+// schemaChanges refreshes config.schema.json and rules.schema.json in dir and
+// points rules.yaml at its schema; patchConfig does the same for config.yaml.
+func schemaChanges(dir string) ([]fileChange, error) {
+	var changes []fileChange
+	for _, name := range schema.Names {
+		data, err := schema.Generate(name)
+		if err != nil {
+			return nil, err
+		}
+		ch, err := changeIfDifferent(filepath.Join(dir, schema.FileName(name)), string(data), 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if ch != nil {
+			changes = append(changes, *ch)
+		}
+	}
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	if data, err := os.ReadFile(rulesPath); err == nil {
+		if next := withSchemaHeader(string(data), "rules"); next != string(data) {
+			ch, err := changeIfDifferent(rulesPath, next, 0)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, *ch)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", rulesPath, err)
+	}
+	return changes, nil
+}
+
+// withSchemaHeader prepends the yaml-language-server modeline for the named
+// schema unless the file already declares one.
+func withSchemaHeader(text, name string) string {
+	if strings.Contains(text, "yaml-language-server: $schema=") {
+		return text
+	}
+	return "# yaml-language-server: $schema=./" + schema.FileName(name) + "\n" + text
+}
+
 func defaultSettingsBlock(configText string) string {
 	title := yamlValue(configText, "title")
 	if title == "" {
@@ -318,19 +372,29 @@ func rootNoteChanges(root, configPath string) ([]fileChange, error) {
 	var changes []fileChange
 	sitePath := filepath.Join(root, "Site.md")
 	if !exists(sitePath) {
-		title := yamlValue(cfg, "title")
-		if title == "" {
-			title = "Notepub Site"
-		}
-		description := yamlValue(cfg, "description")
-		if description == "" {
-			description = "Website powered by Notepub."
-		}
-		ogImage := yamlValue(cfg, "default_og_image")
-		if ogImage == "" || ogImage == "/assets/notepub.jpg" {
-			ogImage = "/media/notepub.jpg"
-		}
-		next := fmt.Sprintf(`---
+		changes = append(changes, fileChange{path: sitePath, next: defaultSiteNote(cfg), mode: 0o644})
+	}
+	interfacePath := filepath.Join(root, "Interface.md")
+	if !exists(interfacePath) {
+		changes = append(changes, fileChange{path: interfacePath, next: defaultInterfaceNote(), mode: 0o644})
+	}
+	return changes, nil
+}
+
+func defaultSiteNote(cfg string) string {
+	title := yamlValue(cfg, "title")
+	if title == "" {
+		title = "Notepub Site"
+	}
+	description := yamlValue(cfg, "description")
+	if description == "" {
+		description = "Website powered by Notepub."
+	}
+	ogImage := yamlValue(cfg, "default_og_image")
+	if ogImage == "" || ogImage == "/assets/notepub.jpg" {
+		ogImage = "/media/notepub.jpg"
+	}
+	return fmt.Sprintf(`---
 site_title: %s
 site_description: %s
 site_language: en
@@ -348,13 +412,6 @@ theme_radius: 14
 
 Edit these properties in Obsidian to customize the site without changing config.yaml.
 `, quoteYAML(title), quoteYAML(description), quoteYAML(ogImage), quoteYAML(title))
-		changes = append(changes, fileChange{path: sitePath, next: next, mode: 0o644})
-	}
-	interfacePath := filepath.Join(root, "Interface.md")
-	if !exists(interfacePath) {
-		changes = append(changes, fileChange{path: interfacePath, next: defaultInterfaceNote(), mode: 0o644})
-	}
-	return changes, nil
 }
 
 func yamlValue(text, key string) string {
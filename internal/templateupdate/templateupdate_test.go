@@ -59,6 +59,15 @@ func TestLegacyUpdateApplyWritesInfrastructure(t *testing.T) {
 	if !strings.Contains(ignore, "config.resolved.yaml") {
 		t.Fatalf("gitignore was not updated")
 	}
+	if !strings.HasPrefix(cfg, "# yaml-language-server: $schema=./config.schema.json\n") {
+		t.Fatalf("config schema header was not added:\n%s", cfg)
+	}
+	if !strings.HasPrefix(readFile(t, filepath.Join(root, "rules.yaml")), "# yaml-language-server: $schema=./rules.schema.json\n") {
+		t.Fatalf("rules schema header was not added")
+	}
+	if !strings.Contains(readFile(t, filepath.Join(root, "rules.schema.json")), `"$schema"`) {
+		t.Fatalf("rules.schema.json was not written")
+	}
 }
 
 func TestCheckReportsManualFindings(t *testing.T) {
@@ -0,0 +1,243 @@
+// Package yamlutil decodes config files strictly: unknown keys are reported
+// with their file, line and a "did you mean" suggestion instead of being
+// silently ignored.
+package yamlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownKey is a mapping key that no field of the target type accepts.
+type UnknownKey struct {
+	Line int
+	// Path is the dotted path of the enclosing mapping ("collections.recent");
+	// empty at the top level.
+	Path       string
+	Key        string
+	Suggestion string
+}
+
+// StrictError lists every unknown key in a file.
+type StrictError struct {
+	File string
+	Keys []UnknownKey
+}
+
+func (e *StrictError) Error() string {
+	lines := make([]string, 0, len(e.Keys))
+	for _, k := range e.Keys {
+		lines = append(lines, k.message(e.File))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (k UnknownKey) message(file string) string {
+	msg := fmt.Sprintf("%s: unknown key %q", Pos(file, k.Line), k.Key)
+	if k.Path != "" {
+		msg += " in " + k.Path
+	}
+	if k.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", k.Suggestion)
+	}
+	return msg
+}
+
+// Lines maps dotted key paths ("collections.recent.link", "links[0].name")
+// to the line the key appears on.
+type Lines map[string]int
+
+// Pos formats file:line, or just file when line is unknown.
+func Pos(file string, line int) string {
+	if line <= 0 {
+		return file
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+// DecodeStrict decodes data into out, rejecting keys that out's type does not
+// declare. file is only used in messages. An empty document leaves out unchanged.
+func DecodeStrict(file string, data []byte, out interface{}) (Lines, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	w := walker{lines: Lines{}}
+	w.walk(&root, reflect.TypeOf(out), "")
+	if len(w.unknown) > 0 {
+		return w.lines, &StrictError{File: file, Keys: w.unknown}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return w.lines, err
+	}
+	return w.lines, nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+type walker struct {
+	lines   Lines
+	unknown []UnknownKey
+}
+
+func (w *walker) walk(node *yaml.Node, t reflect.Type, path string) {
+	if node == nil || t == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			w.walk(node.Content[0], t, path)
+		}
+		return
+	case yaml.AliasNode:
+		w.walk(node.Alias, t, path)
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// Custom unmarshalers may accept other shapes (a scalar shorthand);
+	// only mappings are checked against the struct fields.
+	if reflect.PtrTo(t).Implements(unmarshalerType) && node.Kind != yaml.MappingNode {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				w.walk(val, t, path)
+				continue
+			}
+			w.lines[join(path, key.Value)] = key.Line
+			ft, ok := fields[key.Value]
+			if !ok {
+				names := make([]string, 0, len(fields))
+				for name := range fields {
+					names = append(names, name)
+				}
+				w.unknown = append(w.unknown, UnknownKey{Line: key.Line, Path: path, Key: key.Value, Suggestion: Suggest(key.Value, names)})
+				continue
+			}
+			w.walk(val, ft, join(path, key.Value))
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			w.lines[join(path, key.Value)] = key.Line
+			w.walk(val, t.Elem(), join(path, key.Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			w.lines[p] = item.Line
+			w.walk(item, t.Elem(), p)
+		}
+	}
+}
+
+// structFields maps yaml key names to field types, following `,inline`.
+func structFields(t reflect.Type) map[string]reflect.Type {
+	out := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(","+opts+",", ",inline,") {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range structFields(ft) {
+					out[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		out[name] = f.Type
+	}
+	return out
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Suggest returns the candidate closest to name, or "" when none is close
+// enough to be a likely typo.
+func Suggest(name string, candidates []string) string {
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+	norm := func(s string) string { return strings.ReplaceAll(strings.ToLower(s), "-", "_") }
+	best, bestDist := "", -1
+	for _, c := range sorted {
+		d := distance(norm(name), norm(c))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	limit := len(name) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if best == "" || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+// distance is the Levenshtein edit distance between a and b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
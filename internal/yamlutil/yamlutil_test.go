@@ -0,0 +1,83 @@
+package yamlutil
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type shorthand struct {
+	Type string `yaml:"type"`
+	Min  int    `yaml:"min"`
+}
+
+func (s *shorthand) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.Type = node.Value
+		return nil
+	}
+	type plain shorthand
+	return node.Decode((*plain)(s))
+}
+
+type doc struct {
+	Name   string               `yaml:"name"`
+	Fields map[string]shorthand `yaml:"fields"`
+	Items  []struct {
+		ID string `yaml:"id"`
+	} `yaml:"items"`
+	Extra map[string]interface{} `yaml:"extra"`
+}
+
+func TestDecodeStrictReportsNestedUnknownKeys(t *testing.T) {
+	data := []byte(`name: x
+fields:
+  a: number
+  b:
+    type: number
+    mn: 1
+items:
+  - id: one
+    idd: two
+extra:
+  anything: [1, 2]
+`)
+	var out doc
+	lines, err := DecodeStrict("f.yaml", data, &out)
+	var strict *StrictError
+	if !errors.As(err, &strict) || len(strict.Keys) != 2 {
+		t.Fatalf("err = %v", err)
+	}
+	if got := strict.Keys[0]; got.Line != 6 || got.Path != "fields.b" || got.Suggestion != "min" {
+		t.Fatalf("first key = %+v", got)
+	}
+	if got := strict.Keys[1]; got.Line != 9 || got.Path != "items[0]" || got.Suggestion != "id" {
+		t.Fatalf("second key = %+v", got)
+	}
+	if lines["fields.b"] != 4 || lines["extra.anything"] != 11 {
+		t.Fatalf("lines = %v", lines)
+	}
+}
+
+func TestDecodeStrictDecodesValidInput(t *testing.T) {
+	var out doc
+	if _, err := DecodeStrict("f.yaml", []byte("name: x\nfields:\n  a: number\n"), &out); err != nil {
+		t.Fatalf("DecodeStrict: %v", err)
+	}
+	if out.Name != "x" || out.Fields["a"].Type != "number" {
+		t.Fatalf("out = %+v", out)
+	}
+	if _, err := DecodeStrict("f.yaml", nil, &out); err != nil {
+		t.Fatalf("empty document: %v", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	if got := Suggest("include-types", []string{"include_types", "exclude_drafts"}); got != "include_types" {
+		t.Fatalf("got %q", got)
+	}
+	if got := Suggest("zzz", []string{"include_types"}); got != "" {
+		t.Fatalf("got %q", got)
+	}
+}